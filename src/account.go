@@ -0,0 +1,419 @@
+package main
+
+import (
+  "bufio"
+  "context"
+  "encoding/json"
+  "errors"
+  "fmt"
+  "net/http"
+  "os"
+  "regexp"
+  "strings"
+  "sync"
+  "time"
+
+  "golang.org/x/crypto/bcrypt"
+)
+
+// Lightweight player accounts: a username and a bcrypt password hash, kept in a local file. Logging in hands out a
+// random token that works both as a session cookie (for the browser) and as a bearer token (for scripts). Tokens
+// only live in memory, so everyone has to log in again after a restart.
+
+const DEFAULT_ACCOUNTS_FILE = "accounts.jsonl"
+const AUTH_COOKIE_NAME = "chopsticks_session"
+const AUTH_TOKEN_TTL = 30 * 24 * time.Hour
+const ACCOUNT_MIN_PASSWORD_LENGTH = 8
+
+var USERNAME_REGEXP *regexp.Regexp = regexp.MustCompile(`^[A-Za-z0-9_.-]{2,24}$`)
+
+var ERR_USERNAME_TAKEN error = errors.New("that username is taken")
+var ERR_BAD_CREDENTIALS error = errors.New("wrong username or password")
+
+type account struct {
+  Username string
+  PasswordHash []byte
+  Created time.Time
+}
+
+type AccountStore interface {
+  // Creates an account, failing with ERR_USERNAME_TAKEN if the username exists.
+  create(username string, password string) (*account, error)
+  // Fails with ERR_BAD_CREDENTIALS if there's no such account or the password doesn't match.
+  authenticate(username string, password string) (*account, error)
+  get(username string) (*account, bool, error)
+}
+
+// Accounts as JSON lines, one per account.
+type fileAccountStore struct {
+  mu sync.Mutex
+  path string
+}
+
+func createFileAccountStore(path string) *fileAccountStore {
+  return &fileAccountStore{sync.Mutex{}, path}
+}
+
+func validateAccount(username string, password string) error {
+  if !USERNAME_REGEXP.MatchString(username) {
+    return fmt.Errorf("Invalid username %q, use 2 to 24 letters, digits, dots, dashes or underscores", username)
+  }
+  if err := validatePlayerName(username); err != nil {
+    return err
+  }
+  if len(password) < ACCOUNT_MIN_PASSWORD_LENGTH {
+    return fmt.Errorf("Passwords need at least %d characters", ACCOUNT_MIN_PASSWORD_LENGTH)
+  }
+  return nil
+}
+
+// Usernames are case insensitive, so nobody can sign up as Alice to pass as alice.
+func (fs *fileAccountStore) readLocked(username string) (*account, bool, error) {
+  f, err := os.Open(fs.path)
+  if os.IsNotExist(err) {
+    return nil, false, nil
+  } else if err != nil {
+    return nil, false, err
+  }
+  defer f.Close()
+  scanner := bufio.NewScanner(f)
+  for lineNum := 1; scanner.Scan(); lineNum++ {
+    if len(scanner.Bytes()) == 0 {
+      continue
+    }
+    var a account
+    if err := json.Unmarshal(scanner.Bytes(), &a); err != nil {
+      return nil, false, fmt.Errorf("%s:%d: %v", fs.path, lineNum, err)
+    }
+    if strings.EqualFold(a.Username, username) {
+      return &a, true, nil
+    }
+  }
+  return nil, false, scanner.Err()
+}
+
+func (fs *fileAccountStore) create(username string, password string) (*account, error) {
+  if err := validateAccount(username, password); err != nil {
+    return nil, err
+  }
+  hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+  if err != nil {
+    return nil, err
+  }
+  a := &account{username, hash, time.Now()}
+  line, err := json.Marshal(a)
+  if err != nil {
+    return nil, err
+  }
+  fs.mu.Lock()
+  defer fs.mu.Unlock()
+  if _, exists, err := fs.readLocked(username); err != nil {
+    return nil, err
+  } else if exists {
+    return nil, ERR_USERNAME_TAKEN
+  }
+  // Only the owner can read the password hashes.
+  f, err := os.OpenFile(fs.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+  if err != nil {
+    return nil, err
+  }
+  if _, err := f.Write(append(line, '\n')); err != nil {
+    f.Close()
+    return nil, err
+  }
+  return a, f.Close()
+}
+
+// Hashes a password nobody has, for authenticate to check against when there's no such account.
+var dummyPasswordHash = sync.OnceValue(func() []byte {
+  hash, _ := bcrypt.GenerateFromPassword([]byte("not anyone's password"), bcrypt.DefaultCost)
+  return hash
+})
+
+func (fs *fileAccountStore) authenticate(username string, password string) (*account, error) {
+  a, ok, err := fs.get(username)
+  if err != nil {
+    return nil, err
+  }
+  if !ok {
+    // Take as long as a wrong password would, so response times don't give away who has an account.
+    bcrypt.CompareHashAndPassword(dummyPasswordHash(), []byte(password))
+    return nil, ERR_BAD_CREDENTIALS
+  }
+  if err := bcrypt.CompareHashAndPassword(a.PasswordHash, []byte(password)); err != nil {
+    return nil, ERR_BAD_CREDENTIALS
+  }
+  return a, nil
+}
+
+func (fs *fileAccountStore) get(username string) (*account, bool, error) {
+  fs.mu.Lock()
+  defer fs.mu.Unlock()
+  return fs.readLocked(username)
+}
+
+// ==== Authentication ====
+
+type authToken struct {
+  username string
+  expires time.Time
+}
+
+type authenticator struct {
+  accounts AccountStore
+  mu sync.Mutex
+  tokens map[string]authToken
+}
+
+func createAuthenticator(accounts AccountStore) *authenticator {
+  return &authenticator{accounts, sync.Mutex{}, make(map[string]authToken)}
+}
+
+// Starts a session for the user, returning its token.
+func (a *authenticator) login(username string) (string, error) {
+  token, err := newAuthToken()
+  if err != nil {
+    return "", err
+  }
+  now := time.Now()
+  a.mu.Lock()
+  defer a.mu.Unlock()
+  // Tokens nobody uses again would otherwise stay forever, clear them out while we're here.
+  for other, t := range a.tokens {
+    if now.After(t.expires) {
+      delete(a.tokens, other)
+    }
+  }
+  a.tokens[token] = authToken{username, now.Add(AUTH_TOKEN_TTL)}
+  return token, nil
+}
+
+func (a *authenticator) logout(token string) {
+  a.mu.Lock()
+  defer a.mu.Unlock()
+  delete(a.tokens, token)
+}
+
+// The user a token belongs to, if it's still valid.
+func (a *authenticator) lookup(token string) (string, bool) {
+  a.mu.Lock()
+  defer a.mu.Unlock()
+  t, ok := a.tokens[token]
+  if !ok {
+    return "", false
+  }
+  if time.Now().After(t.expires) {
+    delete(a.tokens, token)
+    return "", false
+  }
+  return t.username, true
+}
+
+func newAuthToken() (string, error) {
+  // Twice as long as a session id, these are worth guessing.
+  first, err := newSessionId()
+  if err != nil {
+    return "", err
+  }
+  second, err := newSessionId()
+  if err != nil {
+    return "", err
+  }
+  return first + second, nil
+}
+
+// The token from an "Authorization: Bearer" header, or from the session cookie.
+func tokenFromRequest(r *http.Request) string {
+  if header := r.Header.Get("Authorization"); strings.HasPrefix(header, "Bearer ") {
+    return strings.TrimPrefix(header, "Bearer ")
+  }
+  if cookie, err := r.Cookie(AUTH_COOKIE_NAME); err == nil {
+    return cookie.Value
+  }
+  return ""
+}
+
+type accountContextKey struct{}
+
+// Router middleware that works out who's making the request. It never turns anyone away; see requireAccount.
+func (a *authenticator) middleware(next http.Handler) http.Handler {
+  fn := func (w http.ResponseWriter, r *http.Request) {
+    if username, ok := a.lookup(tokenFromRequest(r)); ok {
+      r = r.WithContext(context.WithValue(r.Context(), accountContextKey{}, username))
+    }
+    next.ServeHTTP(w, r)
+  }
+  return http.HandlerFunc(fn)
+}
+
+// Router middleware for per-user endpoints: 401 unless the request comes from someone logged in.
+func requireAccount(next http.Handler) http.Handler {
+  fn := func (w http.ResponseWriter, r *http.Request) {
+    if accountFromRequest(r) == "" {
+      http.Error(w, "log in first", http.StatusUnauthorized)
+      return
+    }
+    next.ServeHTTP(w, r)
+  }
+  return http.HandlerFunc(fn)
+}
+
+// The username of whoever made the request, empty if they're not logged in.
+func accountFromRequest(r *http.Request) string {
+  username, _ := r.Context().Value(accountContextKey{}).(string)
+  return username
+}
+
+// The name to record for whoever made the request. Logged in users always play under their username; guests can
+// pick any name that isn't someone's account. A nil authenticator means accounts are turned off.
+func playerNameForRequest(a *authenticator, r *http.Request, requested string) (string, error) {
+  if username := accountFromRequest(r); username != "" {
+    return username, nil
+  }
+  if err := validatePlayerName(requested); err != nil {
+    return "", err
+  }
+  if a != nil && requested != "" {
+    if _, exists, err := a.accounts.get(requested); err != nil {
+      return "", err
+    } else if exists {
+      return "", fmt.Errorf("%q has an account, log in to play as them", requested)
+    }
+  }
+  return requested, nil
+}
+
+// ==== Handlers ====
+
+type accountRequest struct {
+  Username string `json:"username"`
+  Password string `json:"password"`
+}
+
+type loginResponse struct {
+  Username string
+  Token string
+}
+
+// Whether the browser reached us over https, directly or through a proxy, so cookies can be kept off plain http.
+func isSecureRequest(r *http.Request) bool {
+  return r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https"
+}
+
+func writeLogin(w http.ResponseWriter, r *http.Request, a *authenticator, username string, status int) {
+  token, err := a.login(username)
+  if err != nil {
+    http.Error(w, "can't log in", http.StatusInternalServerError)
+    return
+  }
+  http.SetCookie(w, &http.Cookie{
+    Name: AUTH_COOKIE_NAME,
+    Value: token,
+    Path: "/",
+    MaxAge: int(AUTH_TOKEN_TTL / time.Second),
+    HttpOnly: true,
+    Secure: isSecureRequest(r),
+    SameSite: http.SameSiteLaxMode,
+  })
+  writeJson(w, r, status, &loginResponse{username, token})
+}
+
+// POST /accounts signs up and logs in.
+func getCreateAccountHandler(a *authenticator) http.Handler {
+  fn := func (w http.ResponseWriter, r *http.Request) {
+    var req accountRequest
+    if err := readJsonBody(r, &req); err != nil {
+      http.Error(w, "can't read body: " + err.Error(), http.StatusBadRequest)
+      return
+    }
+    if err := validateAccount(req.Username, req.Password); err != nil {
+      http.Error(w, err.Error(), http.StatusBadRequest)
+      return
+    }
+    created, err := a.accounts.create(req.Username, req.Password)
+    if errors.Is(err, ERR_USERNAME_TAKEN) {
+      http.Error(w, err.Error(), http.StatusConflict)
+      return
+    } else if err != nil {
+      requestLogger(r).Error("Error creating account", "username", req.Username, "err", err)
+      http.Error(w, "can't create account", http.StatusInternalServerError)
+      return
+    }
+    writeLogin(w, r, a, created.Username, http.StatusCreated)
+  }
+  return http.HandlerFunc(fn)
+}
+
+// POST /login
+func getLoginHandler(a *authenticator) http.Handler {
+  fn := func (w http.ResponseWriter, r *http.Request) {
+    var req accountRequest
+    if err := readJsonBody(r, &req); err != nil {
+      http.Error(w, "can't read body: " + err.Error(), http.StatusBadRequest)
+      return
+    }
+    loggedIn, err := a.accounts.authenticate(req.Username, req.Password)
+    if errors.Is(err, ERR_BAD_CREDENTIALS) {
+      http.Error(w, err.Error(), http.StatusUnauthorized)
+      return
+    } else if err != nil {
+      requestLogger(r).Error("Error logging in", "username", req.Username, "err", err)
+      http.Error(w, "can't log in", http.StatusInternalServerError)
+      return
+    }
+    writeLogin(w, r, a, loggedIn.Username, http.StatusOK)
+  }
+  return http.HandlerFunc(fn)
+}
+
+// POST /me/logout
+func getLogoutHandler(a *authenticator) http.Handler {
+  fn := func (w http.ResponseWriter, r *http.Request) {
+    a.logout(tokenFromRequest(r))
+    http.SetCookie(w, &http.Cookie{Name: AUTH_COOKIE_NAME, Value: "", Path: "/", MaxAge: -1, Secure: isSecureRequest(r)})
+    w.WriteHeader(http.StatusNoContent)
+  }
+  return http.HandlerFunc(fn)
+}
+
+type accountView struct {
+  Username string
+  Created time.Time
+}
+
+// GET /me
+func getMeHandler(a *authenticator) http.Handler {
+  fn := func (w http.ResponseWriter, r *http.Request) {
+    me, ok, err := a.accounts.get(accountFromRequest(r))
+    if err != nil || !ok {
+      requestLogger(r).Error("Error fetching account", "username", accountFromRequest(r), "err", err)
+      http.Error(w, "can't fetch account", http.StatusInternalServerError)
+      return
+    }
+    writeJson(w, r, http.StatusOK, &accountView{me.Username, me.Created})
+  }
+  return http.HandlerFunc(fn)
+}
+
+// GET /me/games lists the saved games the user played in.
+func getMyGamesHandler(games GameStore) http.Handler {
+  fn := func (w http.ResponseWriter, r *http.Request) {
+    username := accountFromRequest(r)
+    summaries := []gameRecordSummary{}
+    if games != nil {
+      records, err := games.list()
+      if err != nil {
+        requestLogger(r).Error("Error listing games", "err", err)
+        http.Error(w, "can't list games", http.StatusInternalServerError)
+        return
+      }
+      for _, record := range records {
+        if record.Players["p1"] == username || record.Players["p2"] == username {
+          summaries = append(summaries, record.summary())
+        }
+      }
+    }
+    writeJson(w, r, http.StatusOK, summaries)
+  }
+  return http.HandlerFunc(fn)
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+  "encoding/json"
+  "errors"
+  "fmt"
+  "log/slog"
+  "net/http"
+  "net/http/cookiejar"
+  "net/http/httptest"
+  "os"
+  "path/filepath"
+  "strings"
+  "testing"
+  "time"
+)
+
+func TestFileAccountStore(t *testing.T) {
+  fmt.Println("starting TestFileAccountStore")
+  path := filepath.Join(t.TempDir(), "accounts.jsonl")
+  accounts := createFileAccountStore(path)
+  if _, err := accounts.create("alice", "correct horse"); err != nil {
+    t.Fatal(err.Error())
+  }
+  if _, err := accounts.create("Alice", "battery staple"); !errors.Is(err, ERR_USERNAME_TAKEN) {
+    t.Fatalf("Expected the username to be taken: %v", err)
+  }
+  for _, bad := range [][]string{{"a", "long enough"}, {"no spaces", "long enough"}, {"perfect", "long enough"}, {"bob", "short"}} {
+    if _, err := accounts.create(bad[0], bad[1]); err == nil {
+      t.Fatalf("Expected an error creating %q with password %q", bad[0], bad[1])
+    }
+  }
+
+  if a, err := accounts.authenticate("ALICE", "correct horse"); err != nil || a.Username != "alice" {
+    t.Fatalf("Expected to log in as alice: %+v, %v", a, err)
+  }
+  if _, err := accounts.authenticate("alice", "wrong horse"); !errors.Is(err, ERR_BAD_CREDENTIALS) {
+    t.Fatalf("Expected bad credentials: %v", err)
+  }
+  if _, err := accounts.authenticate("bob", "correct horse"); !errors.Is(err, ERR_BAD_CREDENTIALS) {
+    t.Fatalf("Expected bad credentials: %v", err)
+  }
+
+  info, err := os.Stat(path)
+  if err != nil {
+    t.Fatal(err.Error())
+  }
+  if info.Mode().Perm() != 0600 {
+    t.Fatalf("Expected only the owner to read the accounts, got %v", info.Mode().Perm())
+  }
+  contents, err := os.ReadFile(path)
+  if err != nil {
+    t.Fatal(err.Error())
+  }
+  if strings.Contains(string(contents), "correct horse") {
+    t.Fatal("Password saved in plain text")
+  }
+}
+
+// Sends a JSON request, with a bearer token if one's given.
+func requestJson(t *testing.T, client *http.Client, method string, url string, token string, body string, expectedStatus int, v interface{}) {
+  req, err := http.NewRequest(method, url, strings.NewReader(body))
+  if err != nil {
+    t.Fatal(err.Error())
+  }
+  req.Header.Set("Content-Type", "application/json")
+  if token != "" {
+    req.Header.Set("Authorization", "Bearer " + token)
+  }
+  resp, err := client.Do(req)
+  if err != nil {
+    t.Fatal(err.Error())
+  }
+  defer resp.Body.Close()
+  if resp.StatusCode != expectedStatus {
+    t.Fatalf("%s %s %s: expected status %d, got %d", method, url, body, expectedStatus, resp.StatusCode)
+  }
+  if v != nil {
+    if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+      t.Fatal(err.Error())
+    }
+  }
+}
+
+func TestAccountHandlers(t *testing.T) {
+  fmt.Println("starting TestAccountHandlers")
+  _, visitedStates, _, _, _, err := solve(initGame(), DEFAULT_MAX_DEPTH)
+  if err != nil {
+    t.Fatal(err.Error())
+  }
+  graph := createSolveGraph(visitedStates, DEFAULT_MAX_DEPTH, slog.Default())
+  games := createFileGameStore(filepath.Join(t.TempDir(), "games.jsonl"))
+  auth := createAuthenticator(createFileAccountStore(filepath.Join(t.TempDir(), "accounts.jsonl")))
+  server := httptest.NewServer(createRouter(initGame(), graph, createSessionStore(games, slog.Default()), createLobbyStore(slog.Default()), auth, getFrontendHandler(embeddedFrontend(), false), slog.Default()))
+  defer server.Close()
+  client := server.Client()
+
+  var login loginResponse
+  requestJson(t, client, "POST", server.URL + "/accounts", "", `{"username": "alice", "password": "correct horse"}`, http.StatusCreated, &login)
+  if login.Username != "alice" || login.Token == "" {
+    t.Fatalf("Unexpected login: %+v", login)
+  }
+  requestJson(t, client, "POST", server.URL + "/accounts", "", `{"username": "alice", "password": "correct horse"}`, http.StatusConflict, nil)
+  requestJson(t, client, "POST", server.URL + "/login", "", `{"username": "alice", "password": "wrong horse"}`, http.StatusUnauthorized, nil)
+
+  var me accountView
+  requestJson(t, client, "GET", server.URL + "/me", login.Token, "", http.StatusOK, &me)
+  if me.Username != "alice" {
+    t.Fatalf("Unexpected account: %+v", me)
+  }
+  requestJson(t, client, "GET", server.URL + "/me", "", "", http.StatusUnauthorized, nil)
+  requestJson(t, client, "GET", server.URL + "/me", "not a token", "", http.StatusUnauthorized, nil)
+
+  // Games belong to whoever created them, and they play under their username.
+  var game gameSessionView
+  requestJson(t, client, "POST", server.URL + "/games", login.Token, `{"position": "10/40 w", "allowUnreachable": true, "name": "mallory"}`, http.StatusCreated, &game)
+  requestJson(t, client, "POST", server.URL + "/games/" + game.Id + "/move", "", `{"playerHand": "lh", "receiverHand": "lh"}`, http.StatusForbidden, nil)
+  requestJson(t, client, "POST", server.URL + "/games/" + game.Id + "/move", login.Token, `{"playerHand": "lh", "receiverHand": "lh"}`, http.StatusOK, nil)
+  // Guests can't take alice's name.
+  requestJson(t, client, "POST", server.URL + "/games", "", `{"name": "alice"}`, http.StatusBadRequest, nil)
+  requestJson(t, client, "POST", server.URL + "/lobbies", "", `{"name": "alice"}`, http.StatusBadRequest, nil)
+
+  var mine []gameRecordSummary
+  requestJson(t, client, "GET", server.URL + "/me/games", login.Token, "", http.StatusOK, &mine)
+  if len(mine) != 1 || mine[0].Id != game.Id || mine[0].Players["p1"] != "alice" {
+    t.Fatalf("Unexpected games: %+v", mine)
+  }
+
+  // Browsers get a cookie instead.
+  jar, err := cookiejar.New(nil)
+  if err != nil {
+    t.Fatal(err.Error())
+  }
+  browser := &http.Client{Jar: jar}
+  requestJson(t, browser, "POST", server.URL + "/login", "", `{"username": "alice", "password": "correct horse"}`, http.StatusOK, nil)
+  requestJson(t, browser, "GET", server.URL + "/me", "", "", http.StatusOK, &me)
+  requestJson(t, browser, "POST", server.URL + "/me/logout", "", "", http.StatusNoContent, nil)
+  requestJson(t, browser, "GET", server.URL + "/me", "", "", http.StatusUnauthorized, nil)
+  // Logging out of the browser doesn't end the other session.
+  requestJson(t, client, "GET", server.URL + "/me", login.Token, "", http.StatusOK, nil)
+}
+
+func TestAuthenticatorTokens(t *testing.T) {
+  fmt.Println("starting TestAuthenticatorTokens")
+  accounts := createFileAccountStore(filepath.Join(t.TempDir(), "accounts.jsonl"))
+  if _, err := accounts.create("alice", "correct horse"); err != nil {
+    t.Fatal(err.Error())
+  }
+  auth := createAuthenticator(accounts)
+  stale, err := auth.login("alice")
+  if err != nil {
+    t.Fatal(err.Error())
+  }
+  auth.tokens[stale] = authToken{"alice", time.Now().Add(-time.Minute)}
+  // Logging in clears out expired tokens, even ones nobody looks up again.
+  fresh, err := auth.login("alice")
+  if err != nil {
+    t.Fatal(err.Error())
+  }
+  if _, ok := auth.tokens[stale]; ok || len(auth.tokens) != 1 {
+    t.Fatalf("Expected the expired token to be swept: %+v", auth.tokens)
+  }
+  if username, ok := auth.lookup(fresh); !ok || username != "alice" {
+    t.Fatalf("Expected the new token to work: %s, %t", username, ok)
+  }
+
+  // The cookie is only sent back over https when the login came over https.
+  for _, secure := range []bool{false, true} {
+    req := httptest.NewRequest("POST", "/login", strings.NewReader(`{"username": "alice", "password": "correct horse"}`))
+    if secure {
+      req.Header.Set("X-Forwarded-Proto", "https")
+    }
+    w := httptest.NewRecorder()
+    getLoginHandler(auth).ServeHTTP(w, req)
+    cookies := w.Result().Cookies()
+    if w.Code != http.StatusOK || len(cookies) != 1 || cookies[0].Secure != secure {
+      t.Fatalf("Expected a cookie with Secure %t: %d %+v", secure, w.Code, cookies)
+    }
+  }
+}
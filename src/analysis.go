@@ -0,0 +1,298 @@
+package main
+
+import (
+  "errors"
+  "fmt"
+  "net/http"
+  "strings"
+
+  "github.com/gorilla/mux"
+)
+
+// Post-game analysis. The solver's scores say who wins, but not how fast, so analysis runs its own retrograde pass
+// over the solved graph: terminal states are losses for the Player to move, a state is a win in N+1 plies if some
+// Move leads to a loss in N for the opponent, and a loss in N+1 if every Move leads to a win for the opponent, the
+// slowest of which takes N plies. Whatever is left over can go on forever with best play, so it's a draw.
+
+// The game-theoretic value of a state for the Player to move.
+type positionEval struct {
+  Outcome GameOutcome
+  // Plies until the game ends with best play: the winner wins as fast as possible and the loser holds out as long as
+  // possible. 0 for draws.
+  Plies int
+}
+
+func (e positionEval) toString() string {
+  if e.Outcome == Draw {
+    return "draw"
+  }
+  return fmt.Sprintf("%s in %d", e.Outcome.toString(), e.Plies)
+}
+
+// The value for the Player who just moved into a state with the given value.
+func (e positionEval) forMover() positionEval {
+  if e.Outcome == Draw {
+    return e
+  }
+  return positionEval{-e.Outcome, e.Plies + 1}
+}
+
+// Whether a is better than b for the same Player: wins beat draws beat losses, faster wins beat slower ones, and
+// slower losses beat faster ones.
+func (a positionEval) isBetterThan(b positionEval) bool {
+  if a.Outcome != b.Outcome {
+    return a.Outcome > b.Outcome
+  }
+  if a.Outcome == Win {
+    return a.Plies < b.Plies
+  }
+  return a.Outcome == Loss && a.Plies > b.Plies
+}
+
+// Retrograde analysis over solved (normalized) states. States missing from the map, or that the solver stopped at
+// because of the depth cap, come out as draws.
+func evaluatePositions(states map[GameState]*PlayNode) map[GameState]positionEval {
+  evals := make(map[GameState]positionEval, len(states))
+  for state, node := range states {
+    if node.isTerminal() {
+      // Whoever is eliminated lost, and the game is over so it's their turn.
+      evals[state] = positionEval{Loss, 0}
+    }
+  }
+  for plies := 1; ; plies++ {
+    resolved := make(map[GameState]positionEval)
+    for state, node := range states {
+      if _, ok := evals[state]; ok || len(node.nextNodes) == 0 {
+        continue
+      }
+      allOpponentWins, slowestOpponentWin := true, 0
+      for _, next := range node.nextNodes {
+        nextEval, ok := evals[*next.gs]
+        if ok && nextEval.Outcome == Loss && nextEval.Plies == plies - 1 {
+          resolved[state] = positionEval{Win, plies}
+          break
+        }
+        if !ok || nextEval.Outcome != Win {
+          allOpponentWins = false
+        } else if nextEval.Plies > slowestOpponentWin {
+          slowestOpponentWin = nextEval.Plies
+        }
+      }
+      if _, won := resolved[state]; !won && allOpponentWins && slowestOpponentWin == plies - 1 {
+        resolved[state] = positionEval{Loss, plies}
+      }
+    }
+    // Every state resolved at N+1 plies needs a child resolved at N, so once a round finds nothing we're done.
+    if len(resolved) == 0 {
+      break
+    }
+    for state, eval := range resolved {
+      evals[state] = eval
+    }
+  }
+  for state := range states {
+    if _, ok := evals[state]; !ok {
+      evals[state] = positionEval{Draw, 0}
+    }
+  }
+  return evals
+}
+
+// The best Move from the node and its value for the Player making it.
+func bestMoveForEvals(node *PlayNode, evals map[GameState]positionEval) (Move, positionEval, error) {
+  if len(node.nextNodes) == 0 {
+    return Move{}, positionEval{}, errors.New("No moves from " + node.gs.toPositionString())
+  }
+  var best Move
+  var bestEval positionEval
+  first := true
+  for _, m := range sortedMoves(node.nextNodes) {
+    eval := evals[*node.nextNodes[m].gs].forMover()
+    if first || eval.isBetterThan(bestEval) {
+      best, bestEval, first = m, eval, false
+    }
+  }
+  return best, bestEval, nil
+}
+
+type moveAnalysis struct {
+  MoveNumber int
+  // The Player who moved, "p1" or "p2"
+  Player string
+  Move Move
+  Notation string
+  // The value of the position for the mover before the Move, and what the Move left them with.
+  Before string
+  After string
+  // "blunder" if the Move changed the outcome for the worse, "slower" if it's still a win but takes longer, empty
+  // otherwise.
+  Flag string `json:",omitempty"`
+  // The best Move instead, for flagged Moves
+  Best *Move `json:",omitempty"`
+  BestNotation string `json:",omitempty"`
+  Comment string
+}
+
+type gameAnalysis struct {
+  Id string
+  Players map[string]string
+  Result GameResult
+  Moves []moveAnalysis
+  // Number of flagged Moves per Player, keyed by "p1"/"p2"
+  Blunders map[string]int
+  SlowerWins map[string]int
+}
+
+func analyzeGame(record *GameRecord, graph *solveGraph) (*gameAnalysis, error) {
+  start, err := parsePosition(record.StartPosition)
+  if err != nil {
+    return nil, err
+  }
+  // Make sure every state of the game has been solved before taking the snapshot.
+  if _, err := graph.lookupOrSolve(start); err != nil {
+    return nil, err
+  }
+  for _, recorded := range record.Moves {
+    if checkGameResult(&recorded.State) == Ongoing {
+      if _, err := graph.lookupOrSolve(&recorded.State); err != nil {
+        return nil, err
+      }
+    }
+  }
+  snap := graph.snapshot()
+  evals := snap.evaluations()
+
+  analysis := &gameAnalysis{
+    record.Id, record.Players, record.Result, []moveAnalysis{}, map[string]int{"p1": 0, "p2": 0}, map[string]int{"p1": 0, "p2": 0},
+  }
+  gs := *start
+  for i, recorded := range record.Moves {
+    node, ok := snap.lookup(&gs)
+    if !ok {
+      return nil, errors.New("State missing from the solve graph: " + gs.toPositionString())
+    }
+    gsCopy := gs
+    gps := createGamePlayState(&gsCopy)
+    normalizedMove, err := gps.getNormalizedMoveForGameMove(recorded.Move)
+    if err != nil {
+      return nil, err
+    }
+    next, ok := node.nextNodes[normalizedMove]
+    if !ok {
+      return nil, fmt.Errorf("Move %s not found from %s", recorded.Move.toNotation(), gs.toPositionString())
+    }
+    before := evals[*node.gs]
+    after := evals[*next.gs].forMover()
+    bestNormalized, bestEval, err := bestMoveForEvals(node, evals)
+    if err != nil {
+      return nil, err
+    }
+    bestMove, err := gps.getGameMoveForNormalizedMove(bestNormalized)
+    if err != nil {
+      return nil, err
+    }
+
+    mover := turnToUiString(gs.T)
+    ma := moveAnalysis{
+      i + 1, mover, recorded.Move, recorded.Move.toNotation(), before.toString(), after.toString(), "", nil, "", "",
+    }
+    if after.Outcome < before.Outcome {
+      ma.Flag = "blunder"
+      analysis.Blunders[mover]++
+    } else if after.Outcome == Win && after.Plies > bestEval.Plies {
+      ma.Flag = "slower"
+      analysis.SlowerWins[mover]++
+    }
+    if ma.Flag != "" {
+      ma.Best, ma.BestNotation = &bestMove, bestMove.toNotation()
+    }
+    ma.Comment = commentOnAnalysis(&ma, before, after, bestEval)
+    analysis.Moves = append(analysis.Moves, ma)
+    gs = recorded.State
+  }
+  return analysis, nil
+}
+
+func commentOnAnalysis(ma *moveAnalysis, before positionEval, after positionEval, best positionEval) string {
+  switch ma.Flag {
+  case "blunder":
+    return fmt.Sprintf("%s is a blunder: turns a %s into a %s. %s keeps the %s.", ma.Notation, before.toString(),
+      after.toString(), ma.BestNotation, best.toString())
+  case "slower":
+    return fmt.Sprintf("%s wins slower: %s instead of %s with %s.", ma.Notation, after.toString(), best.toString(), ma.BestNotation)
+  default:
+    return fmt.Sprintf("%s leads to a %s.", ma.Notation, after.toString())
+  }
+}
+
+func (a *gameAnalysis) toString() string {
+  var sb strings.Builder
+  sb.WriteString(fmt.Sprintf("Analysis of %s vs %s (%s)\n", a.Players["p1"], a.Players["p2"], a.Result.toString()))
+  for _, ma := range a.Moves {
+    marker := "  "
+    if ma.Flag == "blunder" {
+      marker = "??"
+    } else if ma.Flag == "slower" {
+      marker = "?!"
+    }
+    sb.WriteString(fmt.Sprintf("%3d. %s %s %s %s\n", ma.MoveNumber, turnToString(uiStringToTurn(ma.Player)), ma.Notation, marker, ma.Comment))
+  }
+  for _, p := range []string{"p1", "p2"} {
+    sb.WriteString(fmt.Sprintf("%s: %d blunders, %d slower wins\n", turnToString(uiStringToTurn(p)), a.Blunders[p], a.SlowerWins[p]))
+  }
+  return sb.String()
+}
+
+func uiStringToTurn(p string) Turn {
+  if p == "p2" {
+    return Player2
+  }
+  return Player1
+}
+
+// ==== Handlers ====
+
+func writeAnalysis(w http.ResponseWriter, r *http.Request, record *GameRecord, graph *solveGraph) {
+  if record.Result == Ongoing {
+    http.Error(w, "the game isn't over yet", http.StatusConflict)
+    return
+  }
+  analysis, err := analyzeGame(record, graph)
+  if err != nil {
+    requestLogger(r).Error("Error analyzing game", "game", record.Id, "err", err)
+    http.Error(w, "can't analyze game", http.StatusInternalServerError)
+    return
+  }
+  writeJson(w, r, http.StatusOK, analysis)
+}
+
+// GET /records/{id}/analysis
+func getRecordAnalysisHandler(games GameStore, graph *solveGraph) http.Handler {
+  fn := func (w http.ResponseWriter, r *http.Request) {
+    id := mux.Vars(r)["id"]
+    record, ok, err := games.get(id)
+    if err != nil {
+      requestLogger(r).Error("Error fetching game", "game", id, "err", err)
+      http.Error(w, "can't fetch game", http.StatusInternalServerError)
+      return
+    }
+    if !ok {
+      http.Error(w, fmt.Sprintf("no saved game with id %s", id), http.StatusNotFound)
+      return
+    }
+    writeAnalysis(w, r, record, graph)
+  }
+  return http.HandlerFunc(fn)
+}
+
+// GET /games/{id}/analysis, for games that are over.
+func getGameAnalysisHandler(sessions *sessionStore, graph *solveGraph) http.Handler {
+  fn := func (w http.ResponseWriter, r *http.Request) {
+    session, ok := getSessionOr404(sessions, w, r)
+    if !ok {
+      return
+    }
+    writeAnalysis(w, r, session.recordCopy(), graph)
+  }
+  return http.HandlerFunc(fn)
+}
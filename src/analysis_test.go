@@ -0,0 +1,109 @@
+package main
+
+import (
+  "encoding/json"
+  "fmt"
+  "log/slog"
+  "net/http"
+  "testing"
+)
+
+func TestEvaluatePositionsIsConsistent(t *testing.T) {
+  fmt.Println("starting TestEvaluatePositionsIsConsistent")
+  _, visitedStates, _, _, _, err := solve(initGame(), DEFAULT_MAX_DEPTH)
+  if err != nil {
+    t.Fatal(err.Error())
+  }
+  evals := evaluatePositions(visitedStates)
+  if len(evals) != len(visitedStates) {
+    t.Fatalf("Expected an evaluation for every state: %d vs %d", len(evals), len(visitedStates))
+  }
+  if evals[*initGame()].Outcome != Draw {
+    t.Fatalf("Expected the start to be a draw: %+v", evals[*initGame()])
+  }
+  for state, node := range visitedStates {
+    eval := evals[state]
+    if node.isTerminal() {
+      if eval != (positionEval{Loss, 0}) {
+        t.Fatalf("Expected a loss in 0 for a terminal state: %s %+v", state.toPositionString(), eval)
+      }
+      continue
+    }
+    // Every state is worth whatever its best Move is worth.
+    _, bestEval, err := bestMoveForEvals(node, evals)
+    if err != nil {
+      t.Fatal(err.Error())
+    }
+    if bestEval != eval {
+      t.Fatalf("Evaluation of %s doesn't match its best move: %+v vs %+v", state.toPositionString(), eval, bestEval)
+    }
+    // Forced wins and losses should agree with the solver.
+    if eval.Outcome != Draw && scoreToOutcome(node.scoreForCurrentPlayer()) != eval.Outcome {
+      t.Fatalf("Evaluation of %s doesn't match the solver: %+v vs %f", state.toPositionString(), eval, node.scoreForCurrentPlayer())
+    }
+  }
+}
+
+func TestAnalyzeGame(t *testing.T) {
+  fmt.Println("starting TestAnalyzeGame")
+  record, err := parseNotation(REPLAY_TEST_GAME)
+  if err != nil {
+    t.Fatal(err.Error())
+  }
+  analysis, err := analyzeGame(record, createSolveGraph(make(map[GameState]*PlayNode), DEFAULT_MAX_DEPTH, slog.Default()))
+  if err != nil {
+    t.Fatal(err.Error())
+  }
+  if len(analysis.Moves) != len(record.Moves) {
+    t.Fatalf("Expected an analysis for every move: %+v", analysis)
+  }
+  // Player 2 throws away the draw on move 2, Player 1 misses a win in 1 on move 5.
+  blunder := analysis.Moves[1]
+  if blunder.Flag != "blunder" || blunder.Player != "p2" || blunder.Before != "draw" || blunder.Best == nil {
+    t.Fatalf("Expected a blunder on move 2: %+v", blunder)
+  }
+  slower := analysis.Moves[4]
+  if slower.Flag != "slower" || slower.Player != "p1" || slower.BestNotation != "L>R" {
+    t.Fatalf("Expected a slower win on move 5: %+v", slower)
+  }
+  if analysis.Blunders["p2"] != 1 || analysis.Blunders["p1"] != 0 || analysis.SlowerWins["p1"] != 1 {
+    t.Fatalf("Unexpected counts: %+v, %+v", analysis.Blunders, analysis.SlowerWins)
+  }
+  for _, i := range []int{0, 2, 6} {
+    if analysis.Moves[i].Flag != "" || analysis.Moves[i].Best != nil {
+      t.Fatalf("Didn't expect move %d to be flagged: %+v", i + 1, analysis.Moves[i])
+    }
+  }
+}
+
+func TestGameAnalysisHandler(t *testing.T) {
+  fmt.Println("starting TestGameAnalysisHandler")
+  server, _ := createTestServer(t)
+  defer server.Close()
+
+  // Player 1 wins in one from here, so the game is over after a single Move.
+  var game gameSessionView
+  postJson(t, server.URL + "/games", `{"position": "10/40 w", "side": "first", "allowUnreachable": true}`, http.StatusCreated, &game)
+  resp, err := http.Get(server.URL + "/games/" + game.Id + "/analysis")
+  if err != nil {
+    t.Fatal(err.Error())
+  }
+  resp.Body.Close()
+  if resp.StatusCode != http.StatusConflict {
+    t.Fatalf("Expected a conflict analyzing an unfinished game, got %d", resp.StatusCode)
+  }
+
+  postJson(t, server.URL + "/games/" + game.Id + "/move", `{"playerHand": "lh", "receiverHand": "lh"}`, http.StatusOK, nil)
+  resp, err = http.Get(server.URL + "/games/" + game.Id + "/analysis")
+  if err != nil {
+    t.Fatal(err.Error())
+  }
+  defer resp.Body.Close()
+  var analysis gameAnalysis
+  if err := json.NewDecoder(resp.Body).Decode(&analysis); err != nil {
+    t.Fatal(err.Error())
+  }
+  if len(analysis.Moves) != 1 || analysis.Result != Player1Wins || analysis.Moves[0].Before != "win in 1" || analysis.Moves[0].Flag != "" {
+    t.Fatalf("Unexpected analysis: %+v", analysis)
+  }
+}
@@ -1,9 +1,10 @@
 package main
 
 import (
-  "fmt"
-  "strings"
   "errors"
+  "fmt"
+  "math/rand"
+  "time"
 )
 
 
@@ -24,113 +25,280 @@ func checkGameResult(gs *GameState) GameResult {
   }
 }
 
-func stringInputToHand(i string) (Hand, error) {
-  if i == "LH" {
-    return Left, nil
-  } else if i == "RH" {
-    return Right, nil
-  } else {
-    return Left, errors.New("Invalid hand " + i + ", must be either LH or RH")
+// Which Player the human plays on the cli. Player 1 always moves first.
+func parseSide(side string) (Turn, error) {
+  switch side {
+  case "first", "1":
+    return Player1, nil
+  case "second", "2":
+    return Player2, nil
+  case "random":
+    if rand.New(rand.NewSource(time.Now().UnixNano())).Intn(2) == 0 {
+      return Player1, nil
+    }
+    return Player2, nil
+  default:
+    return Player1, errors.New("Invalid side " + side + ", must be one of first, second or random")
   }
 }
 
-func dumpTurnInfo(gsAfterPlay *GameState, nodeAfterPlay *PlayNode, nodeBeforePlay *PlayNode, guiMove Move, normalizedMove Move) error {
-   normalizedAfter := gsAfterPlay.copyAndNormalize()
-    if !normalizedAfter.equals(nodeAfterPlay.gs) {
-      return errors.New(fmt.Sprintf("Normalized GUI game state and solve tree game state do not match: %+v, %+v", normalizedAfter, nodeAfterPlay.gs))
-    }
-    fmt.Println("== Begin turn info dump ==")
-    fmt.Printf("previous node: %s\ncurrent node: %s\nnormalized Move: %+v, gui Move: %+v\n", nodeBeforePlay.toTreeString(1), nodeAfterPlay.toTreeString(1), normalizedMove, guiMove) 
-    fmt.Println("== End turn info dump ==")
-    return nil
+func turnToString(t Turn) string {
+  if t == Player1 {
+    return "Player 1"
+  } else {
+    return "Player 2"
+  }
 }
 
-func validateGpsAndNode(gps *gamePlayState, curNode *PlayNode) error {
-  if !gps.normalizedState.equals(curNode.gs) {
-    return errors.New(fmt.Sprintf("GPS normalized state and solve node state do not match: %+v, %+v", gps.normalizedState, curNode.gs))
+func invertTurn(t Turn) Turn {
+  if t == Player1 {
+    return Player2
   } else {
-    return nil
+    return Player1
   }
 }
 
+// Engines for each Player; a nil engine means the human at the keyboard plays that side.
+type cliPlayers map[Turn]Engine
 
-func runPlayerTurn(gps *gamePlayState, curNode *PlayNode) (*PlayNode, error) {
-  fmt.Println("Your turn.")
-  fmt.Println("What would you like to play?")
+func (players cliPlayers) isHuman(t Turn) bool {
+  return players[t] == nil
+}
 
-  // Player Move
-  var playerMoveStr string
-  // Format: LH RH 
-  fmt.Scanln(&playerMoveStr)
-  // NOTE: gs might not be the same as the gs value in the curNode due to normalization!!
-  playerMoveSlice := strings.Split(playerMoveStr, "->")
+// Stops any external engines.
+func (players cliPlayers) close() {
+  closeEngines([]Engine{players[Player1], players[Player2]})
+}
 
-  playerHand, err := stringInputToHand(playerMoveSlice[0]) 
-  if err != nil {
-    fmt.Printf("I don't recognize %s, please enter one of: LH->LH, LH->RH, RH->LH, RH->RH\n", playerMoveStr)
-    gps.state.prettyPrint()
-    return curNode, nil
+func (players cliPlayers) describe(t Turn) string {
+  if players.isHuman(t) {
+    return turnToString(t) + " (you)"
   }
-  receiverHand, err := stringInputToHand(playerMoveSlice[1]) 
-  if err != nil {
-    fmt.Printf("I don't recognize %s, please enter one of: LH->LH, LH->RH, RH->LH, RH->RH\n", playerMoveStr)
-    gps.state.prettyPrint()
-    return curNode, nil
+  return turnToString(t) + " (" + players[t].name() + ")"
+}
+
+// Who plays each side for the GameRecord. The human's games only count towards the ladder if they give a name.
+func (players cliPlayers) recordNames(humanName string) map[string]string {
+  if humanName == "" {
+    humanName = ANONYMOUS_PLAYER
   }
+  names := make(map[string]string, 2)
+  for _, t := range []Turn{Player1, Player2} {
+    if players.isHuman(t) {
+      names[turnToUiString(t)] = humanName
+    } else {
+      names[turnToUiString(t)] = players[t].name()
+    }
+  }
+  return names
+}
+
+// The game being played at the keyboard, shared by the repl and the tui: who plays, the Moves so far, and what the
+// solver thinks of the position.
+type cliGame struct {
+  players cliPlayers
+  // Solved from the starting position; positions loaded from files get solved on demand.
+  graph *solveGraph
+  humanName string
+  // Where finished games get saved, nil to not save them.
+  games GameStore
 
-  playerMove := Move{playerHand, receiverHand}
-  if !gps.state.isMoveValid(playerMove) {
-    // Print an error message and don't advance state.
-    fmt.Println("You can't do that, hands with zero fingers are out of play.")
-    gps.state.prettyPrint()
-    return curNode, nil
+  start *GameState
+  gps *gamePlayState
+  record *GameRecord
+  // Whether the end of the current game has been announced.
+  announced bool
+}
+
+func createCliGame(players cliPlayers, graph *solveGraph, start *GameState, humanName string, games GameStore) (*cliGame, error) {
+  g := &cliGame{players: players, graph: graph, humanName: humanName, games: games}
+  if err := g.newGame(start); err != nil {
+    return nil, err
   }
+  return g, nil
+}
 
-  fmt.Println("You played: " + playerMove.toString())
-  normalizedPlayerMove, err := gps.playGameTurn(playerMove)
+// Starts a new game from the given position, with the same Players.
+func (g *cliGame) newGame(start *GameState) error {
+  id, err := newSessionId()
   if err != nil {
-    return curNode, err
+    return err
   }
-  nodeAfterPlayer, okP := curNode.nextNodes[normalizedPlayerMove]
-  // NOTE: nodeAfterPlayer.gs and gsAfterPlayer may not be equal due to normalization differences, but they should
-  // be equal after normalizing
-  if !okP {
-    return curNode, errors.New(fmt.Sprintf("Normalized Player Move not found in curNode: %+v", curNode))
+  g.start = start
+  g.record = createGameRecord(id, start, g.players.recordNames(g.humanName))
+  stateCopy := *start
+  g.gps = createGamePlayState(&stateCopy)
+  g.announced = false
+  return nil
+}
+
+// Plays the game Moves from the start of the current game, e.g. to take some back.
+func (g *cliGame) replay(moves []Move) error {
+  record := g.record
+  g.record = createGameRecord(record.Id, g.start, record.Players)
+  stateCopy := *g.start
+  g.gps = createGamePlayState(&stateCopy)
+  g.announced = false
+  for _, m := range moves {
+    if checkGameResult(g.gps.state) != Ongoing || !g.gps.state.isMoveValid(m) {
+      return fmt.Errorf("Illegal move %s from %s", m.toNotation(), g.gps.state.toPositionString())
+    }
+    if err := g.playMove(m); err != nil {
+      return err
+    }
   }
-  if DEBUG {
-      if err := dumpTurnInfo(gps.state, nodeAfterPlayer, curNode, playerMove, normalizedPlayerMove); err != nil {
-        return curNode, err
-      }
+  return nil
+}
+
+func (g *cliGame) moves() []Move {
+  moves := make([]Move, len(g.record.Moves))
+  for i, recorded := range g.record.Moves {
+    moves[i] = recorded.Move
   }
-  gps.state.prettyPrint()
-  return nodeAfterPlayer, nil
+  return moves
 }
 
+func (g *cliGame) hasHuman() bool {
+  return g.players.isHuman(Player1) || g.players.isHuman(Player2)
+}
+
+// Whether the human plays against the computer, rather than against themselves.
+func (g *cliGame) againstComputer() bool {
+  return g.players.isHuman(Player1) != g.players.isHuman(Player2)
+}
+
+func (g *cliGame) currentNode() (*PlayNode, error) {
+  return g.graph.lookupOrSolve(g.gps.state)
+}
+
+func (g *cliGame) currentEvals() map[GameState]positionEval {
+  return g.graph.snapshot().evaluations()
+}
 
-func runComputerTurn(gps *gamePlayState, curNode *PlayNode) (*PlayNode, error) {
-  // Computer Move
-  // Need to normalize the guiGs in order to map the best Move onto the current GUI
-  normalizedComputerMove, _, err := curNode.getBestMoveAndScoreForCurrentPlayer(DEBUG, true) // TODO: don't allow unscored child?
+// Who wins from the current position with best play, for the Player to move.
+func (g *cliGame) currentEval() (positionEval, error) {
+  if _, err := g.currentNode(); err != nil {
+    return positionEval{}, err
+  }
+  return g.currentEvals()[*g.gps.state.copyAndNormalize()], nil
+}
+
+// Plays and records a valid game Move.
+func (g *cliGame) playMove(m Move) error {
+  if _, err := g.gps.playGameTurn(m); err != nil {
+    return err
+  }
+  g.record.addMove(m, g.gps.state)
+  return nil
+}
+
+// Asks the engine whose turn it is for a Move and plays it, returning it as a game Move.
+func (g *cliGame) playComputerTurn() (Move, error) {
+  node, err := g.currentNode()
   if err != nil {
-    return curNode, err
+    return Move{}, err
   }
+  engine := g.players[g.gps.state.T]
+  normalizedMove, err := engine.chooseMove(node)
+  if err != nil {
+    return Move{}, err
+  }
+  if _, ok := node.nextNodes[normalizedMove]; !ok {
+    return Move{}, fmt.Errorf("%s played a Move that isn't in the graph: %+v from %s", engine.name(), normalizedMove, node.toString())
+  }
+  gameMove, err := g.gps.playNormalizedTurn(normalizedMove)
+  if err != nil {
+    return Move{}, err
+  }
+  g.record.addMove(gameMove, g.gps.state)
+  return gameMove, nil
+}
 
-  guiComputerMove, err := gps.playNormalizedTurn(normalizedComputerMove)
+// The best game Move for whoever is to move, and what it leads to.
+func (g *cliGame) hint() (Move, positionEval, error) {
+  node, err := g.currentNode()
+  if err != nil {
+    return Move{}, positionEval{}, err
+  }
+  normalizedMove, eval, err := bestMoveForEvals(node, g.currentEvals())
   if err != nil {
-    return curNode, err
+    return Move{}, positionEval{}, err
   }
+  // Work out which hands the normalized Move is on a copy, so the game doesn't move.
+  stateCopy := *g.gps.state
+  gameMove, err := createGamePlayState(&stateCopy).playNormalizedTurn(normalizedMove)
+  if err != nil {
+    return Move{}, positionEval{}, err
+  }
+  return gameMove, eval, nil
+}
 
-  fmt.Println("I'll play: " + guiComputerMove.toString())
+// Takes back Moves until it's a human's turn again, so the computer's reply goes too. Returns how many Moves were
+// taken back, 0 if none of them were the human's.
+func (g *cliGame) undo() (int, error) {
+  moves := g.moves()
+  played := len(moves)
+  mover := g.gps.state.T
+  for len(moves) > 0 {
+    moves = moves[:len(moves) - 1]
+    mover = invertTurn(mover)
+    if g.players.isHuman(mover) {
+      return played - len(moves), g.replay(moves)
+    }
+  }
+  return 0, nil
+}
 
-  nodeAfterComputer, okC := curNode.nextNodes[normalizedComputerMove]
-  if !okC {
-    return curNode, errors.New(fmt.Sprintf("Computer Move not found in curNode: %+v", curNode))
+// Carries on from the end of the record, as a new game with the current Players.
+func (g *cliGame) loadRecord(record *GameRecord) error {
+  start, err := parsePosition(record.StartPosition)
+  if err != nil {
+    return err
   }
-  if DEBUG {
-    if err := dumpTurnInfo(gps.state, nodeAfterComputer, curNode, guiComputerMove, normalizedComputerMove); err != nil {
-      return curNode, err
-    }
+  if err := validatePosition(start); err != nil {
+    return err
+  }
+  if _, err := g.graph.lookupOrSolve(start); err != nil {
+    return err
+  }
+  moves := make([]Move, len(record.Moves))
+  for i, recorded := range record.Moves {
+    moves[i] = recorded.Move
+  }
+  previousStart, previousMoves := g.start, g.moves()
+  if err := g.newGame(start); err != nil {
+    return err
+  }
+  if err := g.replay(moves); err != nil {
+    // Go back to the game we were playing.
+    g.start = previousStart
+    g.replay(previousMoves)
+    return err
+  }
+  return nil
+}
+
+// Who won, from the human's point of view when they played the computer.
+func (g *cliGame) winnerMessage(result GameResult) string {
+  winner := Player1
+  if result == Player2Wins {
+    winner = Player2
+  }
+  if g.againstComputer() && g.players.isHuman(winner) {
+    return "You win!"
+  } else if g.againstComputer() {
+    return "I win!"
+  }
+  return g.players.describe(winner) + " wins!"
+}
+
+// Saves the finished game, if there's somewhere to save it. Returns whether it was saved.
+func (g *cliGame) saveFinished() (bool, error) {
+  if g.games == nil {
+    return false, nil
+  }
+  if err := g.games.save(g.record); err != nil {
+    return false, err
   }
-  gps.state.prettyPrint()
-  return nodeAfterComputer, nil
+  return true, nil
 }
@@ -0,0 +1,86 @@
+package main
+
+import (
+  "fmt"
+)
+
+// The commentator grades each Move against the solve graph, for humans playing each other.
+
+type GameOutcome int8
+const (
+  Loss GameOutcome = iota - 1
+  Draw
+  Win
+)
+
+func (o GameOutcome) toString() string {
+  switch o {
+  case Win:
+    return "win"
+  case Loss:
+    return "loss"
+  default:
+    return "draw"
+  }
+}
+
+// Scores for the current Player close to +/-1 are forced wins/losses; anything else (e.g. heuristic scores of nodes
+// stuck in loops) is treated as a draw.
+func scoreToOutcome(scoreForCurrentPlayer float32) GameOutcome {
+  if scoreForCurrentPlayer > 0.9 {
+    return Win
+  } else if scoreForCurrentPlayer < -0.9 {
+    return Loss
+  } else {
+    return Draw
+  }
+}
+
+type moveComment struct {
+  // best, inaccuracy, mistake or blunder
+  Quality string
+  Comment string
+}
+
+// Grade the game Move played from the given (game, not normalized) state.
+func commentOnMove(graph *solveGraph, before *GameState, gameMove Move) (*moveComment, error) {
+  node, err := graph.lookupOrSolve(before)
+  if err != nil {
+    return nil, err
+  }
+  beforeCopy := *before
+  gps := createGamePlayState(&beforeCopy)
+  normalizedMove, err := gps.getNormalizedMoveForGameMove(gameMove)
+  if err != nil {
+    return nil, err
+  }
+  playedNode, ok := node.nextNodes[normalizedMove]
+  if !ok {
+    return nil, fmt.Errorf("Move %+v not found in node %s", normalizedMove, node.toString())
+  }
+  bestMove, bestScore, err := node.getBestMoveAndScoreForCurrentPlayer(nil, true)
+  if err != nil {
+    return nil, err
+  }
+  bestGameMove, err := gps.getGameMoveForNormalizedMove(bestMove)
+  if err != nil {
+    return nil, err
+  }
+  // The score of the next node is for the opponent, flip it to get the score for the mover.
+  playedScore := -playedNode.scoreForCurrentPlayer()
+  bestOutcome, playedOutcome := scoreToOutcome(bestScore), scoreToOutcome(playedScore)
+
+  mover := turnToString(before.T)
+  if playedScore >= bestScore {
+    return &moveComment{"best", fmt.Sprintf("%s played %s, the best move (%s).", mover, gameMove.toString(), playedOutcome.toString())}, nil
+  }
+  if playedOutcome == bestOutcome {
+    return &moveComment{"inaccuracy", fmt.Sprintf("%s played %s, an inaccuracy. %s was better.", mover, gameMove.toString(), bestGameMove.toString())}, nil
+  }
+  quality := "mistake"
+  if playedOutcome == Loss {
+    quality = "blunder"
+  }
+  return &moveComment{quality, fmt.Sprintf("%s played %s, a %s: turns a %s into a %s. %s was better.",
+    mover, gameMove.toString(), quality, bestOutcome.toString(), playedOutcome.toString(), bestGameMove.toString())}, nil
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+  "fmt"
+  "log/slog"
+  "testing"
+)
+
+func TestCommentOnMove(t *testing.T) {
+  fmt.Println("starting TestCommentOnMove")
+  _, visitedStates, _, _, _, err := solve(initGame(), DEFAULT_MAX_DEPTH)
+  if err != nil {
+    t.Fatal(err.Error())
+  }
+  graph := createSolveGraph(visitedStates, DEFAULT_MAX_DEPTH, slog.Default())
+
+  // Player 1 wins by taking Player 2's last hand, anything else lets Player 2 win.
+  gs := GameState{Player{1, 0}, Player{4, 0}, Player1}
+  comment, err := commentOnMove(graph, &gs, Move{Left, Left})
+  if err != nil {
+    t.Fatal(err.Error())
+  }
+  if comment.Quality != "best" {
+    t.Fatalf("Expected the winning move to be best: %+v", comment)
+  }
+
+  if _, err := commentOnMove(graph, &gs, Move{Right, Left}); err == nil {
+    t.Fatal("Expected an error commenting on an illegal move")
+  }
+}
+
+func TestCommentOnBlunder(t *testing.T) {
+  fmt.Println("starting TestCommentOnBlunder")
+  _, visitedStates, _, _, _, err := solve(initGame(), DEFAULT_MAX_DEPTH)
+  if err != nil {
+    t.Fatal(err.Error())
+  }
+  graph := createSolveGraph(visitedStates, DEFAULT_MAX_DEPTH, slog.Default())
+  // Look for a position with a winning Move and a losing one, and check the losing one is called a blunder.
+  for _, node := range visitedStates {
+    if len(node.nextNodes) == 0 {
+      continue
+    }
+    _, bestScore, err := node.getBestMoveAndScoreForCurrentPlayer(nil, true)
+    if err != nil {
+      t.Fatal(err.Error())
+    }
+    if scoreToOutcome(bestScore) != Win {
+      continue
+    }
+    for m, next := range node.nextNodes {
+      if scoreToOutcome(-next.scoreForCurrentPlayer()) != Loss {
+        continue
+      }
+      gs := *node.gs
+      comment, err := commentOnMove(graph, &gs, m)
+      if err != nil {
+        t.Fatal(err.Error())
+      }
+      if comment.Quality != "blunder" {
+        t.Fatalf("Expected a blunder for %+v from %s: %+v", m, node.toString(), comment)
+      }
+      return
+    }
+  }
+  t.Fatal("No position with both a winning and a losing move")
+}
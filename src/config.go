@@ -0,0 +1,309 @@
+package main
+
+import (
+  "encoding/json"
+  "errors"
+  "fmt"
+  "net"
+  "os"
+  "path/filepath"
+  "strings"
+  "time"
+
+  "github.com/urfave/cli"
+)
+
+// Settings for the server and the solver. Each one comes from the first of: its command line flag, its CHOPSTICKS_*
+// environment variable, the JSON config file, or the default. Everything is checked before any command runs.
+
+const DEFAULT_LISTEN_ADDRESS = ":8888"
+// Relative to the root of the repo, where the Makefile runs the server from.
+const DEFAULT_ASSET_DIR = "src/frontend"
+const DEFAULT_LOG_LEVEL = "info"
+const DEFAULT_SOLVER = "loops"
+const DEFAULT_FINGERS = 5
+const DEFAULT_READ_TIMEOUT = 30 * time.Second
+// Game events stream for as long as someone watches, so writes aren't limited unless asked.
+const DEFAULT_WRITE_TIMEOUT = 0
+const DEFAULT_IDLE_TIMEOUT = 2 * time.Minute
+const DEFAULT_SHUTDOWN_TIMEOUT = 30 * time.Second
+const DEFAULT_ENGINE_PROCESSES = 4
+// Positions write each hand as a single digit.
+const MAX_FINGERS = 10
+var SOLVER_ALGORITHMS []string = []string{"loops", "simple"}
+var LOG_LEVELS []string = []string{"debug", "info", "warn", "error"}
+
+type config struct {
+  // Address the server listens on, like ":8888" or "localhost:8080".
+  Listen string `json:"listen"`
+  // Serve the frontend from AssetDir rather than the copy built into the binary, to see edits without rebuilding.
+  Dev bool `json:"dev"`
+  // Directory with index.html and static/, for dev mode.
+  AssetDir string `json:"asset_dir"`
+  // The rules: a hand with this many fingers is out of play.
+  Fingers int `json:"fingers"`
+  // How deep the solver explores before giving up on a line and scoring it with the heuristic.
+  MaxDepth int `json:"max_depth"`
+  // loops works out the exact score of positions that can repeat; simple gives them heuristic scores instead.
+  Solver string `json:"solver"`
+  // debug, info, warn or error.
+  LogLevel string `json:"log_level"`
+  // text, or json for log collectors.
+  LogFormat string `json:"log_format"`
+  // File to load the solved graph from, or to save it to the first time. Empty to solve on every start.
+  Tablebase string `json:"tablebase"`
+  // Longest the server waits for a request to be read, 0 for no limit.
+  ReadTimeout duration `json:"read_timeout"`
+  // Longest the server takes to write a response, 0 for no limit.
+  WriteTimeout duration `json:"write_timeout"`
+  // How long idle keep-alive connections stay open.
+  IdleTimeout duration `json:"idle_timeout"`
+  // How long requests in flight get to finish when the server is stopped.
+  ShutdownTimeout duration `json:"shutdown_timeout"`
+  // Most processes each external engine runs, i.e. how many games it works out Moves for at once.
+  EngineProcesses int `json:"engine_processes"`
+}
+
+// A time.Duration written like "30s" or "2m" in the config file.
+type duration struct {
+  time.Duration
+}
+
+func (d *duration) UnmarshalJSON(b []byte) error {
+  var s string
+  if err := json.Unmarshal(b, &s); err != nil {
+    return fmt.Errorf("Bad duration %s, expected a string like \"30s\"", b)
+  }
+  parsed, err := time.ParseDuration(s)
+  if err != nil {
+    return err
+  }
+  d.Duration = parsed
+  return nil
+}
+
+func (d duration) MarshalJSON() ([]byte, error) {
+  return json.Marshal(d.String())
+}
+
+// The settings in effect, set once at startup.
+var CONFIG config = defaultConfig()
+
+func defaultConfig() config {
+  return config{DEFAULT_LISTEN_ADDRESS, false, DEFAULT_ASSET_DIR, DEFAULT_FINGERS, DEFAULT_MAX_DEPTH, DEFAULT_SOLVER, DEFAULT_LOG_LEVEL, DEFAULT_LOG_FORMAT, "",
+    duration{DEFAULT_READ_TIMEOUT}, duration{DEFAULT_WRITE_TIMEOUT}, duration{DEFAULT_IDLE_TIMEOUT}, duration{DEFAULT_SHUTDOWN_TIMEOUT},
+    DEFAULT_ENGINE_PROCESSES,
+  }
+}
+
+// Flags every command takes, before the command name, e.g. chopsticks --max-depth 100 serve.
+var CONFIG_FLAGS []cli.Flag = []cli.Flag{
+  cli.StringFlag{
+    Name: "config",
+    EnvVar: "CHOPSTICKS_CONFIG",
+    Usage: "JSON file with settings, keyed by the flag names with underscores, e.g. {\"max_depth\": 100}",
+  },
+  cli.IntFlag{
+    Name: "fingers",
+    EnvVar: "CHOPSTICKS_FINGERS",
+    Usage: fmt.Sprintf("the rules: hands with this many fingers are out of play (default %d)", DEFAULT_FINGERS),
+  },
+  cli.IntFlag{
+    Name: "max-depth",
+    EnvVar: "CHOPSTICKS_MAX_DEPTH",
+    Usage: fmt.Sprintf("how deep the solver explores (default %d)", DEFAULT_MAX_DEPTH),
+  },
+  cli.StringFlag{
+    Name: "solver",
+    EnvVar: "CHOPSTICKS_SOLVER",
+    Usage: fmt.Sprintf("how the solver scores positions that can repeat: %s (default %s)", strings.Join(SOLVER_ALGORITHMS, ", "), DEFAULT_SOLVER),
+  },
+  cli.StringFlag{
+    Name: "log-level",
+    EnvVar: "CHOPSTICKS_LOG_LEVEL",
+    Usage: fmt.Sprintf("how much to log: %s (default %s)", strings.Join(LOG_LEVELS, ", "), DEFAULT_LOG_LEVEL),
+  },
+  cli.StringFlag{
+    Name: "log-format",
+    EnvVar: "CHOPSTICKS_LOG_FORMAT",
+    Usage: fmt.Sprintf("how to write logs to stderr: %s (default %s)", strings.Join(LOG_FORMATS, ", "), DEFAULT_LOG_FORMAT),
+  },
+  cli.StringFlag{
+    Name: "tablebase",
+    EnvVar: "CHOPSTICKS_TABLEBASE",
+    Usage: "file to load the solved game from, solving it and saving it there if it doesn't exist yet",
+  },
+  cli.IntFlag{
+    Name: "engine-processes",
+    EnvVar: "CHOPSTICKS_ENGINE_PROCESSES",
+    Usage: fmt.Sprintf("most processes each external engine runs at once; more games wait for one (default %d)", DEFAULT_ENGINE_PROCESSES),
+  },
+}
+
+var LISTEN_FLAG = cli.StringFlag{
+  Name: "listen",
+  EnvVar: "CHOPSTICKS_LISTEN",
+  Usage: "address to listen on (default " + DEFAULT_LISTEN_ADDRESS + ")",
+}
+
+var DEV_FLAG = cli.BoolFlag{
+  Name: "dev",
+  EnvVar: "CHOPSTICKS_DEV",
+  Usage: "serve the frontend from --asset-dir instead of the copy built in, so edits show up on reload",
+}
+
+var ASSET_DIR_FLAG = cli.StringFlag{
+  Name: "asset-dir",
+  EnvVar: "CHOPSTICKS_ASSET_DIR",
+  Usage: "directory with the frontend for --dev (default " + DEFAULT_ASSET_DIR + ")",
+}
+
+var TIMEOUT_FLAGS []cli.Flag = []cli.Flag{
+  cli.DurationFlag{
+    Name: "read-timeout",
+    EnvVar: "CHOPSTICKS_READ_TIMEOUT",
+    Usage: fmt.Sprintf("longest the server waits to read a request, 0 for no limit (default %s)", DEFAULT_READ_TIMEOUT),
+  },
+  cli.DurationFlag{
+    Name: "write-timeout",
+    EnvVar: "CHOPSTICKS_WRITE_TIMEOUT",
+    Usage: "longest the server takes to write a response, 0 for no limit; game events stop streaming after it (default 0)",
+  },
+  cli.DurationFlag{
+    Name: "idle-timeout",
+    EnvVar: "CHOPSTICKS_IDLE_TIMEOUT",
+    Usage: fmt.Sprintf("how long idle connections stay open (default %s)", DEFAULT_IDLE_TIMEOUT),
+  },
+  cli.DurationFlag{
+    Name: "shutdown-timeout",
+    EnvVar: "CHOPSTICKS_SHUTDOWN_TIMEOUT",
+    Usage: fmt.Sprintf("how long requests get to finish when the server is stopped (default %s)", DEFAULT_SHUTDOWN_TIMEOUT),
+  },
+}
+
+// The defaults overridden by the file, keys it doesn't mention keep their defaults. Unknown keys are errors, so typos
+// don't go unnoticed.
+func readConfigFile(path string) (config, error) {
+  cfg := defaultConfig()
+  f, err := os.Open(path)
+  if err != nil {
+    return cfg, err
+  }
+  defer f.Close()
+  decoder := json.NewDecoder(f)
+  decoder.DisallowUnknownFields()
+  if err := decoder.Decode(&cfg); err != nil {
+    return cfg, fmt.Errorf("Bad config file %s: %w", path, err)
+  }
+  return cfg, nil
+}
+
+// Overrides settings with the flags that were given, on the command line or through their environment variables.
+// Flags that c doesn't have are left alone.
+func (cfg *config) applyFlags(c *cli.Context) {
+  for flag, setting := range map[string]*string{
+    "listen": &cfg.Listen, "asset-dir": &cfg.AssetDir, "solver": &cfg.Solver, "log-level": &cfg.LogLevel,
+    "log-format": &cfg.LogFormat, "tablebase": &cfg.Tablebase,
+  } {
+    if c.IsSet(flag) {
+      *setting = c.String(flag)
+    }
+  }
+  for flag, setting := range map[string]*int{"fingers": &cfg.Fingers, "max-depth": &cfg.MaxDepth, "engine-processes": &cfg.EngineProcesses} {
+    if c.IsSet(flag) {
+      *setting = c.Int(flag)
+    }
+  }
+  for flag, setting := range map[string]*time.Duration{
+    "read-timeout": &cfg.ReadTimeout.Duration, "write-timeout": &cfg.WriteTimeout.Duration,
+    "idle-timeout": &cfg.IdleTimeout.Duration, "shutdown-timeout": &cfg.ShutdownTimeout.Duration,
+  } {
+    if c.IsSet(flag) {
+      *setting = c.Duration(flag)
+    }
+  }
+  if c.IsSet("dev") {
+    cfg.Dev = c.Bool("dev")
+  }
+}
+
+func configFromFlags(c *cli.Context) (config, error) {
+  cfg := defaultConfig()
+  if path := c.String("config"); path != "" {
+    var err error
+    if cfg, err = readConfigFile(path); err != nil {
+      return cfg, err
+    }
+  }
+  cfg.applyFlags(c)
+  return cfg, cfg.validate()
+}
+
+func isOneOf(value string, values []string) bool {
+  for _, v := range values {
+    if v == value {
+      return true
+    }
+  }
+  return false
+}
+
+// Checks the settings every command uses. The server's own settings are checked by validateServer.
+func (cfg config) validate() error {
+  if cfg.Fingers < 2 || cfg.Fingers > MAX_FINGERS {
+    return fmt.Errorf("Bad fingers %d, must be between 2 and %d", cfg.Fingers, MAX_FINGERS)
+  }
+  if cfg.MaxDepth < 1 {
+    return fmt.Errorf("Bad max depth %d, must be positive", cfg.MaxDepth)
+  }
+  if !isOneOf(cfg.Solver, SOLVER_ALGORITHMS) {
+    return fmt.Errorf("Unknown solver %s, must be one of: %s", cfg.Solver, strings.Join(SOLVER_ALGORITHMS, ", "))
+  }
+  if !isOneOf(cfg.LogLevel, LOG_LEVELS) {
+    return fmt.Errorf("Unknown log level %s, must be one of: %s", cfg.LogLevel, strings.Join(LOG_LEVELS, ", "))
+  }
+  if !isOneOf(cfg.LogFormat, LOG_FORMATS) {
+    return fmt.Errorf("Unknown log format %s, must be one of: %s", cfg.LogFormat, strings.Join(LOG_FORMATS, ", "))
+  }
+  if cfg.EngineProcesses < 1 {
+    return fmt.Errorf("Bad engine processes %d, must be positive", cfg.EngineProcesses)
+  }
+  if cfg.Tablebase != "" {
+    if info, err := os.Stat(filepath.Dir(cfg.Tablebase)); err != nil || !info.IsDir() {
+      return fmt.Errorf("Bad tablebase %s, its directory doesn't exist", cfg.Tablebase)
+    }
+  }
+  return nil
+}
+
+func (cfg config) validateServer() error {
+  if _, _, err := net.SplitHostPort(cfg.Listen); err != nil {
+    return fmt.Errorf("Bad listen address %q: %w", cfg.Listen, err)
+  }
+  if _, err := os.Stat(filepath.Join(cfg.AssetDir, "index.html")); cfg.Dev && err != nil {
+    return errors.New("Bad asset dir " + cfg.AssetDir + ", it has no index.html")
+  }
+  for name, timeout := range map[string]time.Duration{
+    "read": cfg.ReadTimeout.Duration, "write": cfg.WriteTimeout.Duration, "idle": cfg.IdleTimeout.Duration,
+  } {
+    if timeout < 0 {
+      return fmt.Errorf("Bad %s timeout %s, must not be negative", name, timeout)
+    }
+  }
+  if cfg.ShutdownTimeout.Duration <= 0 {
+    return fmt.Errorf("Bad shutdown timeout %s, must be positive", cfg.ShutdownTimeout.Duration)
+  }
+  return nil
+}
+
+// Makes the settings take effect.
+func applyConfig(cfg config) {
+  CONFIG = cfg
+  // validate has checked the level.
+  level, _ := parseLogLevel(cfg.LogLevel)
+  LOGGER = configureLogging(os.Stderr, level, cfg.LogFormat)
+  if int8(cfg.Fingers) != NUM_FINGERS {
+    setNumFingers(int8(cfg.Fingers))
+  }
+  useSimpleScore = cfg.Solver == "simple"
+}
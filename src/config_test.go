@@ -0,0 +1,122 @@
+package main
+
+import (
+  "fmt"
+  "os"
+  "path/filepath"
+  "testing"
+  "time"
+
+  "github.com/urfave/cli"
+)
+
+// Parses the global flags the way main does and returns the settings.
+func configForArgs(t *testing.T, args ...string) (config, error) {
+  var cfg config
+  var cfgErr error
+  app := cli.NewApp()
+  app.Flags = CONFIG_FLAGS
+  app.Action = func(c *cli.Context) error {
+    cfg, cfgErr = configFromFlags(c)
+    return nil
+  }
+  if err := app.Run(append([]string{"chopsticks"}, args...)); err != nil {
+    t.Fatal(err.Error())
+  }
+  return cfg, cfgErr
+}
+
+func TestConfigPrecedence(t *testing.T) {
+  fmt.Println("starting TestConfigPrecedence")
+  cfg, err := configForArgs(t)
+  if err != nil || cfg != defaultConfig() {
+    t.Fatalf("Expected the defaults: %+v, %v", cfg, err)
+  }
+
+  path := filepath.Join(t.TempDir(), "chopsticks.json")
+  if err := os.WriteFile(path, []byte(`{"max_depth": 100, "solver": "simple", "log_level": "warn"}`), 0644); err != nil {
+    t.Fatal(err.Error())
+  }
+  t.Setenv("CHOPSTICKS_MAX_DEPTH", "90")
+  t.Setenv("CHOPSTICKS_LOG_LEVEL", "debug")
+  cfg, err = configForArgs(t, "--config", path, "--max-depth", "80")
+  if err != nil {
+    t.Fatal(err.Error())
+  }
+  // The flag beats the environment, which beats the file, which beats the defaults.
+  if cfg.MaxDepth != 80 || cfg.LogLevel != "debug" || cfg.Solver != "simple" || cfg.Fingers != DEFAULT_FINGERS {
+    t.Fatalf("Unexpected settings: %+v", cfg)
+  }
+}
+
+func TestConfigValidation(t *testing.T) {
+  fmt.Println("starting TestConfigValidation")
+  for _, args := range [][]string{
+    {"--fingers", "1"}, {"--fingers", "11"}, {"--max-depth", "0"}, {"--engine-processes", "0"}, {"--solver", "fancy"}, {"--log-level", "loud"},
+    {"--log-format", "xml"}, {"--tablebase", "/nonexistent/tablebase.json"},
+    {"--config", "/nonexistent/chopsticks.json"},
+  } {
+    if _, err := configForArgs(t, args...); err == nil {
+      t.Fatalf("Expected an error for %v", args)
+    }
+  }
+
+  path := filepath.Join(t.TempDir(), "chopsticks.json")
+  if err := os.WriteFile(path, []byte(`{"max_dept": 100}`), 0644); err != nil {
+    t.Fatal(err.Error())
+  }
+  if _, err := readConfigFile(path); err == nil {
+    t.Fatal("Expected an unknown key to be an error")
+  }
+
+  // The asset dir only matters in dev mode.
+  cfg := defaultConfig()
+  cfg.AssetDir = t.TempDir()
+  if err := cfg.validateServer(); err != nil {
+    t.Fatal(err.Error())
+  }
+  cfg.Dev, cfg.AssetDir = true, "frontend"
+  if err := cfg.validateServer(); err != nil {
+    t.Fatal(err.Error())
+  }
+  for _, bad := range []config{{Listen: "8888", AssetDir: cfg.AssetDir}, {Listen: ":8888", Dev: true, AssetDir: t.TempDir()}} {
+    if err := bad.validateServer(); err == nil {
+      t.Fatalf("Expected an error for %+v", bad)
+    }
+  }
+}
+
+func TestConfigTimeouts(t *testing.T) {
+  fmt.Println("starting TestConfigTimeouts")
+  path := filepath.Join(t.TempDir(), "chopsticks.json")
+  if err := os.WriteFile(path, []byte(`{"read_timeout": "5s", "shutdown_timeout": "1m"}`), 0644); err != nil {
+    t.Fatal(err.Error())
+  }
+  cfg, err := readConfigFile(path)
+  if err != nil {
+    t.Fatal(err.Error())
+  }
+  if cfg.ReadTimeout.Duration != 5 * time.Second || cfg.ShutdownTimeout.Duration != time.Minute ||
+    cfg.IdleTimeout.Duration != DEFAULT_IDLE_TIMEOUT {
+    t.Fatalf("Unexpected timeouts: %+v", cfg)
+  }
+
+  for _, bad := range []string{`{"read_timeout": 5}`, `{"idle_timeout": "soon"}`} {
+    if err := os.WriteFile(path, []byte(bad), 0644); err != nil {
+      t.Fatal(err.Error())
+    }
+    if _, err := readConfigFile(path); err == nil {
+      t.Fatalf("Expected an error for %s", bad)
+    }
+  }
+  cfg = defaultConfig()
+  cfg.WriteTimeout.Duration = -time.Second
+  if err := cfg.validateServer(); err == nil {
+    t.Fatal("Expected a negative timeout to be an error")
+  }
+  cfg = defaultConfig()
+  cfg.ShutdownTimeout.Duration = 0
+  if err := cfg.validateServer(); err == nil {
+    t.Fatal("Expected a zero shutdown timeout to be an error")
+  }
+}
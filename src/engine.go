@@ -0,0 +1,92 @@
+package main
+
+import (
+  "fmt"
+  "log/slog"
+  "math/rand"
+  "sort"
+  "strings"
+  "sync"
+  "time"
+)
+
+// Engines pick Moves for computer Players. They always work on normalized nodes of the solve graph; translating
+// the chosen Move back to the hands the Player sees is the job of the gamePlayState.
+type Engine interface {
+  name() string
+  // Returns the normalized Move to play from the given node.
+  chooseMove(node *PlayNode) (Move, error)
+}
+
+// Difficulty levels are the solver with a chance of playing a random Move instead of the best one.
+var DIFFICULTY_NAMES []string = []string{"perfect", "hard", "medium", "easy", "random"}
+var DIFFICULTY_MISTAKE_RATES map[string]float64 = map[string]float64{
+  "perfect": 0,
+  "hard": 0.15,
+  "medium": 0.35,
+  "easy": 0.6,
+  "random": 1,
+}
+const DEFAULT_DIFFICULTY string = "perfect"
+
+type solverEngine struct {
+  difficulty string
+  mistakeRate float64
+  // rand.Rand is not safe for concurrent use, and the server shares engines between requests.
+  mu sync.Mutex
+  rng *rand.Rand
+}
+
+func createSolverEngine(difficulty string, seed int64) (*solverEngine, error) {
+  mistakeRate, ok := DIFFICULTY_MISTAKE_RATES[difficulty]
+  if !ok {
+    return nil, fmt.Errorf("Unknown difficulty %s, must be one of: %s", difficulty, strings.Join(DIFFICULTY_NAMES, ", "))
+  }
+  return &solverEngine{difficulty, mistakeRate, sync.Mutex{}, rand.New(rand.NewSource(seed))}, nil
+}
+
+// Parse a difficulty name into an engine seeded from the clock.
+func engineForDifficulty(difficulty string) (Engine, error) {
+  if difficulty == "" {
+    difficulty = DEFAULT_DIFFICULTY
+  }
+  return createSolverEngine(difficulty, time.Now().UnixNano())
+}
+
+func (e *solverEngine) name() string {
+  return e.difficulty
+}
+
+func (e *solverEngine) chooseMove(node *PlayNode) (Move, error) {
+  if len(node.nextNodes) == 0 {
+    return Move{}, fmt.Errorf("No Moves available from %s", node.gs.toString())
+  }
+  e.mu.Lock()
+  makeMistake := e.mistakeRate > 0 && e.rng.Float64() < e.mistakeRate
+  var randomIdx int
+  if makeMistake {
+    randomIdx = e.rng.Intn(len(node.nextNodes))
+  }
+  e.mu.Unlock()
+
+  if makeMistake {
+    return sortedMoves(node.nextNodes)[randomIdx], nil
+  }
+  bestMove, _, err := node.getBestMoveAndScoreForCurrentPlayer(slog.Default(), true) // TODO: don't allow unscored child?
+  return bestMove, err
+}
+
+// Map iteration order is random, so sort the Moves to make seeded engines deterministic.
+func sortedMoves(nodeMap map[Move]*PlayNode) []Move {
+  moves := make([]Move, 0, len(nodeMap))
+  for m, _ := range nodeMap {
+    moves = append(moves, m)
+  }
+  sort.Slice(moves, func(i, j int) bool {
+    if moves[i].PlayerHand != moves[j].PlayerHand {
+      return moves[i].PlayerHand < moves[j].PlayerHand
+    }
+    return moves[i].ReceiverHand < moves[j].ReceiverHand
+  })
+  return moves
+}
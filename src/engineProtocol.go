@@ -0,0 +1,420 @@
+package main
+
+import (
+  "bufio"
+  "errors"
+  "fmt"
+  "io"
+  "log/slog"
+  "os"
+  "os/exec"
+  "strconv"
+  "strings"
+  "sync"
+  "time"
+)
+
+// A line-based protocol for engines that run in their own process, modelled on chess's UCI so bots can be written in
+// any language. The client writes commands to the engine's stdin and reads replies from its stdout, one per line:
+//
+//   chopsticks                       The handshake. The engine replies with "id name <name>", then "chopsticksok".
+//   setoption rules fingers <n>      The rules: hands with n fingers are knocked out. Engines that can't play them
+//                                    reply "info string error ..." and should be stopped.
+//   isready                          The engine replies "readyok" once it's done setting up.
+//   position <position> [moves ...]  Set up a position like "11/11 w", or "startpos", then play the Moves, e.g. L>R.
+//   go                               The engine replies "bestmove <move>" for the Player to move, or "bestmove none"
+//                                    if the game is over.
+//   quit                             The engine exits. It should also exit at the end of its input, since the
+//                                    server can't always send quit.
+//
+// Moves name the mover's hand, then the receiver's hand, in the position as it was sent. Engines can send
+// "info string <text>" at any time, and unknown lines are ignored by both sides.
+
+const EXTERNAL_ENGINE_TIMEOUT = 10 * time.Second
+// Engine specs for external engines are this prefix then the command line, e.g. "exec:python3 bot.py".
+const EXTERNAL_ENGINE_PREFIX = "exec:"
+
+// ==== Client ====
+
+// Drives an engine program as a Player. It only ever sees positions, so it's told the normalized state and its Move is
+// used on the normalized node as is. Every Move is asked for in a full position, so any of the engine's processes can
+// answer it: each Move gets a process to itself, and processes are started as more games want Moves at once, up to
+// a limit. Past it, games wait for a process to be free.
+type externalEngine struct {
+  command string
+  engineName string
+  logger *slog.Logger
+  // Holds a value for every process working out a Move; its capacity is the most processes there can be.
+  busy chan struct{}
+  mu sync.Mutex
+  // Processes that aren't working out a Move right now.
+  idle []*engineProcess
+  closed bool
+}
+
+// One running copy of the engine program.
+type engineProcess struct {
+  engineName string
+  logger *slog.Logger
+  cmd *exec.Cmd
+  stdin io.WriteCloser
+  // Lines the engine prints, closed when it exits.
+  lines chan string
+}
+
+// Starts the engine once up front, so a command that doesn't work is caught before any game starts. It runs up to
+// maxProcesses copies of the engine at once. What's sent to and received from the engine is logged on the debug level.
+func startExternalEngine(command string, maxProcesses int, logger *slog.Logger) (*externalEngine, error) {
+  if maxProcesses < 1 {
+    return nil, fmt.Errorf("Bad number of engine processes %d, must be positive", maxProcesses)
+  }
+  p, err := startEngineProcess(command, logger)
+  if err != nil {
+    return nil, err
+  }
+  return &externalEngine{
+    command: command, engineName: p.engineName, logger: logger, busy: make(chan struct{}, maxProcesses), idle: []*engineProcess{p},
+  }, nil
+}
+
+func startEngineProcess(command string, logger *slog.Logger) (*engineProcess, error) {
+  args := strings.Fields(command)
+  if len(args) == 0 {
+    return nil, errors.New("Expected a command for the external engine")
+  }
+  cmd := exec.Command(args[0], args[1:]...)
+  cmd.Stderr = os.Stderr
+  stdin, err := cmd.StdinPipe()
+  if err != nil {
+    return nil, err
+  }
+  stdout, err := cmd.StdoutPipe()
+  if err != nil {
+    return nil, err
+  }
+  if err := cmd.Start(); err != nil {
+    return nil, fmt.Errorf("Can't start engine %s: %w", command, err)
+  }
+  lines := make(chan string)
+  go func() {
+    scanner := bufio.NewScanner(stdout)
+    for scanner.Scan() {
+      lines <- strings.TrimSpace(scanner.Text())
+    }
+    close(lines)
+  }()
+
+  p := &engineProcess{command, logger, cmd, stdin, lines}
+  if err := p.handshake(); err != nil {
+    p.stop()
+    return nil, fmt.Errorf("Engine %s didn't start: %w", command, err)
+  }
+  return p, nil
+}
+
+func (p *engineProcess) handshake() error {
+  if err := p.send("chopsticks"); err != nil {
+    return err
+  }
+  for {
+    line, err := p.readLine()
+    if err != nil {
+      return err
+    }
+    if name := strings.TrimPrefix(line, "id name "); name != line && name != "" {
+      p.engineName = name
+    } else if line == "chopsticksok" {
+      break
+    }
+  }
+  if err := p.send("setoption rules fingers " + strconv.Itoa(int(NUM_FINGERS))); err != nil {
+    return err
+  }
+  if err := p.send("isready"); err != nil {
+    return err
+  }
+  _, err := p.readReply("readyok")
+  return err
+}
+
+func (p *engineProcess) send(line string) error {
+  p.logger.Debug("Sent to engine", "engine", p.engineName, "line", line)
+  _, err := io.WriteString(p.stdin, line + "\n")
+  return err
+}
+
+// The next line from the engine, or an error if it takes too long or exits.
+func (p *engineProcess) readLine() (string, error) {
+  timer := time.NewTimer(EXTERNAL_ENGINE_TIMEOUT)
+  defer timer.Stop()
+  select {
+  case line, ok := <-p.lines:
+    if !ok {
+      return "", errors.New("engine exited")
+    }
+    p.logger.Debug("Received from engine", "engine", p.engineName, "line", line)
+    if strings.HasPrefix(line, "info string error") {
+      return "", errors.New(strings.TrimPrefix(line, "info string "))
+    }
+    return line, nil
+  case <-timer.C:
+    return "", fmt.Errorf("no reply after %v", EXTERNAL_ENGINE_TIMEOUT)
+  }
+}
+
+// Reads lines until one starts with the given word, and returns the rest of it.
+func (p *engineProcess) readReply(word string) (string, error) {
+  for {
+    line, err := p.readLine()
+    if err != nil {
+      return "", err
+    }
+    if fields := strings.Fields(line); len(fields) > 0 && fields[0] == word {
+      return strings.TrimSpace(strings.TrimPrefix(line, word)), nil
+    }
+  }
+}
+
+func (e *externalEngine) name() string {
+  return e.engineName
+}
+
+func (e *externalEngine) chooseMove(node *PlayNode) (Move, error) {
+  p, err := e.acquire()
+  if err != nil {
+    return Move{}, err
+  }
+  m, err := p.askForMove(node)
+  if err != nil {
+    // Whatever the process says next would be out of step with what we ask it, so stop it. The next Move gets a
+    // fresh one.
+    p.stop()
+    <-e.busy
+    return Move{}, fmt.Errorf("Engine %s failed: %w", e.engineName, err)
+  }
+  e.release(p)
+  return m, nil
+}
+
+// An idle process to ask for a Move, or a new one if they're all busy. Once there are as many processes as allowed,
+// waits for one to be released, for as long as a process gets to reply.
+func (e *externalEngine) acquire() (*engineProcess, error) {
+  timer := time.NewTimer(EXTERNAL_ENGINE_TIMEOUT)
+  defer timer.Stop()
+  select {
+  case e.busy <- struct{}{}:
+  case <-timer.C:
+    return nil, fmt.Errorf("Engine %s is busy, all %d of its processes are working out Moves", e.engineName, cap(e.busy))
+  }
+  e.mu.Lock()
+  if e.closed {
+    e.mu.Unlock()
+    <-e.busy
+    return nil, fmt.Errorf("Engine %s was stopped", e.engineName)
+  }
+  if n := len(e.idle); n > 0 {
+    p := e.idle[n - 1]
+    e.idle = e.idle[:n - 1]
+    e.mu.Unlock()
+    return p, nil
+  }
+  e.mu.Unlock()
+  // Starting a process takes a while, don't hold up the other games.
+  p, err := startEngineProcess(e.command, e.logger)
+  if err != nil {
+    <-e.busy
+    return nil, err
+  }
+  return p, nil
+}
+
+func (e *externalEngine) release(p *engineProcess) {
+  defer func() { <-e.busy }()
+  e.mu.Lock()
+  if !e.closed {
+    e.idle = append(e.idle, p)
+    e.mu.Unlock()
+    return
+  }
+  e.mu.Unlock()
+  p.stop()
+}
+
+func (p *engineProcess) askForMove(node *PlayNode) (Move, error) {
+  if err := p.send("position " + node.gs.toPositionString()); err != nil {
+    return Move{}, err
+  }
+  if err := p.send("go"); err != nil {
+    return Move{}, err
+  }
+  reply, err := p.readReply("bestmove")
+  if err != nil {
+    return Move{}, err
+  }
+  m, err := parseMoveNotation(reply)
+  if err != nil {
+    return Move{}, err
+  }
+  if _, ok := node.nextNodes[m]; !ok {
+    return Move{}, fmt.Errorf("illegal move %s from %s", reply, node.gs.toPositionString())
+  }
+  return m, nil
+}
+
+// Asks the engine to quit, and kills it if it doesn't.
+func (p *engineProcess) stop() {
+  p.send("quit")
+  p.stdin.Close()
+  done := make(chan struct{})
+  go func() {
+    p.cmd.Wait()
+    close(done)
+  }()
+  select {
+  case <-done:
+  case <-time.After(time.Second):
+    p.cmd.Process.Kill()
+    <-done
+  }
+  // Let the reader finish so it doesn't block forever.
+  for range p.lines {
+  }
+}
+
+// Stops the idle processes. Ones working out a Move are stopped once they're done.
+func (e *externalEngine) close() error {
+  e.mu.Lock()
+  idle := e.idle
+  e.idle, e.closed = nil, true
+  e.mu.Unlock()
+  for _, p := range idle {
+    p.stop()
+  }
+  return nil
+}
+
+// Engines that hold on to something, like a process, that has to be let go of once the game is over.
+type closableEngine interface {
+  Engine
+  close() error
+}
+
+func closeEngines(engines []Engine) {
+  for _, engine := range engines {
+    if closable, ok := engine.(closableEngine); ok {
+      closable.close()
+    }
+  }
+}
+
+// ==== Engine ====
+
+// Parses the arguments of a position command: a position or startpos, optionally followed by moves and the Moves
+// to play from it.
+func parseProtocolPosition(args []string) (*GameState, error) {
+  if len(args) == 0 {
+    return nil, errors.New("expected a position")
+  }
+  var gs *GameState
+  var err error
+  if args[0] == "startpos" {
+    gs, args = initGame(), args[1:]
+  } else if len(args) >= 2 {
+    if gs, err = parsePosition(args[0] + " " + args[1]); err != nil {
+      return nil, err
+    }
+    if err := validatePosition(gs); err != nil {
+      return nil, err
+    }
+    args = args[2:]
+  } else {
+    return nil, fmt.Errorf("invalid position %q", args[0])
+  }
+  if len(args) == 0 {
+    return gs, nil
+  }
+  if args[0] != "moves" {
+    return nil, fmt.Errorf("expected moves, got %q", args[0])
+  }
+  for _, notation := range args[1:] {
+    m, err := parseMoveNotation(notation)
+    if err != nil {
+      return nil, err
+    }
+    if checkGameResult(gs) != Ongoing || !gs.isMoveValid(m) {
+      return nil, fmt.Errorf("illegal move %s from %s", notation, gs.toPositionString())
+    }
+    if gs, err = gs.copyAndPlayTurn(m.PlayerHand, m.ReceiverHand); err != nil {
+      return nil, err
+    }
+  }
+  return gs, nil
+}
+
+// The engine's Move from the position, in the hands of the position rather than the normalized ones.
+func protocolBestMove(graph *solveGraph, engine Engine, gs *GameState) (string, error) {
+  if checkGameResult(gs) != Ongoing {
+    return "none", nil
+  }
+  node, err := graph.lookupOrSolve(gs)
+  if err != nil {
+    return "", err
+  }
+  normalizedMove, err := engine.chooseMove(node)
+  if err != nil {
+    return "", err
+  }
+  stateCopy := *gs
+  gameMove, err := createGamePlayState(&stateCopy).playNormalizedTurn(normalizedMove)
+  if err != nil {
+    return "", err
+  }
+  return gameMove.toNotation(), nil
+}
+
+// Speaks the protocol on the engine's side for one of the built-in engines, until quit or the end of the input.
+func runEngineProtocol(in io.Reader, out io.Writer, engine Engine, graph *solveGraph) error {
+  reply := func(format string, args ...interface{}) error {
+    _, err := fmt.Fprintf(out, format + "\n", args...)
+    return err
+  }
+  gs := initGame()
+  scanner := bufio.NewScanner(in)
+  for scanner.Scan() {
+    fields := strings.Fields(scanner.Text())
+    if len(fields) == 0 {
+      continue
+    }
+    var err error
+    switch fields[0] {
+    case "chopsticks":
+      if err = reply("id name %s", engine.name()); err == nil {
+        err = reply("chopsticksok")
+      }
+    case "isready":
+      err = reply("readyok")
+    case "setoption":
+      if len(fields) == 4 && fields[1] == "rules" && fields[2] == "fingers" && fields[3] != strconv.Itoa(int(NUM_FINGERS)) {
+        err = reply("info string error only games with %d fingers are supported", NUM_FINGERS)
+      }
+    case "position":
+      if next, posErr := parseProtocolPosition(fields[1:]); posErr != nil {
+        err = reply("info string error %s", posErr.Error())
+      } else {
+        gs = next
+      }
+    case "go":
+      if m, moveErr := protocolBestMove(graph, engine, gs); moveErr != nil {
+        err = reply("info string error %s", moveErr.Error())
+      } else {
+        err = reply("bestmove %s", m)
+      }
+    case "quit":
+      return nil
+    }
+    if err != nil {
+      return err
+    }
+  }
+  return scanner.Err()
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+  "bytes"
+  "fmt"
+  "log/slog"
+  "os"
+  "strings"
+  "testing"
+  "time"
+)
+
+func TestParseProtocolPosition(t *testing.T) {
+  fmt.Println("starting TestParseProtocolPosition")
+  for args, expected := range map[string]string{
+    "startpos": "11/11 w",
+    "startpos moves L>L": "11/21 b",
+    "14/44 w moves R>L l>r": "12/34 w",
+  } {
+    gs, err := parseProtocolPosition(strings.Fields(args))
+    if err != nil {
+      t.Fatal(err.Error())
+    }
+    if gs.toPositionString() != expected {
+      t.Fatalf("Expected %s from %s, got %s", expected, args, gs.toPositionString())
+    }
+  }
+  for _, args := range []string{"", "11/11", "11/11 w L>L", "startpos moves L>X", "10/40 w moves L>L L>L", "19/11 w"} {
+    if _, err := parseProtocolPosition(strings.Fields(args)); err == nil {
+      t.Fatalf("Expected an error for position %q", args)
+    }
+  }
+}
+
+func TestEngineProtocol(t *testing.T) {
+  fmt.Println("starting TestEngineProtocol")
+  graph := createSolveGraph(make(map[GameState]*PlayNode), DEFAULT_MAX_DEPTH, slog.Default())
+  if err := graph.rebuild(initGame()); err != nil {
+    t.Fatal(err.Error())
+  }
+  engine, err := engineForSpec("perfect", 1)
+  if err != nil {
+    t.Fatal(err.Error())
+  }
+  in := strings.Join([]string{
+    "chopsticks", "setoption rules fingers 5", "isready", "position 01/04 w", "go", "position 10/40 w", "go",
+    "unknown command", "position 10/00 b", "setoption rules fingers 4", "quit", "isready",
+  }, "\n")
+  var out bytes.Buffer
+  if err := runEngineProtocol(strings.NewReader(in), &out, engine, graph); err != nil {
+    t.Fatal(err.Error())
+  }
+  // The winning Move is in the hands as they were sent, not the normalized ones.
+  expected := []string{
+    "id name perfect", "chopsticksok", "readyok", "bestmove R>R", "bestmove L>L",
+    "info string error Game is already over: 10/00 b", "info string error only games with 5 fingers are supported",
+  }
+  if lines := strings.Split(strings.TrimSpace(out.String()), "\n"); strings.Join(lines, "|") != strings.Join(expected, "|") {
+    t.Fatalf("Unexpected replies:\n%s", out.String())
+  }
+}
+
+// Not a real test: TestExternalEngine runs the test binary again with this as the only test, as an engine process.
+func TestEngineHelperProcess(t *testing.T) {
+  if os.Getenv("CHOPSTICKS_ENGINE_HELPER") != "1" {
+    return
+  }
+  protocolOut := os.Stdout
+  os.Stdout = os.Stderr
+  graph := createSolveGraph(make(map[GameState]*PlayNode), DEFAULT_MAX_DEPTH, slog.Default())
+  if err := graph.rebuild(initGame()); err != nil {
+    os.Exit(1)
+  }
+  engine, _ := engineForSpec(os.Getenv("CHOPSTICKS_ENGINE_HELPER_DIFFICULTY"), 1)
+  runEngineProtocol(os.Stdin, protocolOut, engine, graph)
+  os.Exit(0)
+}
+
+func TestExternalEngine(t *testing.T) {
+  fmt.Println("starting TestExternalEngine")
+  t.Setenv("CHOPSTICKS_ENGINE_HELPER", "1")
+  t.Setenv("CHOPSTICKS_ENGINE_HELPER_DIFFICULTY", "perfect")
+  engine, err := engineForSpec(EXTERNAL_ENGINE_PREFIX + os.Args[0] + " -test.run=^TestEngineHelperProcess$", 1)
+  if err != nil {
+    t.Fatal(err.Error())
+  }
+  defer closeEngines([]Engine{engine})
+  if engine.name() != "perfect" {
+    t.Fatalf("Expected the engine to introduce itself, got %s", engine.name())
+  }
+
+  graph := createSolveGraph(make(map[GameState]*PlayNode), DEFAULT_MAX_DEPTH, slog.Default())
+  if err := graph.rebuild(initGame()); err != nil {
+    t.Fatal(err.Error())
+  }
+  random, err := engineForSpec("random", 1)
+  if err != nil {
+    t.Fatal(err.Error())
+  }
+  // The external engine never loses from the start or from a won position, whichever side it plays.
+  for _, start := range []string{"11/11 w", "10/40 w", "40/10 b"} {
+    gs, err := parsePosition(start)
+    if err != nil {
+      t.Fatal(err.Error())
+    }
+    external := gs.T
+    result, err := playEngineGame(graph, gs, cliPlayers{external: engine, invertTurn(external): random}, 100)
+    if err != nil {
+      t.Fatal(err.Error())
+    }
+    if rewardForTurn(result, external) == 0 {
+      t.Fatalf("External engine lost from %s", start)
+    }
+  }
+
+  // A process that dies fails the Move it was asked for, and the next Move gets a new one.
+  node, _ := graph.lookup(initGame())
+  external := engine.(*externalEngine)
+  if len(external.idle) != 1 {
+    t.Fatalf("Expected one idle process, got %d", len(external.idle))
+  }
+  external.idle[0].cmd.Process.Kill()
+  if _, err := engine.chooseMove(node); err == nil {
+    t.Fatal("Expected an error from a killed engine process")
+  }
+  if _, err := engine.chooseMove(node); err != nil {
+    t.Fatalf("Expected the engine to be restarted, got %s", err.Error())
+  }
+  // Games asking for Moves at the same time get processes of their own.
+  first, err := external.acquire()
+  if err != nil {
+    t.Fatal(err.Error())
+  }
+  second, err := external.acquire()
+  if err != nil {
+    t.Fatal(err.Error())
+  }
+  if first == second {
+    t.Fatal("Expected busy processes not to be shared")
+  }
+  external.release(first)
+  external.release(second)
+
+  // Past the limit, games wait for a process instead of starting another.
+  limited, err := startExternalEngine(os.Args[0] + " -test.run=^TestEngineHelperProcess$", 1, slog.Default())
+  if err != nil {
+    t.Fatal(err.Error())
+  }
+  defer limited.close()
+  only, err := limited.acquire()
+  if err != nil {
+    t.Fatal(err.Error())
+  }
+  waited := make(chan *engineProcess)
+  go func() {
+    next, _ := limited.acquire()
+    waited <- next
+  }()
+  select {
+  case <-waited:
+    t.Fatal("Expected to wait for the only process")
+  case <-time.After(100 * time.Millisecond):
+  }
+  limited.release(only)
+  if next := <-waited; next != only {
+    t.Fatal("Expected the released process to be reused")
+  }
+  limited.release(only)
+
+  closeEngines([]Engine{engine})
+  if _, err := engine.chooseMove(node); err == nil {
+    t.Fatal("Expected an error asking a stopped engine for a Move")
+  }
+  if _, err := engineForSpec(EXTERNAL_ENGINE_PREFIX + "/nonexistent/engine", 1); err == nil {
+    t.Fatal("Expected an error starting an engine that doesn't exist")
+  }
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+  "fmt"
+  "testing"
+)
+
+func TestPerfectEnginePlaysBestMove(t *testing.T) {
+  fmt.Println("starting TestPerfectEnginePlaysBestMove")
+  stateNode, visitedStates, _, _, _, err := solve(initGame(), DEFAULT_MAX_DEPTH)
+  if err != nil {
+    t.Fatal(err.Error())
+  }
+  engine, err := createSolverEngine("perfect", 1)
+  if err != nil {
+    t.Fatal(err.Error())
+  }
+  for _, node := range visitedStates {
+    if len(node.nextNodes) == 0 {
+      continue
+    }
+    bestMove, _, err := node.getBestMoveAndScoreForCurrentPlayer(nil, true)
+    if err != nil {
+      t.Fatal(err.Error())
+    }
+    engineMove, err := engine.chooseMove(node)
+    if err != nil {
+      t.Fatal(err.Error())
+    }
+    // Several Moves can share the best score, so compare scores rather than Moves.
+    if node.nextNodes[engineMove].score != node.nextNodes[bestMove].score {
+      t.Fatalf("Perfect engine did not play a best Move: %+v vs %+v, %s", engineMove, bestMove, node.toTreeString(1))
+    }
+  }
+  if _, err := engine.chooseMove(stateNode); err != nil {
+    t.Fatal(err.Error())
+  }
+}
+
+func TestRandomEnginePlaysLegalMoves(t *testing.T) {
+  fmt.Println("starting TestRandomEnginePlaysLegalMoves")
+  _, visitedStates, _, _, _, err := solve(initGame(), DEFAULT_MAX_DEPTH)
+  if err != nil {
+    t.Fatal(err.Error())
+  }
+  engine, err := createSolverEngine("random", 42)
+  if err != nil {
+    t.Fatal(err.Error())
+  }
+  for _, node := range visitedStates {
+    if len(node.nextNodes) == 0 {
+      if _, err := engine.chooseMove(node); err == nil {
+        t.Fatalf("Expected an error choosing a Move from a leaf: %s", node.toString())
+      }
+      continue
+    }
+    m, err := engine.chooseMove(node)
+    if err != nil {
+      t.Fatal(err.Error())
+    }
+    if !node.gs.isMoveValid(m) || node.nextNodes[m] == nil {
+      t.Fatalf("Random engine played an illegal Move %+v from %s", m, node.toString())
+    }
+  }
+}
+
+func TestEngineForDifficulty(t *testing.T) {
+  fmt.Println("starting TestEngineForDifficulty")
+  for _, difficulty := range DIFFICULTY_NAMES {
+    engine, err := engineForDifficulty(difficulty)
+    if err != nil {
+      t.Fatal(err.Error())
+    }
+    if engine.name() != difficulty {
+      t.Fatalf("Engine has the wrong name: %s, expected %s", engine.name(), difficulty)
+    }
+  }
+  if engine, err := engineForDifficulty(""); err != nil || engine.name() != DEFAULT_DIFFICULTY {
+    t.Fatalf("Empty difficulty should give the default engine: %+v, %v", engine, err)
+  }
+  if _, err := engineForDifficulty("impossible"); err == nil {
+    t.Fatal("Expected an error for an unknown difficulty")
+  }
+}
+
+func TestParseSide(t *testing.T) {
+  fmt.Println("starting TestParseSide")
+  if side, err := parseSide("first"); err != nil || side != Player1 {
+    t.Fatalf("Unexpected side for first: %v, %v", side, err)
+  }
+  if side, err := parseSide("second"); err != nil || side != Player2 {
+    t.Fatalf("Unexpected side for second: %v, %v", side, err)
+  }
+  if side, err := parseSide("random"); err != nil || (side != Player1 && side != Player2) {
+    t.Fatalf("Unexpected side for random: %v, %v", side, err)
+  }
+  if _, err := parseSide("middle"); err == nil {
+    t.Fatal("Expected an error for an invalid side")
+  }
+}
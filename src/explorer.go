@@ -3,6 +3,7 @@ package main
 import (
   "fmt"
   "errors"
+  "log/slog"
   "sort"
 )
 
@@ -11,15 +12,13 @@ import (
 // These could either be terminal states or require further exploration. We should start at these states when scoring
 // the play graph.
 /// OK, fuck breadth first search... go back to dfs but keep the same function signature.
-func exploreStates(startNode *PlayNode, visitedStates map[GameState]*PlayNode, maxDepth int) (*PlayNode, map[*PlayNode][]*PlayNode, [][]*PlayNode, error) {
-  return exploreStatesImpl(startNode, []*PlayNode{startNode}, visitedStates, make(map[*PlayNode][]*PlayNode, 4), make([][]*PlayNode, 0, 4), maxDepth, 0)
+func exploreStates(startNode *PlayNode, visitedStates map[GameState]*PlayNode, maxDepth int, logger *slog.Logger) (*PlayNode, map[*PlayNode][]*PlayNode, [][]*PlayNode, error) {
+  return exploreStatesImpl(startNode, []*PlayNode{startNode}, visitedStates, make(map[*PlayNode][]*PlayNode, 4), make([][]*PlayNode, 0, 4), maxDepth, 0, createSolveRun(nil, logger))
 }
 
-func exploreStatesRetryable(startNode *PlayNode, curPath []*PlayNode, visitedStates map[GameState]*PlayNode, maxDepth int) (*PlayNode, map[*PlayNode][]*PlayNode, [][]*PlayNode, error) {
-  if INFO {
-    fmt.Printf("Exploring state %s\n", startNode.toString())
-  }
-  return exploreStatesImpl(startNode, curPath, visitedStates, make(map[*PlayNode][]*PlayNode, 4), make([][]*PlayNode, 0, 4), maxDepth, len(curPath) - 1)
+func exploreStatesRetryable(startNode *PlayNode, curPath []*PlayNode, visitedStates map[GameState]*PlayNode, maxDepth int, run *solveRun) (*PlayNode, map[*PlayNode][]*PlayNode, [][]*PlayNode, error) {
+  run.logger.Debug("Exploring state", "node", lazyLogValue(startNode.toString))
+  return exploreStatesImpl(startNode, curPath, visitedStates, make(map[*PlayNode][]*PlayNode, 4), make([][]*PlayNode, 0, 4), maxDepth, len(curPath) - 1, run)
 }
 
 // Yes, O(N) search. Whatever, it's probably fine
@@ -45,7 +44,7 @@ type exploreCandidate struct {
   heuristic float32
 }
 
-func exploreStatesImpl(curNode *PlayNode, curPath []*PlayNode, visitedStates map[GameState]*PlayNode, leaves map[*PlayNode][]*PlayNode, loops [][]*PlayNode, maxDepth int, baseDepth int) (*PlayNode, map[*PlayNode][]*PlayNode, [][]*PlayNode, error) {
+func exploreStatesImpl(curNode *PlayNode, curPath []*PlayNode, visitedStates map[GameState]*PlayNode, leaves map[*PlayNode][]*PlayNode, loops [][]*PlayNode, maxDepth int, baseDepth int, run *solveRun) (*PlayNode, map[*PlayNode][]*PlayNode, [][]*PlayNode, error) {
   // Sanity check: curNode should be the last node of the path
   if curPath[len(curPath) - 1] != curNode {
     return nil, nil, nil, errors.New(fmt.Sprintf("current path is invalid, last node should be %+v: %+v", curNode, curPath))
@@ -60,19 +59,16 @@ func exploreStatesImpl(curNode *PlayNode, curPath []*PlayNode, visitedStates map
 
   // Memoize the current node now so we can catch intersections in recursive calls.
   visitedStates[curGs] = curNode
+  run.exploredState()
 
-  if DEBUG {
-    fmt.Printf("Exploring node: %s, depth: %d\n", curNode.toString(), depth)
-  }
+  run.logger.Debug("Exploring node", "node", lazyLogValue(curNode.toString), "depth", depth)
 
   // Check if we've hit the max depth - if so mark this node as a "frontier node", i.e. a non-terminal leaf, and DON'T save it 
   // to our visited states map (since we haven't visited it.)
   // TODO: too support retryable-ness this should happen earlier.
   if depth >= maxDepth {
     // This is a leaf node, add it to our output collection and continue
-    if INFO {
-      fmt.Printf(fmt.Sprintf("Hit max depth, not exploring further. cur state: %+v, depth %d\n", curNode.gs, depth))
-    }
+    run.logger.Debug("Hit max depth, not exploring further", "position", lazyLogValue(curNode.gs.toPositionString), "depth", depth)
     // Mark this as a leaf, but _NOT_ an explored state
     leaves[curNode] = copyPath(curPath)
     return curNode, leaves, loops, nil
@@ -88,9 +84,7 @@ func exploreStatesImpl(curNode *PlayNode, curPath []*PlayNode, visitedStates map
   // Check for terminal states, i.e. TERMINAL leaf nodes. We also need to save these because we need them for scoring.
   if curNode.isTerminal() {
     // This is a leaf node, add it to our output collection and continue
-      if INFO {
-        fmt.Printf(fmt.Sprintf("Found leaf node, not exploring further. cur state: %+v, depth %d\n", curNode.gs, depth))
-      }
+    run.logger.Debug("Found leaf node, not exploring further", "position", lazyLogValue(curNode.gs.toPositionString), "depth", depth)
     leaves[curNode] = copyPath(curPath)
     return curNode, leaves, loops, nil
   }
@@ -136,15 +130,13 @@ func exploreStatesImpl(curNode *PlayNode, curPath []*PlayNode, visitedStates map
         return nil, nil, nil, errors.New(fmt.Sprintf("Visiting states map is corrupt: visitedStates[%+v] = %s", nextNode.gs, existingNode.toString()))
       }
       addParentChildEdges(curNode, existingNode, *curMove)
-      if DEBUG {
-        fmt.Printf(fmt.Sprintf("++ Found intersection in Move tree, not exploring further. cur node: %s, loop Move: %+v, next node: %s\n", curNode.toString(), curMove, existingNode.toString()))
-      }
+      run.stats.Transpositions++
+      run.logger.Debug("Found intersection in Move tree, not exploring further", "node", lazyLogValue(curNode.toString),
+        "move", lazyLogValue(curMove.toString), "next", lazyLogValue(existingNode.toString))
       // Check for loops
       if loopIdx := findNodeInPath(existingNode, curPath); loopIdx != -1 {
         curLoop := copyPath(curPath[loopIdx:])
-        if DEBUG {
-          fmt.Printf("++++ Found LOOP in Move tree, saving loop for later: %+v\n", curLoop)
-        }
+        run.logger.Debug("Found loop in Move tree, saving it for later", "length", len(curLoop))
         loops = append(loops, curLoop)
       }
     } else {
@@ -156,7 +148,7 @@ func exploreStatesImpl(curNode *PlayNode, curPath []*PlayNode, visitedStates map
       //   nextNode, nextNode.getHeuristicScoreForCurrentPlayer(),
       // })
       nextPath := append(curPath, nextNode)
-      _, _, newLoops, err := exploreStatesImpl(nextNode, nextPath, visitedStates, leaves, loops, maxDepth, baseDepth)
+      _, _, newLoops, err := exploreStatesImpl(nextNode, nextPath, visitedStates, leaves, loops, maxDepth, baseDepth, run)
       loops = newLoops
       if err != nil {
         return nil, nil, nil, err
@@ -171,11 +163,11 @@ func exploreStatesImpl(curNode *PlayNode, curPath []*PlayNode, visitedStates map
 }
 
 // Explore the game tree and correct any incorrect scores.
-func solidifyScores(startNode *PlayNode, maxDepth int) bool {
-  return solidifyScoresImpl(startNode, make(map[*PlayNode]bool, 4), 0, maxDepth)
+func solidifyScores(startNode *PlayNode, maxDepth int, logger *slog.Logger) bool {
+  return solidifyScoresImpl(startNode, make(map[*PlayNode]bool, 4), 0, maxDepth, logger)
 }
 
-func solidifyScoresImpl(curNode *PlayNode, visitedNodes map[*PlayNode]bool, depth int, maxDepth int) bool {
+func solidifyScoresImpl(curNode *PlayNode, visitedNodes map[*PlayNode]bool, depth int, maxDepth int, logger *slog.Logger) bool {
   // Abort after we hit the maximum depth.
   if depth >= maxDepth { 
     return false
@@ -194,18 +186,16 @@ func solidifyScoresImpl(curNode *PlayNode, visitedNodes map[*PlayNode]bool, dept
   // First, solidify scores for all children. For leaves this will be empty.
   someChildUpdatedScore := false
   for _, childNode := range curNode.nextNodes {
-    if solidifyScoresImpl(childNode, visitedNodes, depth-1, maxDepth) {
+    if solidifyScoresImpl(childNode, visitedNodes, depth-1, maxDepth, logger) {
       someChildUpdatedScore = true
     }
   }
 
   // Then, update the score for the current node.
   prevScore := curNode.score
-  curNode.updateScore()
+  curNode.updateScore(logger)
   if curNode.score != prevScore {
-    if DEBUG {
-      fmt.Printf("Updated score for node %s, previous score: %f\n", curNode.toString(), prevScore)
-    }
+    logger.Debug("Updated score", "node", lazyLogValue(curNode.toString), "previous_score", prevScore)
     return true
   } else {
     return someChildUpdatedScore
@@ -2,6 +2,7 @@ package main
 
 import (
   "fmt"
+  "log/slog"
   "testing"
 )
 
@@ -12,7 +13,7 @@ func testExploreStates(numFingers int8, maxDepth int, t *testing.T) {
     Player{1, 1}, Player{1, 1}, Player1,
   }
   visitedStates := make(map[GameState]*PlayNode, 38)
-  startNode, leaves, loops, err := exploreStates(createPlayNodeCopyGs(startState), visitedStates, maxDepth)
+  startNode, leaves, loops, err := exploreStates(createPlayNodeCopyGs(startState), visitedStates, maxDepth, slog.Default())
   if err != nil {
     t.Fatal(err)
   }
@@ -74,7 +75,7 @@ func TestExploreLoop(t *testing.T) {
     Player{0, 4}, Player{0, 3}, Player1,
   }
   visitedStates := make(map[GameState]*PlayNode, 38)
-  startNode, leaves, loops, err := exploreStates(createPlayNodeCopyGs(startState), visitedStates, 15)
+  startNode, leaves, loops, err := exploreStates(createPlayNodeCopyGs(startState), visitedStates, 15, slog.Default())
   if err != nil {
     t.Fatal(err)
   }
@@ -212,7 +213,7 @@ func TestSolidifyScore(t *testing.T) {
   n2.score, n2.isScored = 0, true // Should be 1
   n1.score, n1.isScored = 0, true // Should be 1
 
-  if updated := solidifyScores(n1, 5); !updated {
+  if updated := solidifyScores(n1, 5, slog.Default()); !updated {
     t.Fatal("Scores did not update when they should have")
   }
 
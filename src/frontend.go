@@ -0,0 +1,76 @@
+package main
+
+import (
+  "bytes"
+  "crypto/sha256"
+  "embed"
+  "errors"
+  "fmt"
+  "io/fs"
+  "net/http"
+  "os"
+  "path"
+  "strings"
+  "time"
+)
+
+// The frontend is built into the binary, so the server runs from any directory. In dev mode it's read from disk on
+// every request instead, so edits show up on reload.
+
+// Only the files the page uses; frontend/static has other artwork that would only make the binary bigger. Images
+// the page starts using have to be added here, which TestEmbeddedFrontendAssets checks.
+//go:embed frontend/index.html frontend/static/hands.png frontend/static/hands_green.png frontend/static/hands_red.png
+var EMBEDDED_FRONTEND embed.FS
+
+// How long browsers can use static files without asking again. index.html is always checked, so a new build's page
+// shows up right away; it can still come from the cache if its ETag hasn't changed.
+const STATIC_MAX_AGE = 24 * time.Hour
+
+func embeddedFrontend() fs.FS {
+  frontend, err := fs.Sub(EMBEDDED_FRONTEND, "frontend")
+  if err != nil {
+    panic(err)
+  }
+  return frontend
+}
+
+func frontendFromConfig(cfg config) fs.FS {
+  if cfg.Dev {
+    return os.DirFS(cfg.AssetDir)
+  }
+  return embeddedFrontend()
+}
+
+// Serves the files in the frontend, with index.html at /. Content types come from the file extension, and every
+// response has an ETag so unchanged files come back as 304s.
+func getFrontendHandler(frontend fs.FS, dev bool) http.Handler {
+  fn := func (w http.ResponseWriter, r *http.Request) {
+    name := strings.TrimPrefix(path.Clean(r.URL.Path), "/")
+    if name == "" {
+      name = "index.html"
+    }
+    body, err := fs.ReadFile(frontend, name)
+    if errors.Is(err, fs.ErrNotExist) || errors.Is(err, fs.ErrInvalid) {
+      http.NotFound(w, r)
+      return
+    } else if err != nil {
+      // Directories can't be read as files.
+      if info, statErr := fs.Stat(frontend, name); statErr == nil && info.IsDir() {
+        http.NotFound(w, r)
+        return
+      }
+      requestLogger(r).Error("Error reading from the frontend", "file", name, "err", err)
+      http.Error(w, "can't read file", http.StatusInternalServerError)
+      return
+    }
+    if dev || name == "index.html" {
+      w.Header().Set("Cache-Control", "no-cache")
+    } else {
+      w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(STATIC_MAX_AGE.Seconds())))
+    }
+    w.Header().Set("ETag", fmt.Sprintf("\"%x\"", sha256.Sum256(body)))
+    // ServeContent answers If-None-Match from the ETag, and handles HEAD and ranges.
+    http.ServeContent(w, r, name, time.Time{}, bytes.NewReader(body))
+  }
+  return http.HandlerFunc(fn)
+}
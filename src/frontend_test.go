@@ -0,0 +1,112 @@
+package main
+
+import (
+  "fmt"
+  "io"
+  "net/http"
+  "net/http/httptest"
+  "os"
+  "io/fs"
+  "path/filepath"
+  "regexp"
+  "strings"
+  "testing"
+)
+
+func getFrontend(t *testing.T, url string, etag string) (*http.Response, string) {
+  req, err := http.NewRequest("GET", url, nil)
+  if err != nil {
+    t.Fatal(err.Error())
+  }
+  if etag != "" {
+    req.Header.Set("If-None-Match", etag)
+  }
+  resp, err := http.DefaultClient.Do(req)
+  if err != nil {
+    t.Fatal(err.Error())
+  }
+  defer resp.Body.Close()
+  body, err := io.ReadAll(resp.Body)
+  if err != nil {
+    t.Fatal(err.Error())
+  }
+  return resp, string(body)
+}
+
+func TestEmbeddedFrontend(t *testing.T) {
+  fmt.Println("starting TestEmbeddedFrontend")
+  server, _ := createTestServer(t)
+  defer server.Close()
+
+  resp, body := getFrontend(t, server.URL + "/", "")
+  if resp.StatusCode != http.StatusOK || !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/html") || !strings.Contains(body, "<script>") {
+    t.Fatalf("Expected index.html, got %d %s", resp.StatusCode, resp.Header.Get("Content-Type"))
+  }
+  etag := resp.Header.Get("ETag")
+  if etag == "" || resp.Header.Get("Cache-Control") != "no-cache" {
+    t.Fatalf("Expected index.html to be revalidated by ETag: %+v", resp.Header)
+  }
+  if resp, _ := getFrontend(t, server.URL + "/", etag); resp.StatusCode != http.StatusNotModified {
+    t.Fatalf("Expected a 304 for an unchanged ETag, got %d", resp.StatusCode)
+  }
+
+  resp, _ = getFrontend(t, server.URL + "/static/hands.png", "")
+  if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "image/png" || !strings.Contains(resp.Header.Get("Cache-Control"), "max-age=") {
+    t.Fatalf("Expected a cacheable png, got %d %+v", resp.StatusCode, resp.Header)
+  }
+  for _, missing := range []string{"/static/nope.png", "/static/", "/static/../../go.mod"} {
+    if resp, _ := getFrontend(t, server.URL + missing, ""); resp.StatusCode != http.StatusNotFound {
+      t.Fatalf("Expected a 404 for %s, got %d", missing, resp.StatusCode)
+    }
+  }
+}
+
+func TestDevFrontend(t *testing.T) {
+  fmt.Println("starting TestDevFrontend")
+  dir := t.TempDir()
+  index := filepath.Join(dir, "index.html")
+  if err := os.WriteFile(index, []byte("<p>before</p>"), 0644); err != nil {
+    t.Fatal(err.Error())
+  }
+  cfg := defaultConfig()
+  cfg.Dev, cfg.AssetDir = true, dir
+  server := httptest.NewServer(getFrontendHandler(frontendFromConfig(cfg), cfg.Dev))
+  defer server.Close()
+
+  resp, body := getFrontend(t, server.URL + "/", "")
+  if body != "<p>before</p>" {
+    t.Fatalf("Expected the file on disk, got %s", body)
+  }
+  if err := os.WriteFile(index, []byte("<p>after</p>"), 0644); err != nil {
+    t.Fatal(err.Error())
+  }
+  // The old ETag no longer matches, so the edit comes through.
+  resp, body = getFrontend(t, server.URL + "/", resp.Header.Get("ETag"))
+  if resp.StatusCode != http.StatusOK || body != "<p>after</p>" {
+    t.Fatalf("Expected the edited file, got %d %s", resp.StatusCode, body)
+  }
+}
+
+func TestEmbeddedFrontendAssets(t *testing.T) {
+  fmt.Println("starting TestEmbeddedFrontendAssets")
+  index, err := fs.ReadFile(embeddedFrontend(), "index.html")
+  if err != nil {
+    t.Fatal(err.Error())
+  }
+  used := make(map[string]bool)
+  for _, asset := range regexp.MustCompile(`static/[A-Za-z0-9_.-]+`).FindAllString(string(index), -1) {
+    used[asset] = true
+    if _, err := fs.Stat(embeddedFrontend(), asset); err != nil {
+      t.Fatalf("index.html uses %s, but it isn't embedded: %v", asset, err)
+    }
+  }
+  embedded, err := fs.Glob(embeddedFrontend(), "static/*")
+  if err != nil {
+    t.Fatal(err.Error())
+  }
+  for _, asset := range embedded {
+    if !used[asset] {
+      t.Fatalf("Embedded %s, but nothing uses it", asset)
+    }
+  }
+}
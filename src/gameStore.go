@@ -0,0 +1,219 @@
+package main
+
+import (
+  "bufio"
+  "encoding/json"
+  "fmt"
+  "net/http"
+  "os"
+  "sync"
+  "time"
+
+  "github.com/gorilla/mux"
+)
+
+// Finished games get saved to a GameStore so they outlive the process.
+
+const DEFAULT_GAMES_FILE = "games.jsonl"
+
+type recordedMove struct {
+  // In the hands the Players see
+  Move Move
+  // The state after the Move
+  State GameState
+  Time time.Time
+}
+
+// The name recorded for humans who didn't give one.
+const ANONYMOUS_PLAYER = "human"
+
+type GameRecord struct {
+  Id string
+  // Who played each side, keyed by "p1"/"p2": a Player's name, ANONYMOUS_PLAYER, or the engine difficulty.
+  Players map[string]string
+  // The rules the game was played with
+  NumFingers int8
+  StartPosition string
+  Moves []recordedMove
+  Result GameResult
+  StartTime time.Time
+  // Zero until the game is over
+  EndTime time.Time
+}
+
+// A GameRecord without its Moves, for listing games.
+type gameRecordSummary struct {
+  Id string
+  Players map[string]string
+  NumFingers int8
+  StartPosition string
+  NumMoves int
+  Result GameResult
+  StartTime time.Time
+  EndTime time.Time
+}
+
+func createGameRecord(id string, start *GameState, players map[string]string) *GameRecord {
+  return &GameRecord{
+    id, players, NUM_FINGERS, start.toPositionString(), []recordedMove{}, checkGameResult(start), time.Now(), time.Time{},
+  }
+}
+
+// Record a Move and the state it led to.
+func (r *GameRecord) addMove(m Move, after *GameState) {
+  r.Moves = append(r.Moves, recordedMove{m, *after, time.Now()})
+  r.Result = checkGameResult(after)
+  if r.Result != Ongoing {
+    r.EndTime = time.Now()
+  }
+}
+
+func (r *GameRecord) summary() gameRecordSummary {
+  return gameRecordSummary{r.Id, r.Players, r.NumFingers, r.StartPosition, len(r.Moves), r.Result, r.StartTime, r.EndTime}
+}
+
+func (result GameResult) toString() string {
+  switch result {
+  case Player1Wins:
+    return "Player 1 wins"
+  case Player2Wins:
+    return "Player 2 wins"
+  default:
+    return "ongoing"
+  }
+}
+
+type GameStore interface {
+  save(record *GameRecord) error
+  // All saved games, oldest first.
+  list() ([]*GameRecord, error)
+  get(id string) (*GameRecord, bool, error)
+}
+
+// Keeps games in a JSON-lines file, one record per line. Saving a game again appends a new line which replaces the
+// older one when reading.
+type fileGameStore struct {
+  mu sync.Mutex
+  path string
+}
+
+func createFileGameStore(path string) *fileGameStore {
+  return &fileGameStore{sync.Mutex{}, path}
+}
+
+func (fs *fileGameStore) save(record *GameRecord) error {
+  line, err := json.Marshal(record)
+  if err != nil {
+    return err
+  }
+  fs.mu.Lock()
+  defer fs.mu.Unlock()
+  f, err := os.OpenFile(fs.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+  if err != nil {
+    return err
+  }
+  if _, err := f.Write(append(line, '\n')); err != nil {
+    f.Close()
+    return err
+  }
+  return f.Close()
+}
+
+func (fs *fileGameStore) list() ([]*GameRecord, error) {
+  fs.mu.Lock()
+  defer fs.mu.Unlock()
+  f, err := os.Open(fs.path)
+  if os.IsNotExist(err) {
+    return []*GameRecord{}, nil
+  } else if err != nil {
+    return nil, err
+  }
+  defer f.Close()
+
+  records := []*GameRecord{}
+  // Index of each game in records, so later saves replace earlier ones in place.
+  indices := make(map[string]int)
+  scanner := bufio.NewScanner(f)
+  scanner.Buffer(make([]byte, 64 * 1024), 16 * 1024 * 1024)
+  for lineNum := 1; scanner.Scan(); lineNum++ {
+    if len(scanner.Bytes()) == 0 {
+      continue
+    }
+    var record GameRecord
+    if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+      return nil, fmt.Errorf("%s:%d: %v", fs.path, lineNum, err)
+    }
+    if i, ok := indices[record.Id]; ok {
+      records[i] = &record
+    } else {
+      indices[record.Id] = len(records)
+      records = append(records, &record)
+    }
+  }
+  if err := scanner.Err(); err != nil {
+    return nil, err
+  }
+  return records, nil
+}
+
+func (fs *fileGameStore) get(id string) (*GameRecord, bool, error) {
+  records, err := fs.list()
+  if err != nil {
+    return nil, false, err
+  }
+  for _, record := range records {
+    if record.Id == id {
+      return record, true, nil
+    }
+  }
+  return nil, false, nil
+}
+
+// ==== Handlers ====
+
+func getGameRecordsHandler(games GameStore) http.Handler {
+  fn := func (w http.ResponseWriter, r *http.Request) {
+    records, err := games.list()
+    if err != nil {
+      requestLogger(r).Error("Error listing games", "err", err)
+      http.Error(w, "can't list games", http.StatusInternalServerError)
+      return
+    }
+    summaries := make([]gameRecordSummary, len(records))
+    for i, record := range records {
+      summaries[i] = record.summary()
+    }
+    writeJson(w, r, http.StatusOK, summaries)
+  }
+  return http.HandlerFunc(fn)
+}
+
+// GET /records/{id} returns the record as JSON, /records/{id}/cgn in game notation.
+func getGameRecordHandler(games GameStore) http.Handler {
+  fn := func (w http.ResponseWriter, r *http.Request) {
+    id := mux.Vars(r)["id"]
+    record, ok, err := games.get(id)
+    if err != nil {
+      requestLogger(r).Error("Error fetching game", "game", id, "err", err)
+      http.Error(w, "can't fetch game", http.StatusInternalServerError)
+      return
+    }
+    if !ok {
+      http.Error(w, fmt.Sprintf("no saved game with id %s", id), http.StatusNotFound)
+      return
+    }
+    if mux.Vars(r)["format"] == "cgn" {
+      notation, err := record.toNotation()
+      if err != nil {
+        requestLogger(r).Error("Error writing game in game notation", "game", id, "err", err)
+        http.Error(w, "can't write game", http.StatusInternalServerError)
+        return
+      }
+      w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+      fmt.Fprint(w, notation)
+      return
+    }
+    writeJson(w, r, http.StatusOK, record)
+  }
+  return http.HandlerFunc(fn)
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+  "encoding/json"
+  "fmt"
+  "io/ioutil"
+  "log/slog"
+  "net/http"
+  "net/http/httptest"
+  "path/filepath"
+  "testing"
+)
+
+func TestFileGameStore(t *testing.T) {
+  fmt.Println("starting TestFileGameStore")
+  games := createFileGameStore(filepath.Join(t.TempDir(), "games.jsonl"))
+  if records, err := games.list(); err != nil || len(records) != 0 {
+    t.Fatalf("Expected no games in a new store: %+v, %v", records, err)
+  }
+
+  first := createGameRecord("first", initGame(), map[string]string{"p1": "human", "p2": "perfect"})
+  second := createGameRecord("second", initGame(), map[string]string{"p1": "easy", "p2": "hard"})
+  for _, record := range []*GameRecord{first, second} {
+    if err := games.save(record); err != nil {
+      t.Fatal(err.Error())
+    }
+  }
+  // Saving again replaces the first record.
+  after := GameState{Player{1, 1}, Player{2, 1}, Player2}
+  first.addMove(Move{Left, Left}, &after)
+  if err := games.save(first); err != nil {
+    t.Fatal(err.Error())
+  }
+
+  records, err := games.list()
+  if err != nil {
+    t.Fatal(err.Error())
+  }
+  if len(records) != 2 || records[0].Id != "first" || records[1].Id != "second" {
+    t.Fatalf("Unexpected records: %+v", records)
+  }
+  if len(records[0].Moves) != 1 || !records[0].Moves[0].State.equals(&after) || records[0].Players["p2"] != "perfect" {
+    t.Fatalf("Unexpected record: %+v", records[0])
+  }
+
+  if record, ok, err := games.get("second"); err != nil || !ok || record.Players["p1"] != "easy" {
+    t.Fatalf("Unexpected record: %+v, %v, %v", record, ok, err)
+  }
+  if _, ok, err := games.get("third"); err != nil || ok {
+    t.Fatalf("Expected no third game: %v, %v", ok, err)
+  }
+}
+
+func TestGameRecordResult(t *testing.T) {
+  fmt.Println("starting TestGameRecordResult")
+  start := GameState{Player{1, 0}, Player{4, 0}, Player1}
+  record := createGameRecord("id", &start, map[string]string{})
+  if record.StartPosition != "10/40 w" || record.NumFingers != NUM_FINGERS || record.Result != Ongoing {
+    t.Fatalf("Unexpected record: %+v", record)
+  }
+  after := GameState{Player{1, 0}, Player{0, 0}, Player2}
+  record.addMove(Move{Left, Left}, &after)
+  if record.Result != Player1Wins || record.EndTime.IsZero() {
+    t.Fatalf("Expected a finished game: %+v", record)
+  }
+}
+
+func TestFinishedGamesGetSaved(t *testing.T) {
+  fmt.Println("starting TestFinishedGamesGetSaved")
+  _, visitedStates, _, _, _, err := solve(initGame(), DEFAULT_MAX_DEPTH)
+  if err != nil {
+    t.Fatal(err.Error())
+  }
+  graph := createSolveGraph(visitedStates, DEFAULT_MAX_DEPTH, slog.Default())
+  games := createFileGameStore(filepath.Join(t.TempDir(), "games.jsonl"))
+  server := httptest.NewServer(createRouter(initGame(), graph, createSessionStore(games, slog.Default()), createLobbyStore(slog.Default()), nil, getFrontendHandler(embeddedFrontend(), false), slog.Default()))
+  defer server.Close()
+
+  // Random games can cycle forever once both Players are down to one hand, so start from a position where Player 1's
+  // only Move wins.
+  var game gameSessionView
+  postJson(t, server.URL + "/games", `{"auto": true, "difficulty": "random", "position": "10/40 w", "allowUnreachable": true}`, http.StatusCreated, &game)
+  var next NextStateAndMove
+  moves := 0
+  for moves == 0 || checkGameResult(&next.NextState) == Ongoing {
+    postJson(t, server.URL + "/games/" + game.Id + "/computer-move", "", http.StatusOK, &next)
+    moves++
+  }
+
+  resp, err := http.Get(server.URL + "/records")
+  if err != nil {
+    t.Fatal(err.Error())
+  }
+  defer resp.Body.Close()
+  var summaries []gameRecordSummary
+  if err := json.NewDecoder(resp.Body).Decode(&summaries); err != nil {
+    t.Fatal(err.Error())
+  }
+  if len(summaries) != 1 || summaries[0].Id != game.Id || summaries[0].NumMoves != moves || summaries[0].Players["p1"] != "random" {
+    t.Fatalf("Unexpected summaries: %+v", summaries)
+  }
+
+  recordResp, err := http.Get(server.URL + "/records/" + game.Id)
+  if err != nil {
+    t.Fatal(err.Error())
+  }
+  defer recordResp.Body.Close()
+  var record GameRecord
+  if err := json.NewDecoder(recordResp.Body).Decode(&record); err != nil {
+    t.Fatal(err.Error())
+  }
+  if record.Result != checkGameResult(&next.NextState) || !record.Moves[len(record.Moves) - 1].State.equals(&next.NextState) {
+    t.Fatalf("Unexpected record: %+v", record)
+  }
+
+  cgnResp, err := http.Get(server.URL + "/records/" + game.Id + "/cgn")
+  if err != nil {
+    t.Fatal(err.Error())
+  }
+  defer cgnResp.Body.Close()
+  notation, err := ioutil.ReadAll(cgnResp.Body)
+  if err != nil {
+    t.Fatal(err.Error())
+  }
+  parsed, err := parseNotation(string(notation))
+  if err != nil {
+    t.Fatalf("Can't parse %s: %v", notation, err)
+  }
+  if parsed.Id != game.Id || len(parsed.Moves) != moves || parsed.Result != record.Result {
+    t.Fatalf("Unexpected game from notation: %+v", parsed)
+  }
+
+  missing, err := http.Get(server.URL + "/records/nope")
+  if err != nil {
+    t.Fatal(err.Error())
+  }
+  missing.Body.Close()
+  if missing.StatusCode != http.StatusNotFound {
+    t.Fatalf("Expected a 404 for a missing game, got %d", missing.StatusCode)
+  }
+}
@@ -1,13 +1,20 @@
 module test.com/chopsticks
 
-go 1.17
+go 1.21
+
+require (
+	github.com/gorilla/mux v1.8.0
+	github.com/gorilla/websocket v1.5.0
+	github.com/urfave/cli v1.22.5
+	golang.org/x/crypto v0.9.0
+	golang.org/x/term v0.10.0
+)
 
 require (
 	github.com/cpuguy83/go-md2man/v2 v2.0.0-20190314233015-f79a8a8ca69d // indirect
 	github.com/google/go-cmp v0.5.6 // indirect
-	github.com/gorilla/mux v1.8.0 // indirect
 	github.com/pkg/errors v0.9.1 // indirect
 	github.com/russross/blackfriday/v2 v2.0.1 // indirect
 	github.com/shurcooL/sanitized_anchor_name v1.0.0 // indirect
-	github.com/urfave/cli v1.22.5 // indirect
+	golang.org/x/sys v0.10.0 // indirect
 )
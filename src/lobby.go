@@ -0,0 +1,459 @@
+package main
+
+import (
+  "crypto/rand"
+  "fmt"
+  "log/slog"
+  "net/http"
+  "strings"
+  "sync"
+  "time"
+
+  "github.com/gorilla/mux"
+  "github.com/gorilla/websocket"
+)
+
+// Lobbies let two humans play each other over the network. One Player creates a lobby and shares its code, the other
+// joins with it, then both connect a WebSocket to send Moves and get every Move broadcast back. The game itself is a
+// regular gameSession (with no engines), so the server checks legality and whose turn it is.
+
+// No 0/O or 1/I, codes get read out loud.
+const LOBBY_CODE_CHARS = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+const LOBBY_CODE_LENGTH = 6
+const WS_WRITE_TIMEOUT = 10 * time.Second
+
+type lobbyConn struct {
+  // gorilla/websocket allows only one concurrent writer.
+  mu sync.Mutex
+  ws *websocket.Conn
+}
+
+func (c *lobbyConn) send(msg *lobbyMessage) error {
+  c.mu.Lock()
+  defer c.mu.Unlock()
+  c.ws.SetWriteDeadline(time.Now().Add(WS_WRITE_TIMEOUT))
+  return c.ws.WriteJSON(msg)
+}
+
+// The connection's handler sees the socket close and cleans up after it.
+func (c *lobbyConn) close() {
+  closeMsg := websocket.FormatCloseMessage(websocket.CloseGoingAway, "server shutting down")
+  c.ws.WriteControl(websocket.CloseMessage, closeMsg, time.Now().Add(WS_WRITE_TIMEOUT))
+  c.ws.Close()
+}
+
+type lobbyPlayer struct {
+  name string
+  // Secret handed out when taking the seat, needed to connect (and reconnect) as this Player.
+  token string
+  // nil while the Player isn't connected.
+  conn *lobbyConn
+}
+
+type lobby struct {
+  mu sync.Mutex
+  code string
+  session *gameSession
+  players map[Turn]*lobbyPlayer
+  logger *slog.Logger
+}
+
+type lobbyPlayerView struct {
+  Name string
+  Connected bool
+}
+
+// What the API (and the "state" message) returns for a lobby.
+type lobbyView struct {
+  Code string
+  Game gameSessionView
+  // Seated Players keyed by "p1"/"p2"
+  Players map[string]lobbyPlayerView
+  Commentator bool
+}
+
+// Messages the server sends over the WebSocket.
+type lobbyMessage struct {
+  // state, move or error
+  Type string
+  Lobby *lobbyView `json:",omitempty"`
+  Event *gameEvent `json:",omitempty"`
+  Error string `json:",omitempty"`
+}
+
+// Messages Players send over the WebSocket.
+type lobbyClientMessage struct {
+  // Only "move" for now
+  Type string `json:"type"`
+  PlayerHand string `json:"playerHand"`
+  ReceiverHand string `json:"receiverHand"`
+}
+
+func createLobby(code string, session *gameSession, logger *slog.Logger) *lobby {
+  return &lobby{sync.Mutex{}, code, session, make(map[Turn]*lobbyPlayer, 2), logger}
+}
+
+func (l *lobby) view() *lobbyView {
+  l.mu.Lock()
+  defer l.mu.Unlock()
+  players := make(map[string]lobbyPlayerView, len(l.players))
+  for t, p := range l.players {
+    players[turnToUiString(t)] = lobbyPlayerView{p.name, p.conn != nil}
+  }
+  return &lobbyView{l.code, l.session.view(), players, l.session.commentator != nil}
+}
+
+// Seat a Player, on the given side if it's free. Returns the seat and the Player's token.
+func (l *lobby) seat(name string, side Turn) (Turn, string, error) {
+  l.mu.Lock()
+  defer l.mu.Unlock()
+  if _, taken := l.players[side]; taken {
+    side = invertTurn(side)
+    if _, taken := l.players[side]; taken {
+      return side, "", fmt.Errorf("lobby %s is full", l.code)
+    }
+  }
+  token, err := newSessionId()
+  if err != nil {
+    return side, "", err
+  }
+  l.players[side] = &lobbyPlayer{name, token, nil}
+  l.session.setPlayerName(side, name)
+  return side, token, nil
+}
+
+func (l *lobby) isFull() bool {
+  l.mu.Lock()
+  defer l.mu.Unlock()
+  return len(l.players) == 2
+}
+
+// Attach a connection to the seat with the given token. A Player reconnecting replaces (and closes) their old
+// connection.
+func (l *lobby) connect(token string, conn *lobbyConn) (Turn, bool) {
+  l.mu.Lock()
+  defer l.mu.Unlock()
+  for t, p := range l.players {
+    if p.token == token {
+      if p.conn != nil {
+        p.conn.ws.Close()
+      }
+      p.conn = conn
+      return t, true
+    }
+  }
+  return Player1, false
+}
+
+// Detach the connection from its seat, unless the Player already reconnected on a new one.
+func (l *lobby) disconnect(t Turn, conn *lobbyConn) {
+  l.mu.Lock()
+  defer l.mu.Unlock()
+  if p, ok := l.players[t]; ok && p.conn == conn {
+    p.conn = nil
+  }
+}
+
+func (l *lobby) hasToken(token string) bool {
+  l.mu.Lock()
+  defer l.mu.Unlock()
+  for _, p := range l.players {
+    if p.token == token {
+      return true
+    }
+  }
+  return false
+}
+
+func (l *lobby) connections() []*lobbyConn {
+  l.mu.Lock()
+  defer l.mu.Unlock()
+  conns := make([]*lobbyConn, 0, 2)
+  for _, p := range l.players {
+    if p.conn != nil {
+      conns = append(conns, p.conn)
+    }
+  }
+  return conns
+}
+
+// Let everyone connected know who's in the lobby and where the game stands.
+func (l *lobby) broadcastState() {
+  msg := &lobbyMessage{Type: "state", Lobby: l.view()}
+  for _, conn := range l.connections() {
+    if err := conn.send(msg); err != nil {
+      l.logger.Warn("Error sending lobby state", "lobby", l.code, "err", err)
+    }
+  }
+}
+
+type lobbyStore struct {
+  mu sync.Mutex
+  lobbies map[string]*lobby
+  logger *slog.Logger
+}
+
+func createLobbyStore(logger *slog.Logger) *lobbyStore {
+  return &lobbyStore{sync.Mutex{}, make(map[string]*lobby), logger}
+}
+
+// Adds the lobby under a fresh code and returns it.
+func (ls *lobbyStore) add(session *gameSession) (*lobby, error) {
+  ls.mu.Lock()
+  defer ls.mu.Unlock()
+  for {
+    code, err := newLobbyCode()
+    if err != nil {
+      return nil, err
+    }
+    if _, taken := ls.lobbies[code]; !taken {
+      l := createLobby(code, session, ls.logger)
+      ls.lobbies[code] = l
+      return l, nil
+    }
+  }
+}
+
+func (ls *lobbyStore) get(code string) (*lobby, bool) {
+  ls.mu.Lock()
+  defer ls.mu.Unlock()
+  l, ok := ls.lobbies[strings.ToUpper(code)]
+  return l, ok
+}
+
+// Tells everyone connected that the server is going away and hangs up, for shutting down.
+func (ls *lobbyStore) closeConnections() {
+  ls.mu.Lock()
+  lobbies := make([]*lobby, 0, len(ls.lobbies))
+  for _, l := range ls.lobbies {
+    lobbies = append(lobbies, l)
+  }
+  ls.mu.Unlock()
+  for _, l := range lobbies {
+    for _, conn := range l.connections() {
+      conn.close()
+    }
+  }
+}
+
+func newLobbyCode() (string, error) {
+  codeBytes := make([]byte, LOBBY_CODE_LENGTH)
+  if _, err := rand.Read(codeBytes); err != nil {
+    return "", err
+  }
+  for i, b := range codeBytes {
+    codeBytes[i] = LOBBY_CODE_CHARS[int(b) % len(LOBBY_CODE_CHARS)]
+  }
+  return string(codeBytes), nil
+}
+
+// ==== Handlers ====
+
+type createLobbyRequest struct {
+  Name string `json:"name"`
+  // first, second or random
+  Side string `json:"side"`
+  Position string `json:"position"`
+  AllowUnreachable bool `json:"allowUnreachable"`
+  // Annotate every Move with its quality according to the solve graph.
+  Commentator bool `json:"commentator"`
+}
+
+type joinLobbyRequest struct {
+  Name string `json:"name"`
+}
+
+// Returned to a Player taking a seat. The token is needed to connect to the lobby.
+type lobbySeat struct {
+  Code string
+  Seat string
+  Token string
+  Lobby *lobbyView
+}
+
+func getCreateLobbyHandler(lobbies *lobbyStore, sessions *sessionStore, graph *solveGraph, auth *authenticator) http.Handler {
+  fn := func (w http.ResponseWriter, r *http.Request) {
+    var req createLobbyRequest
+    if err := readJsonBody(r, &req); err != nil {
+      http.Error(w, "can't read body: " + err.Error(), http.StatusBadRequest)
+      return
+    }
+    start, err := startPosition(req.Position, req.AllowUnreachable)
+    if err != nil {
+      http.Error(w, err.Error(), http.StatusBadRequest)
+      return
+    }
+    name, err := playerNameForRequest(auth, r, req.Name)
+    if err != nil {
+      http.Error(w, err.Error(), http.StatusBadRequest)
+      return
+    }
+    side := req.Side
+    if side == "" {
+      side = "first"
+    }
+    seat, err := parseSide(side)
+    if err != nil {
+      http.Error(w, err.Error(), http.StatusBadRequest)
+      return
+    }
+    id, err := newSessionId()
+    if err != nil {
+      http.Error(w, "can't create game", http.StatusInternalServerError)
+      return
+    }
+    session := createGameSession(id, start, map[Turn]Engine{Player1: nil, Player2: nil})
+    session.remote = true
+    if req.Commentator {
+      session.commentator = graph
+    }
+    l, err := lobbies.add(session)
+    if err != nil {
+      http.Error(w, "can't create lobby", http.StatusInternalServerError)
+      return
+    }
+    seat, token, err := l.seat(name, seat)
+    if err != nil {
+      http.Error(w, "can't create lobby", http.StatusInternalServerError)
+      return
+    }
+    sessions.add(session)
+    writeJson(w, r, http.StatusCreated, &lobbySeat{l.code, turnToUiString(seat), token, l.view()})
+  }
+  return http.HandlerFunc(fn)
+}
+
+func getLobbyOr404(lobbies *lobbyStore, w http.ResponseWriter, r *http.Request) (*lobby, bool) {
+  code := mux.Vars(r)["code"]
+  l, ok := lobbies.get(code)
+  if !ok {
+    http.Error(w, fmt.Sprintf("no lobby with code %s", code), http.StatusNotFound)
+  }
+  return l, ok
+}
+
+func getJoinLobbyHandler(lobbies *lobbyStore, auth *authenticator) http.Handler {
+  fn := func (w http.ResponseWriter, r *http.Request) {
+    l, ok := getLobbyOr404(lobbies, w, r)
+    if !ok {
+      return
+    }
+    var req joinLobbyRequest
+    if err := readJsonBody(r, &req); err != nil {
+      http.Error(w, "can't read body: " + err.Error(), http.StatusBadRequest)
+      return
+    }
+    name, err := playerNameForRequest(auth, r, req.Name)
+    if err != nil {
+      http.Error(w, err.Error(), http.StatusBadRequest)
+      return
+    }
+    if l.isFull() {
+      http.Error(w, fmt.Sprintf("lobby %s is full", l.code), http.StatusConflict)
+      return
+    }
+    seat, token, err := l.seat(name, Player1)
+    if err != nil {
+      // Someone else took the last seat in the meantime.
+      http.Error(w, err.Error(), http.StatusConflict)
+      return
+    }
+    l.broadcastState()
+    writeJson(w, r, http.StatusOK, &lobbySeat{l.code, turnToUiString(seat), token, l.view()})
+  }
+  return http.HandlerFunc(fn)
+}
+
+func getLobbyHandler(lobbies *lobbyStore) http.Handler {
+  fn := func (w http.ResponseWriter, r *http.Request) {
+    if l, ok := getLobbyOr404(lobbies, w, r); ok {
+      writeJson(w, r, http.StatusOK, l.view())
+    }
+  }
+  return http.HandlerFunc(fn)
+}
+
+var upgrader = websocket.Upgrader{}
+
+// GET /lobbies/{code}/ws?token=... connects a seated Player. The server sends a "state" message on connect and
+// whenever someone (dis)connects, a "move" message for every Move, and an "error" message for rejected Moves.
+func getLobbySocketHandler(lobbies *lobbyStore) http.Handler {
+  fn := func (w http.ResponseWriter, r *http.Request) {
+    l, ok := getLobbyOr404(lobbies, w, r)
+    if !ok {
+      return
+    }
+    token := r.URL.Query().Get("token")
+    if !l.hasToken(token) {
+      http.Error(w, "invalid token", http.StatusForbidden)
+      return
+    }
+    ws, err := upgrader.Upgrade(w, r, nil)
+    if err != nil {
+      // The upgrader already replied.
+      requestLogger(r).Warn("Error upgrading lobby connection", "lobby", l.code, "err", err)
+      return
+    }
+    conn := &lobbyConn{ws: ws}
+    seat, ok := l.connect(token, conn)
+    if !ok {
+      ws.Close()
+      return
+    }
+    defer func() {
+      ws.Close()
+      l.disconnect(seat, conn)
+      l.broadcastState()
+    }()
+
+    // Subscribe before sending the state, so no Move falls in between.
+    events, unsubscribe := l.session.subscribe()
+    defer unsubscribe()
+    done := make(chan struct{})
+    defer close(done)
+    go forwardLobbyEvents(conn, events, done, requestLogger(r))
+    l.broadcastState()
+
+    for {
+      var msg lobbyClientMessage
+      if err := ws.ReadJSON(&msg); err != nil {
+        if !websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+          requestLogger(r).Debug("Lobby connection closed", "lobby", l.code, "seat", turnToString(seat), "err", err)
+        }
+        return
+      }
+      if err := handleLobbyMessage(l, seat, &msg); err != nil {
+        if !isIllegalMoveError(err) {
+          requestLogger(r).Error("Error handling lobby message", "lobby", l.code, "type", msg.Type, "err", err)
+        }
+        conn.send(&lobbyMessage{Type: "error", Error: err.Error()})
+      }
+    }
+  }
+  return http.HandlerFunc(fn)
+}
+
+func forwardLobbyEvents(conn *lobbyConn, events chan gameEvent, done chan struct{}, logger *slog.Logger) {
+  for {
+    select {
+    case ev := <-events:
+      if err := conn.send(&lobbyMessage{Type: "move", Event: &ev}); err != nil {
+        logger.Warn("Error sending move", "err", err)
+      }
+    case <-done:
+      return
+    }
+  }
+}
+
+func handleLobbyMessage(l *lobby, seat Turn, msg *lobbyClientMessage) error {
+  if msg.Type != "move" {
+    return &illegalMoveError{fmt.Sprintf("unknown message type %q", msg.Type)}
+  }
+  gameMove, err := parseUiHands(msg.PlayerHand, msg.ReceiverHand)
+  if err != nil {
+    return &illegalMoveError{err.Error()}
+  }
+  _, err = l.session.playMoveAs(seat, gameMove)
+  return err
+}
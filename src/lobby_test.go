@@ -0,0 +1,119 @@
+package main
+
+import (
+  "fmt"
+  "net/http"
+  "strings"
+  "testing"
+  "time"
+
+  "github.com/gorilla/websocket"
+)
+
+func dialLobby(t *testing.T, serverUrl string, code string, token string) *websocket.Conn {
+  url := "ws" + strings.TrimPrefix(serverUrl, "http") + "/lobbies/" + code + "/ws?token=" + token
+  ws, _, err := websocket.DefaultDialer.Dial(url, nil)
+  if err != nil {
+    t.Fatal(err.Error())
+  }
+  return ws
+}
+
+// Read messages until one of the given type shows up, other Players (dis)connecting sends extra state messages.
+func readLobbyMessage(t *testing.T, ws *websocket.Conn, msgType string) *lobbyMessage {
+  ws.SetReadDeadline(time.Now().Add(5 * time.Second))
+  for {
+    var msg lobbyMessage
+    if err := ws.ReadJSON(&msg); err != nil {
+      t.Fatal(err.Error())
+    }
+    if msg.Type == msgType {
+      return &msg
+    }
+  }
+}
+
+func TestLobbyCreateAndJoin(t *testing.T) {
+  fmt.Println("starting TestLobbyCreateAndJoin")
+  server, _ := createTestServer(t)
+  defer server.Close()
+
+  var host lobbySeat
+  postJson(t, server.URL + "/lobbies", `{"name": "alice", "side": "second"}`, http.StatusCreated, &host)
+  if host.Seat != "p2" || host.Token == "" || len(host.Code) != LOBBY_CODE_LENGTH {
+    t.Fatalf("Unexpected seat for the host: %+v", host)
+  }
+  var guest lobbySeat
+  postJson(t, server.URL + "/lobbies/" + strings.ToLower(host.Code) + "/join", `{"name": "bob"}`, http.StatusOK, &guest)
+  if guest.Seat != "p1" || guest.Token == host.Token {
+    t.Fatalf("Unexpected seat for the guest: %+v", guest)
+  }
+  if guest.Lobby.Players["p1"].Name != "bob" || guest.Lobby.Players["p2"].Name != "alice" {
+    t.Fatalf("Unexpected players: %+v", guest.Lobby.Players)
+  }
+  postJson(t, server.URL + "/lobbies/" + host.Code + "/join", `{"name": "carol"}`, http.StatusConflict, nil)
+  postJson(t, server.URL + "/lobbies/NOPE00/join", `{"name": "carol"}`, http.StatusNotFound, nil)
+
+  // Lobby games can't be played through the regular game API.
+  postJson(t, server.URL + "/games/" + host.Lobby.Game.Id + "/move", `{"playerHand": "lh", "receiverHand": "lh"}`, http.StatusBadRequest, nil)
+}
+
+func TestLobbyPlayOverWebSockets(t *testing.T) {
+  fmt.Println("starting TestLobbyPlayOverWebSockets")
+  server, _ := createTestServer(t)
+  defer server.Close()
+
+  var p1, p2 lobbySeat
+  postJson(t, server.URL + "/lobbies", `{"name": "alice", "commentator": true}`, http.StatusCreated, &p1)
+  postJson(t, server.URL + "/lobbies/" + p1.Code + "/join", `{"name": "bob"}`, http.StatusOK, &p2)
+
+  if _, _, err := websocket.DefaultDialer.Dial("ws" + strings.TrimPrefix(server.URL, "http") + "/lobbies/" + p1.Code + "/ws?token=bad", nil); err == nil {
+    t.Fatal("Expected an error connecting with a bad token")
+  }
+
+  ws1 := dialLobby(t, server.URL, p1.Code, p1.Token)
+  defer ws1.Close()
+  readLobbyMessage(t, ws1, "state")
+  ws2 := dialLobby(t, server.URL, p1.Code, p2.Token)
+  defer ws2.Close()
+  state := readLobbyMessage(t, ws2, "state")
+  if !state.Lobby.Players["p1"].Connected || !state.Lobby.Players["p2"].Connected {
+    t.Fatalf("Expected both players to be connected: %+v", state.Lobby)
+  }
+
+  // Player 2 can't move on Player 1's turn.
+  ws2.WriteJSON(&lobbyClientMessage{"move", "lh", "lh"})
+  if msg := readLobbyMessage(t, ws2, "error"); !strings.Contains(msg.Error, "not your turn") {
+    t.Fatalf("Unexpected error: %+v", msg)
+  }
+
+  ws1.WriteJSON(&lobbyClientMessage{"move", "lh", "rh"})
+  expected := GameState{Player{1, 1}, Player{1, 2}, Player2}
+  for _, ws := range []*websocket.Conn{ws1, ws2} {
+    msg := readLobbyMessage(t, ws, "move")
+    if msg.Event.MoveNumber != 1 || msg.Event.Player != "p1" || !msg.Event.State.equals(&expected) {
+      t.Fatalf("Unexpected move event: %+v", msg.Event)
+    }
+    if msg.Event.Comment == nil || msg.Event.Comment.Quality == "" {
+      t.Fatalf("Expected the commentator to annotate the move: %+v", msg.Event)
+    }
+  }
+
+  // Reconnecting replaces the old connection, and the game carries on.
+  ws1Again := dialLobby(t, server.URL, p1.Code, p1.Token)
+  defer ws1Again.Close()
+  state = readLobbyMessage(t, ws1Again, "state")
+  if !state.Lobby.Game.State.equals(&expected) {
+    t.Fatalf("Unexpected state after reconnecting: %+v", state.Lobby.Game)
+  }
+  ws1.SetReadDeadline(time.Now().Add(5 * time.Second))
+  for {
+    if _, _, err := ws1.ReadMessage(); err != nil {
+      break
+    }
+  }
+  ws2.WriteJSON(&lobbyClientMessage{"move", "rh", "lh"})
+  if msg := readLobbyMessage(t, ws1Again, "move"); msg.Event.MoveNumber != 2 || msg.Event.Player != "p2" {
+    t.Fatalf("Unexpected move event: %+v", msg.Event)
+  }
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+  "bufio"
+  "context"
+  "errors"
+  "fmt"
+  "io"
+  "log"
+  "log/slog"
+  "net"
+  "net/http"
+  "strings"
+  "time"
+)
+
+// Logs go to stderr through log/slog, as text or as JSON for log collectors, so stdout is left for what commands
+// print. The solver logs each position it looks at on the debug level, and only sums up at info. Every server
+// request gets an ID, sent back in X-Request-Id, and handlers log through requestLogger so their lines carry it.
+
+const DEFAULT_LOG_FORMAT = "text"
+var LOG_FORMATS []string = []string{"text", "json"}
+const REQUEST_ID_HEADER = "X-Request-Id"
+// Longer incoming request IDs are replaced, so clients can't fill the logs with them.
+const MAX_REQUEST_ID_LENGTH = 64
+
+// Each part of the program is given a logger tagged with its component, so their lines can be told apart.
+const SOLVER_COMPONENT = "solver"
+const SERVER_COMPONENT = "server"
+
+// debug, info, warn or error.
+func parseLogLevel(level string) (slog.Level, error) {
+  var l slog.Level
+  if err := l.UnmarshalText([]byte(level)); err != nil || strings.ContainsAny(level, "+-") {
+    return l, fmt.Errorf("Unknown log level %s, must be one of: %s", level, strings.Join(LOG_LEVELS, ", "))
+  }
+  return l, nil
+}
+
+func createLogHandler(w io.Writer, level slog.Level, format string) slog.Handler {
+  options := &slog.HandlerOptions{Level: level}
+  if format == "json" {
+    return slog.NewJSONHandler(w, options)
+  }
+  return slog.NewTextHandler(w, options)
+}
+
+func createLogger(w io.Writer, level slog.Level, format string) *slog.Logger {
+  return slog.New(createLogHandler(w, level, format))
+}
+
+// Returns a logger writing to w and makes it the default, for code that isn't handed one. Uses of the log package go
+// there too, on the info level.
+func configureLogging(w io.Writer, level slog.Level, format string) *slog.Logger {
+  logger := createLogger(w, level, format)
+  slog.SetDefault(logger)
+  log.SetFlags(0)
+  return logger
+}
+
+func componentLogger(logger *slog.Logger, component string) *slog.Logger {
+  return logger.With("component", component)
+}
+
+// Whether debug lines are logged, for checks that are only worth their time while debugging.
+func debugEnabled(logger *slog.Logger) bool {
+  return logger.Enabled(context.Background(), slog.LevelDebug)
+}
+
+// A log value that's only worked out if the line is logged, for node and graph descriptions that are slow to build.
+type lazyLogValue func() string
+
+func (v lazyLogValue) LogValue() slog.Value {
+  return slog.StringValue(v())
+}
+
+// ==== Requests ====
+
+type requestLoggerKey struct{}
+
+// The logger for a request, with its ID. Outside of requests it's the default logger.
+func requestLogger(r *http.Request) *slog.Logger {
+  if logger, ok := r.Context().Value(requestLoggerKey{}).(*slog.Logger); ok {
+    return logger
+  }
+  return slog.Default()
+}
+
+// The client's request ID, so a request can be followed through a proxy, or a new one.
+func requestIdFromRequest(r *http.Request) (string, error) {
+  if id := r.Header.Get(REQUEST_ID_HEADER); id != "" && len(id) <= MAX_REQUEST_ID_LENGTH {
+    return id, nil
+  }
+  return newSessionId()
+}
+
+// Remembers the status a handler replied with, for the request's log line.
+type statusRecorder struct {
+  http.ResponseWriter
+  status int
+}
+
+func (rec *statusRecorder) WriteHeader(status int) {
+  rec.status = status
+  rec.ResponseWriter.WriteHeader(status)
+}
+
+// Game events stream through Flush.
+func (rec *statusRecorder) Flush() {
+  if flusher, ok := rec.ResponseWriter.(http.Flusher); ok {
+    flusher.Flush()
+  }
+}
+
+// Lobby websockets take over the connection.
+func (rec *statusRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+  hijacker, ok := rec.ResponseWriter.(http.Hijacker)
+  if !ok {
+    return nil, nil, errors.New("response can't be hijacked")
+  }
+  rec.status = http.StatusSwitchingProtocols
+  return hijacker.Hijack()
+}
+
+// Gives each request an ID and a logger with it, and logs the request once it's been handled.
+func requestLoggingMiddleware(serverLogger *slog.Logger) func(http.Handler) http.Handler {
+  return func(next http.Handler) http.Handler {
+    return requestLoggingHandler(next, serverLogger)
+  }
+}
+
+func requestLoggingHandler(next http.Handler, serverLogger *slog.Logger) http.Handler {
+  fn := func (w http.ResponseWriter, r *http.Request) {
+    id, err := requestIdFromRequest(r)
+    if err != nil {
+      serverLogger.Error("Error creating request id", "err", err)
+      http.Error(w, "can't handle request", http.StatusInternalServerError)
+      return
+    }
+    logger := serverLogger.With("request_id", id)
+    w.Header().Set(REQUEST_ID_HEADER, id)
+    rec := &statusRecorder{w, http.StatusOK}
+    start := time.Now()
+    next.ServeHTTP(rec, r.WithContext(context.WithValue(r.Context(), requestLoggerKey{}, logger)))
+    logger.Info("Handled request", "method", r.Method, "path", r.URL.Path, "status", rec.status,
+      "duration", time.Since(start))
+  }
+  return http.HandlerFunc(fn)
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+  "bytes"
+  "encoding/json"
+  "fmt"
+  "log/slog"
+  "net/http"
+  "net/http/httptest"
+  "strings"
+  "testing"
+)
+
+// A logger that writes to the returned buffer.
+func captureLogs(level slog.Level, format string) (*slog.Logger, *bytes.Buffer) {
+  var logs bytes.Buffer
+  return createLogger(&logs, level, format), &logs
+}
+
+func decodeLogLines(t *testing.T, logs *bytes.Buffer) []map[string]interface{} {
+  lines := []map[string]interface{}{}
+  for _, line := range strings.Split(strings.TrimSpace(logs.String()), "\n") {
+    if line == "" {
+      continue
+    }
+    var fields map[string]interface{}
+    if err := json.Unmarshal([]byte(line), &fields); err != nil {
+      t.Fatalf("Log line isn't json: %s", line)
+    }
+    lines = append(lines, fields)
+  }
+  return lines
+}
+
+func TestLogLevels(t *testing.T) {
+  fmt.Println("starting TestLogLevels")
+  for _, level := range LOG_LEVELS {
+    if _, err := parseLogLevel(level); err != nil {
+      t.Fatal(err.Error())
+    }
+  }
+  for _, bad := range []string{"quiet", "info+2", ""} {
+    if _, err := parseLogLevel(bad); err == nil {
+      t.Fatalf("Expected an error for %q", bad)
+    }
+  }
+
+  logger, logs := captureLogs(slog.LevelInfo, "text")
+  solverLogger := componentLogger(logger, SOLVER_COMPONENT)
+  evaluated := false
+  solverLogger.Debug("Exploring node", "node", lazyLogValue(func() string {
+    evaluated = true
+    return "node"
+  }))
+  if evaluated || logs.Len() != 0 || debugEnabled(logger) {
+    t.Fatalf("Expected debug lines to be skipped on the info level: %s", logs.String())
+  }
+  solverLogger.Info("Generated Move tree", "nodes", 3)
+  if line := logs.String(); !strings.Contains(line, "component=solver") || !strings.Contains(line, "nodes=3") {
+    t.Fatalf("Unexpected log line: %s", line)
+  }
+}
+
+func TestJsonLogs(t *testing.T) {
+  fmt.Println("starting TestJsonLogs")
+  logger, logs := captureLogs(slog.LevelDebug, "json")
+  componentLogger(logger, SOLVER_COMPONENT).Debug("Exploring node", "node", lazyLogValue(func() string { return "11/11 w" }), "depth", 2)
+  lines := decodeLogLines(t, logs)
+  if len(lines) != 1 {
+    t.Fatalf("Expected one line: %s", logs.String())
+  }
+  line := lines[0]
+  if line["level"] != "DEBUG" || line["msg"] != "Exploring node" || line["component"] != "solver" ||
+    line["node"] != "11/11 w" || line["depth"] != float64(2) {
+    t.Fatalf("Unexpected log line: %+v", line)
+  }
+}
+
+// Solves log to the logger they're given, not the default one.
+func TestSolverLogger(t *testing.T) {
+  fmt.Println("starting TestSolverLogger")
+  logger, logs := captureLogs(slog.LevelInfo, "json")
+  if _, _, _, _, _, err := solveWithProgress(initGame(), DEFAULT_MAX_DEPTH, nil, logger); err != nil {
+    t.Fatal(err.Error())
+  }
+  lines := decodeLogLines(t, logs)
+  if len(lines) == 0 {
+    t.Fatal("Expected the solve to log")
+  }
+  for _, line := range lines {
+    if line["component"] != SOLVER_COMPONENT {
+      t.Fatalf("Expected solver lines: %+v", line)
+    }
+  }
+}
+
+func TestRequestIds(t *testing.T) {
+  fmt.Println("starting TestRequestIds")
+  logger, logs := captureLogs(slog.LevelInfo, "json")
+  handler := requestLoggingMiddleware(componentLogger(logger, SERVER_COMPONENT))(http.HandlerFunc(func (w http.ResponseWriter, r *http.Request) {
+    requestLogger(r).Info("Handling")
+    w.WriteHeader(http.StatusTeapot)
+  }))
+
+  w := httptest.NewRecorder()
+  handler.ServeHTTP(w, httptest.NewRequest("GET", "/teapot", nil))
+  id := w.Header().Get(REQUEST_ID_HEADER)
+  if id == "" {
+    t.Fatal("Expected a request id")
+  }
+  lines := decodeLogLines(t, logs)
+  if len(lines) != 2 {
+    t.Fatalf("Expected the handler's line and the request's: %s", logs.String())
+  }
+  for _, line := range lines {
+    if line["request_id"] != id || line["component"] != "server" {
+      t.Fatalf("Expected request id %s: %+v", id, line)
+    }
+  }
+  if done := lines[1]; done["method"] != "GET" || done["path"] != "/teapot" || done["status"] != float64(http.StatusTeapot) {
+    t.Fatalf("Unexpected request line: %+v", done)
+  }
+
+  // The client's id is kept, unless it's too long.
+  logs.Reset()
+  r := httptest.NewRequest("GET", "/teapot", nil)
+  r.Header.Set(REQUEST_ID_HEADER, "from-the-proxy")
+  w = httptest.NewRecorder()
+  handler.ServeHTTP(w, r)
+  if w.Header().Get(REQUEST_ID_HEADER) != "from-the-proxy" {
+    t.Fatalf("Expected the client's request id, got %s", w.Header().Get(REQUEST_ID_HEADER))
+  }
+  r.Header.Set(REQUEST_ID_HEADER, strings.Repeat("a", MAX_REQUEST_ID_LENGTH + 1))
+  w = httptest.NewRecorder()
+  handler.ServeHTTP(w, r)
+  if len(w.Header().Get(REQUEST_ID_HEADER)) > MAX_REQUEST_ID_LENGTH {
+    t.Fatal("Expected a long request id to be replaced")
+  }
+}
+
+func TestServerRequestIds(t *testing.T) {
+  fmt.Println("starting TestServerRequestIds")
+  server, _ := createTestServer(t)
+  defer server.Close()
+  resp, err := http.Get(server.URL + "/engines")
+  if err != nil {
+    t.Fatal(err.Error())
+  }
+  resp.Body.Close()
+  if resp.Header.Get(REQUEST_ID_HEADER) == "" {
+    t.Fatal("Expected the server to send a request id")
+  }
+}
@@ -1,8 +1,8 @@
 package main
 
 import (
-  "fmt"
   "log"
+  "log/slog"
 )
 
 // Node for the loop metagraphs on top of the PlayNode graph
@@ -111,7 +111,7 @@ func getAllExitNodes(loopGraphs map[*loopGraph]int) map[*loopGraph]map[*PlayNode
   return graphsToExitNodes
 }
 
-func invertExitNodesMap(loopsToExitNodes map[*loopGraph]map[*PlayNode]int) map[*PlayNode][]*loopGraph {
+func invertExitNodesMap(loopsToExitNodes map[*loopGraph]map[*PlayNode]int, logger *slog.Logger) map[*PlayNode][]*loopGraph {
   exitNodesToLoopGraph := make(map[*PlayNode][]*loopGraph, len(loopsToExitNodes)) // underestimates size
   for lg, exitNodes := range loopsToExitNodes {
     for exitNode, _ := range exitNodes {
@@ -120,9 +120,7 @@ func invertExitNodesMap(loopsToExitNodes map[*loopGraph]map[*PlayNode]int) map[*
       if ok {
         // TODO: how common is it to have multiple loop graphs for the same exit node?
         // It happens for num_fingers == 4....
-        if DEBUG {
-          fmt.Printf("Found exit node for %d loop graphs\n", len(existingLoops) + 1)
-        }
+        logger.Debug("Found exit node for several loop graphs", "loop_graphs", len(existingLoops) + 1)
         curLoops = append(existingLoops, lg)
       } else {
         curLoops = []*loopGraph{lg}
@@ -1,94 +1,746 @@
 package main
 
 import (
+  "encoding/json"
+  "errors"
   "fmt"
-  "log"
+  "log/slog"
   "os"
+  "strconv"
+  "strings"
   "github.com/urfave/cli"
   "time"
 )
 
-// const DEBUG bool = true
-var DEBUG bool = false
-const INFO bool = true
+// Set up by applyConfig from the flags and config file, and handed to the solver, server and engines. Until then
+// it's slog's default.
+var LOGGER *slog.Logger = slog.Default()
+
+func engineForFlag(c *cli.Context, flag string) (Engine, error) {
+  spec := c.String(flag)
+  if spec == "" {
+    spec = c.String("difficulty")
+  }
+  if spec == "" {
+    spec = DEFAULT_DIFFICULTY
+  }
+  return engineForSpec(spec, time.Now().UnixNano())
+}
+
+func cliPlayersFromFlags(c *cli.Context) (cliPlayers, error) {
+  players := cliPlayers{}
+  if c.Bool("auto") {
+    for t, flag := range map[Turn]string{Player1: "p1-difficulty", Player2: "p2-difficulty"} {
+      engine, err := engineForFlag(c, flag)
+      if err != nil {
+        players.close()
+        return nil, err
+      }
+      players[t] = engine
+    }
+    return players, nil
+  }
+  humanTurn, err := parseSide(c.String("side"))
+  if err != nil {
+    return nil, err
+  }
+  engine, err := engineForFlag(c, "difficulty")
+  if err != nil {
+    return nil, err
+  }
+  players[humanTurn] = nil
+  players[invertTurn(humanTurn)] = engine
+  return players, nil
+}
+
+var GAMES_FLAG = cli.StringFlag{
+  Name: "games",
+  Value: DEFAULT_GAMES_FILE,
+  Usage: "file to save finished games to, empty to not save them",
+}
+
+var ACCOUNTS_FLAG = cli.StringFlag{
+  Name: "accounts",
+  Value: DEFAULT_ACCOUNTS_FILE,
+  Usage: "file to keep player accounts in, empty to turn accounts off",
+}
+
+// The AccountStore for the --accounts flag, nil if accounts are turned off.
+func accountStoreFromFlags(c *cli.Context) AccountStore {
+  if c.String("accounts") == "" {
+    return nil
+  }
+  return createFileAccountStore(c.String("accounts"))
+}
+
+// Starts the external engines for the --engine flags, keyed by name. The ones that started are returned even if
+// another one didn't, so they can be closed.
+func botsFromFlags(c *cli.Context) (map[string]Engine, error) {
+  bots := make(map[string]Engine)
+  for _, flag := range c.StringSlice("engine") {
+    parts := strings.SplitN(flag, "=", 2)
+    if len(parts) != 2 || parts[0] == "" {
+      return bots, fmt.Errorf("Bad --engine %q, expected name=command", flag)
+    }
+    if bots[parts[0]] != nil {
+      return bots, fmt.Errorf("Two engines called %s", parts[0])
+    }
+    bot, err := startExternalEngine(parts[1], CONFIG.EngineProcesses, LOGGER)
+    if err != nil {
+      return bots, err
+    }
+    bots[parts[0]] = bot
+  }
+  return bots, nil
+}
+
+// The GameStore for the --games flag, nil if saving is turned off.
+func gameStoreFromFlags(c *cli.Context) GameStore {
+  if c.String("games") == "" {
+    return nil
+  }
+  return createFileGameStore(c.String("games"))
+}
+
+func printGameRecords(games GameStore) error {
+  records, err := games.list()
+  if err != nil {
+    return err
+  }
+  for _, record := range records {
+    fmt.Printf("%s  %s  %s vs %s  from %s  %d moves  %s\n", record.Id, record.StartTime.Format(time.RFC3339),
+      record.Players["p1"], record.Players["p2"], record.StartPosition, len(record.Moves), record.Result.toString())
+  }
+  return nil
+}
+
+// Prints the game in game notation, or as JSON.
+func printGameRecord(games GameStore, id string, asJson bool) error {
+  record, ok, err := games.get(id)
+  if err != nil {
+    return err
+  }
+  if !ok {
+    return errors.New("No saved game with id " + id)
+  }
+  if asJson {
+    recordJson, err := json.MarshalIndent(record, "", "  ")
+    if err != nil {
+      return err
+    }
+    fmt.Println(string(recordJson))
+    return nil
+  }
+  notation, err := record.toNotation()
+  if err != nil {
+    return err
+  }
+  fmt.Print(notation)
+  return nil
+}
+
+// The game to look at for replay and analyze: a file in game notation, or a saved game with --id.
+func recordFromArgs(c *cli.Context) (*GameRecord, error) {
+  if id := c.String("id"); id != "" {
+    record, ok, err := createFileGameStore(c.String("games")).get(id)
+    if err != nil {
+      return nil, err
+    }
+    if !ok {
+      return nil, errors.New("No saved game with id " + id)
+    }
+    return record, nil
+  } else if c.NArg() == 1 {
+    return readNotationFile(c.Args().First())
+  }
+  return nil, errors.New("Expected a file with a game in game notation, or --id")
+}
+
+var POSITION_REPORT_FLAGS = []cli.Flag{
+  cli.StringFlag{
+    Name: "position",
+    Usage: "the position, like \"11/11 w\": Player 1's hands / Player 2's hands, then w if Player 1 is to move or b for Player 2 (defaults to the start of the game)",
+  },
+  cli.StringFlag{
+    Name: "rules",
+    Usage: "the rules, e.g. fingers=" + strconv.Itoa(int(NUM_FINGERS)) + "; only the built-in rules are supported",
+  },
+  cli.BoolFlag{
+    Name: "allow-unreachable",
+    Usage: "allow a --position that can't come up in a normal game",
+  },
+  cli.BoolFlag{
+    Name: "json",
+    Usage: "print the result as JSON",
+  },
+}
+
+// Solves --position and reports on it. The solver's progress goes to stderr, so only the report ends up on stdout.
+func reportFromFlags(c *cli.Context) (positionReport, error) {
+  if err := checkRules(c.String("rules")); err != nil {
+    return positionReport{}, err
+  }
+  gs, err := reportStartPosition(c.String("position"), c.Bool("allow-unreachable"))
+  if err != nil {
+    return positionReport{}, err
+  }
+  graph, err := lazyGraphFromConfig(CONFIG, LOGGER)
+  if err != nil {
+    return positionReport{}, err
+  }
+  return reportPosition(graph, gs)
+}
+
+func printReport(report positionReport, asJson bool, text string) error {
+  if !asJson {
+    fmt.Println(text)
+    return nil
+  }
+  return printJson(report)
+}
+
+func printJson(v interface{}) error {
+  // Keep Moves like L>R readable.
+  encoder := json.NewEncoder(os.Stdout)
+  encoder.SetEscapeHTML(false)
+  encoder.SetIndent("", "  ")
+  return encoder.Encode(v)
+}
 
 func main() {
   app := &cli.App{
+    Flags: CONFIG_FLAGS,
+    Before: func(c *cli.Context) error {
+      cfg, err := configFromFlags(c)
+      if err != nil {
+        return err
+      }
+      applyConfig(cfg)
+      return nil
+    },
     Commands: []cli.Command{
       {
         Name:    "cli",
         Aliases: []string{"c"},
         Usage:   "play chopsticks on the cli",
+        Flags: []cli.Flag{
+          cli.StringFlag{
+            Name: "name",
+            Usage: "your name, to rate your games on the ladder",
+          },
+          cli.StringFlag{
+            Name: "side",
+            Value: "first",
+            Usage: "which side you play: first, second or random",
+          },
+          cli.BoolFlag{
+            Name: "auto",
+            Usage: "let the computer play both sides",
+          },
+          cli.StringFlag{
+            Name: "difficulty",
+            Value: DEFAULT_DIFFICULTY,
+            Usage: "computer difficulty or engine: " + strings.Join(ENGINE_SPEC_NAMES, ", "),
+          },
+          cli.StringFlag{
+            Name: "p1-difficulty",
+            Usage: "difficulty or engine for Player 1 with --auto (defaults to --difficulty)",
+          },
+          cli.StringFlag{
+            Name: "p2-difficulty",
+            Usage: "difficulty or engine for Player 2 with --auto (defaults to --difficulty)",
+          },
+          cli.StringFlag{
+            Name: "position",
+            Usage: "start from a position like \"11/11 w\": Player 1's hands / Player 2's hands, then w if Player 1 is to move or b for Player 2",
+          },
+          cli.BoolFlag{
+            Name: "allow-unreachable",
+            Usage: "allow a --position that can't come up in a normal game",
+          },
+          cli.BoolFlag{
+            Name: "tui",
+            Usage: "play full screen, picking hands with the arrow keys",
+          },
+          cli.BoolFlag{
+            Name: "eval-bar",
+            Usage: "with --tui, start with the evaluation bar showing who's winning (toggle it with e)",
+          },
+          GAMES_FLAG,
+        },
         Action: func(c *cli.Context) error {
-          gs := initGame()
+          players, err := cliPlayersFromFlags(c)
+          if err != nil {
+            return err
+          }
+          defer players.close()
+          if err := validatePlayerName(c.String("name")); err != nil {
+            return err
+          }
+          gs, err := startPosition(c.String("position"), c.Bool("allow-unreachable"))
+          if err != nil {
+            return err
+          }
           start := time.Now()
-          var stateNode, _, _, _, solveErr = solve(gs, DEFAULT_MAX_DEPTH)
-          duration := time.Since(start)
-          fmt.Println("Computed solve state in:") // 10s of ms, hot damn golang is fast
-          fmt.Println(duration)
+          graph, solveErr := lazyGraphFromConfig(CONFIG, LOGGER)
+          if solveErr == nil {
+            _, solveErr = graph.lookupOrSolve(gs)
+          }
+          componentLogger(LOGGER, SOLVER_COMPONENT).Info("Solved the start position", "duration", time.Since(start))
           if solveErr != nil {
             fmt.Println("Error when solving: " + solveErr.Error())
             return nil
           }
 
-          fmt.Println("Let's play a game of chopsticks! You be Player 1.")
-          gs.prettyPrint()
+          if players.isHuman(Player1) {
+            fmt.Println("Let's play a game of chopsticks! You be Player 1.")
+          } else if players.isHuman(Player2) {
+            fmt.Println("Let's play a game of chopsticks! You be Player 2.")
+          } else {
+            fmt.Printf("Let's watch a game of chopsticks: %s vs %s.\n", players.describe(Player1), players.describe(Player2))
+          }
 
-          gps := createGamePlayState(gs)
-          var gameResult GameResult
-          var err error = nil
-          for gameResult = checkGameResult(gps.state); gameResult == Ongoing; gameResult = checkGameResult(gps.state) {
-            if DEBUG {
-              if err := validateGpsAndNode(gps, stateNode); err != nil {
-                return err
-              }
-            }
-            if gps.state.T == Player1 {
-            // if false {
-              stateNode, err = runPlayerTurn(gps, stateNode)  
-            } else {
-              time.Sleep(1 * time.Second)
-              stateNode, err = runComputerTurn(gps, stateNode)  
-            }
+          if c.Bool("tui") {
+            t, err := createTui(os.Stdout, players, graph, gs, c.String("name"), gameStoreFromFlags(c))
             if err != nil {
               return err
             }
-            fmt.Printf("Cur state: %s\n", stateNode.toString())
+            t.computerDelay = 1 * time.Second
+            t.showEval = c.Bool("eval-bar")
+            return runTerminalTui(t)
           }
-
-          // Game over!
-          fmt.Println("Game over!")
-          if gameResult == Player1Wins {
-            fmt.Println("You win!")
-          } else {
-            fmt.Println("I win!")
+          r, err := createRepl(stdinLineReader(), os.Stdout, players, graph, gs, c.String("name"), gameStoreFromFlags(c))
+          if err != nil {
+            return err
           }
-          return nil
+          r.computerDelay = 1 * time.Second
+          return r.run()
         },
       },
       {
         Name:    "serve",
         Aliases: []string{"s"},
         Usage:   "play chopsticks with a browser",
+        Flags: append([]cli.Flag{
+          LISTEN_FLAG,
+          DEV_FLAG,
+          ASSET_DIR_FLAG,
+          GAMES_FLAG,
+          ACCOUNTS_FLAG,
+          cli.StringSliceFlag{
+            Name: "engine",
+            Usage: "an external engine games can play against, as name=command; can be given more than once",
+          },
+        }, TIMEOUT_FLAGS...),
         Action:  func(c *cli.Context) error {
-          gs := initGame()
-          start := time.Now()
-          var _, visitedStates, _, _, err = solve(gs, DEFAULT_MAX_DEPTH)
+          cfg := CONFIG
+          cfg.applyFlags(c)
+          if err := cfg.validateServer(); err != nil {
+            return err
+          }
+          bots, err := botsFromFlags(c)
+          defer func() {
+            for _, bot := range bots {
+              closeEngines([]Engine{bot})
+            }
+          }()
+          if err != nil {
+            return err
+          }
+          // The server answers health checks while the game is solved.
+          loadGraph := func() (*solveGraph, error) {
+            start := time.Now()
+            graph, err := solvedGraphFromConfig(cfg, LOGGER)
+            if err == nil {
+              componentLogger(LOGGER, SOLVER_COMPONENT).Info("Solved the game", "duration", time.Since(start)) // 10s of ms, hot damn golang is fast
+            }
+            return graph, err
+          }
+          return serve(initGame(), loadGraph, gameStoreFromFlags(c), accountStoreFromFlags(c), bots, cfg, LOGGER)
+        },
+      },
+      {
+        Name:    "replay",
+        Usage:   "step through a game in game notation, or a saved game with --id",
+        ArgsUsage: "<file>",
+        Flags: []cli.Flag{
+          cli.StringFlag{
+            Name: "id",
+            Usage: "replay the saved game with this id instead of a file",
+          },
+          GAMES_FLAG,
+        },
+        Action: func(c *cli.Context) error {
+          record, err := recordFromArgs(c)
+          if err != nil {
+            return err
+          }
+          graph, err := lazyGraphFromConfig(CONFIG, LOGGER)
+          if err != nil {
+            return err
+          }
+          steps, err := buildReplay(record, graph)
+          if err != nil {
+            return err
+          }
+          printReplay(record, steps)
+          return nil
+        },
+      },
+      {
+        Name:    "analyze",
+        Usage:   "find the blunders in a game in game notation, or a saved game with --id",
+        ArgsUsage: "<file>",
+        Flags: []cli.Flag{
+          cli.StringFlag{
+            Name: "id",
+            Usage: "analyze the saved game with this id instead of a file",
+          },
+          GAMES_FLAG,
+          cli.BoolFlag{
+            Name: "json",
+            Usage: "print the analysis as JSON",
+          },
+        },
+        Action: func(c *cli.Context) error {
+          record, err := recordFromArgs(c)
+          if err != nil {
+            return err
+          }
+          graph, err := lazyGraphFromConfig(CONFIG, LOGGER)
+          if err != nil {
+            return err
+          }
+          analysis, err := analyzeGame(record, graph)
+          if err != nil {
+            return err
+          }
+          if c.Bool("json") {
+            analysisJson, err := json.MarshalIndent(analysis, "", "  ")
+            if err != nil {
+              return err
+            }
+            fmt.Println(string(analysisJson))
+          } else {
+            fmt.Print(analysis.toString())
+          }
+          return nil
+        },
+      },
+      {
+        Name:    "bestmove",
+        Usage:   "print the best Move from a position, or none if the game is over (exit code " + strconv.Itoa(EXIT_GAME_OVER) + ")",
+        Flags: POSITION_REPORT_FLAGS,
+        Action: func(c *cli.Context) error {
+          report, err := reportFromFlags(c)
           if err != nil {
             return err
           }
-          duration := time.Since(start)
-          fmt.Println("Computed solve state in:") // 10s of ms, hot damn golang is fast
-          fmt.Println(duration)
-          serve(gs, visitedStates)
+          bestMove := report.BestMove
+          if bestMove == "" {
+            bestMove = "none"
+          }
+          if err := printReport(report, c.Bool("json"), bestMove); err != nil {
+            return err
+          }
+          if report.BestMove == "" {
+            return cli.NewExitError("The game is over", EXIT_GAME_OVER)
+          }
           return nil
         },
       },
+      {
+        Name:    "eval",
+        Usage:   "print who wins from a position with best play, and how fast",
+        Flags: POSITION_REPORT_FLAGS,
+        Action: func(c *cli.Context) error {
+          report, err := reportFromFlags(c)
+          if err != nil {
+            return err
+          }
+          return printReport(report, c.Bool("json"), report.toString())
+        },
+      },
+      {
+        Name:    "solve",
+        Usage:   "solve a position from scratch, ignoring the tablebase, and print who wins from it",
+        // All but --json.
+        Flags: append(POSITION_REPORT_FLAGS[:3:3],
+          cli.BoolFlag{
+            Name: "stats",
+            Usage: "also print what the solver did and how long each phase took",
+          },
+          cli.BoolFlag{
+            Name: "progress",
+            Usage: "print each phase of the solve, and the states explored so far, to stderr",
+          },
+        ),
+        Action: func(c *cli.Context) error {
+          if err := checkRules(c.String("rules")); err != nil {
+            return err
+          }
+          gs, err := reportStartPosition(c.String("position"), c.Bool("allow-unreachable"))
+          if err != nil {
+            return err
+          }
+          var progress SolveProgress
+          if c.Bool("progress") {
+            progress = func(phase string, stats SolveStats) {
+              fmt.Fprintf(os.Stderr, "%s: %d states explored\n", phase, stats.StatesExplored)
+            }
+          }
+          _, states, _, _, stats, err := solveWithProgress(gs.copyAndNormalize(), CONFIG.MaxDepth, progress, LOGGER)
+          if err != nil {
+            return err
+          }
+          report, err := reportPosition(createSolveGraph(states, CONFIG.MaxDepth, LOGGER), gs)
+          if err != nil {
+            return err
+          }
+          fmt.Println(report.toString())
+          if c.Bool("stats") {
+            fmt.Print(stats.toString())
+          }
+          return nil
+        },
+      },
+      {
+        Name:    "verify",
+        Usage:   "check the solved graph from a position (the tablebase's, if there is one) for scores and edges that don't add up, and print every violation",
+        Flags: POSITION_REPORT_FLAGS,
+        Action: func(c *cli.Context) error {
+          if err := checkRules(c.String("rules")); err != nil {
+            return err
+          }
+          gs, err := reportStartPosition(c.String("position"), c.Bool("allow-unreachable"))
+          if err != nil {
+            return err
+          }
+          root, states, err := graphToVerify(CONFIG, gs, LOGGER)
+          if err != nil {
+            return err
+          }
+          report := verifyGraph(root, states)
+          if c.Bool("json") {
+            if err := printJson(report); err != nil {
+              return err
+            }
+          } else {
+            fmt.Println(report.toString())
+          }
+          if !report.ok() {
+            return fmt.Errorf("Found %d violations", len(report.Violations))
+          }
+          return nil
+        },
+      },
+      {
+        Name:    "puzzles",
+        Usage:   "find positions with a single Move that forces a win in exactly --depth plies",
+        Flags: []cli.Flag{
+          cli.IntFlag{
+            Name: "depth",
+            Value: 3,
+            Usage: "plies until the win, counting the solution (odd)",
+          },
+          cli.IntFlag{
+            Name: "count",
+            Value: PUZZLE_DEFAULT_COUNT,
+            Usage: "how many puzzles to print, 0 for all of them",
+          },
+          cli.BoolFlag{
+            Name: "json",
+            Usage: "print the puzzles as JSON",
+          },
+        },
+        Action: func(c *cli.Context) error {
+          graph, err := solvedGraphFromConfig(CONFIG, LOGGER)
+          if err != nil {
+            return err
+          }
+          puzzles, err := findPuzzles(graph.snapshot(), c.Int("depth"))
+          if err != nil {
+            return err
+          }
+          if count := c.Int("count"); count > 0 && count < len(puzzles) {
+            puzzles = puzzles[:count]
+          }
+          if c.Bool("json") {
+            puzzlesJson, err := json.MarshalIndent(puzzles, "", "  ")
+            if err != nil {
+              return err
+            }
+            fmt.Println(string(puzzlesJson))
+            return nil
+          }
+          for _, p := range puzzles {
+            fmt.Println(p.toLine())
+          }
+          return nil
+        },
+      },
+      {
+        Name:    "tournament",
+        Usage:   "play engines against each other and print a crosstable",
+        ArgsUsage: "<engine> <engine>...",
+        Description: "Engines are a difficulty or one of: " + strings.Join(ENGINE_SPEC_NAMES, ", "),
+        Flags: []cli.Flag{
+          cli.StringFlag{
+            Name: "format",
+            Value: "roundrobin",
+            Usage: "how to pair engines: " + strings.Join(TOURNAMENT_FORMATS, ", "),
+          },
+          cli.IntFlag{
+            Name: "rounds",
+            Usage: "rounds to play with --format swiss, defaults to about log2 of the number of engines",
+          },
+          cli.IntFlag{
+            Name: "openings",
+            Value: TOURNAMENT_DEFAULT_OPENINGS,
+            Usage: "positions each pair of engines plays from, with both sides each",
+          },
+          cli.IntFlag{
+            Name: "max-plies",
+            Value: TOURNAMENT_DEFAULT_MAX_PLIES,
+            Usage: "plies after which a game is a draw",
+          },
+          cli.Int64Flag{
+            Name: "seed",
+            Value: 1,
+            Usage: "seed for the engines and openings, the same seed plays the same tournament",
+          },
+          cli.BoolFlag{
+            Name: "json",
+            Usage: "print the results as JSON",
+          },
+        },
+        Action: func(c *cli.Context) error {
+          if c.NArg() < 2 {
+            return errors.New("Expected at least two engines")
+          }
+          if c.Int("openings") < 1 || c.Int("max-plies") < 1 {
+            return errors.New("--openings and --max-plies must be positive")
+          }
+          engines := make([]Engine, 0, c.NArg())
+          defer func() {
+            closeEngines(engines)
+          }()
+          for i, spec := range c.Args() {
+            engine, err := engineForSpec(spec, c.Int64("seed") + int64(i))
+            if err != nil {
+              return err
+            }
+            engines = append(engines, engine)
+          }
+          graph, err := solvedGraphFromConfig(CONFIG, LOGGER)
+          if err != nil {
+            return err
+          }
+          t := createTournament(graph, engines, pickOpenings(c.Int("openings"), c.Int64("seed")), c.Int("max-plies"))
+          switch c.String("format") {
+          case "roundrobin":
+            if err := t.roundRobin(); err != nil {
+              return err
+            }
+          case "swiss":
+            rounds := c.Int("rounds")
+            if rounds <= 0 {
+              rounds = swissRounds(len(engines))
+            }
+            if err := t.swiss(rounds); err != nil {
+              return err
+            }
+          default:
+            return fmt.Errorf("Unknown format %s, must be one of: %s", c.String("format"), strings.Join(TOURNAMENT_FORMATS, ", "))
+          }
+          if c.Bool("json") {
+            resultJson, err := json.MarshalIndent(t.result(), "", "  ")
+            if err != nil {
+              return err
+            }
+            fmt.Println(string(resultJson))
+            return nil
+          }
+          fmt.Print(t.crosstable())
+          return nil
+        },
+      },
+      {
+        Name:    "engine",
+        Usage:   "play as an engine over stdin and stdout, for other programs to drive",
+        Flags: []cli.Flag{
+          cli.StringFlag{
+            Name: "difficulty",
+            Value: DEFAULT_DIFFICULTY,
+            Usage: "the built-in engine to play with: " + strings.Join(ENGINE_SPEC_NAMES[:len(ENGINE_SPEC_NAMES) - 1], ", "),
+          },
+        },
+        Action: func(c *cli.Context) error {
+          if strings.HasPrefix(c.String("difficulty"), EXTERNAL_ENGINE_PREFIX) {
+            return errors.New("The engine command only plays with the built-in engines")
+          }
+          engine, err := engineForSpec(c.String("difficulty"), time.Now().UnixNano())
+          if err != nil {
+            return err
+          }
+          graph, err := solvedGraphFromConfig(CONFIG, LOGGER)
+          if err != nil {
+            return err
+          }
+          return runEngineProtocol(os.Stdin, os.Stdout, engine, graph)
+        },
+      },
+      {
+        Name:    "games",
+        Usage:   "look at saved games",
+        Subcommands: []cli.Command{
+          {
+            Name: "list",
+            Usage: "list saved games",
+            Flags: []cli.Flag{
+              GAMES_FLAG,
+            },
+            Action: func(c *cli.Context) error {
+              return printGameRecords(createFileGameStore(c.String("games")))
+            },
+          },
+          {
+            Name: "leaderboard",
+            Usage: "rate everyone from the saved games",
+            Flags: []cli.Flag{
+              GAMES_FLAG,
+            },
+            Action: func(c *cli.Context) error {
+              return printLeaderboard(createFileGameStore(c.String("games")))
+            },
+          },
+          {
+            Name: "show",
+            Usage: "print a saved game",
+            ArgsUsage: "<id>",
+            Flags: []cli.Flag{
+              GAMES_FLAG,
+              cli.BoolFlag{
+                Name: "json",
+                Usage: "print the full record as JSON instead of in game notation",
+              },
+            },
+            Action: func(c *cli.Context) error {
+              if c.NArg() != 1 {
+                return errors.New("Expected the id of a game")
+              }
+              return printGameRecord(createFileGameStore(c.String("games")), c.Args().First(), c.Bool("json"))
+            },
+          },
+        },
+      },
     },
   }
 
   err := app.Run(os.Args)
   if err != nil {
-    log.Fatal(err)
+    // Errors are for whoever ran the command, not the logs.
+    fmt.Fprintln(os.Stderr, err)
+    os.Exit(1)
   }
 }
\ No newline at end of file
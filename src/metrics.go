@@ -0,0 +1,257 @@
+package main
+
+import (
+  "bufio"
+  "fmt"
+  "io"
+  "math"
+  "net/http"
+  "sort"
+  "strconv"
+  "strings"
+  "sync"
+  "time"
+
+  "github.com/gorilla/mux"
+)
+
+// Metrics for the dashboard, served at /metrics in the Prometheus text format. They're kept in memory by the
+// process, so they start over when it restarts.
+
+const METRICS_CONTENT_TYPE = "text/plain; version=0.0.4; charset=utf-8"
+// Seconds, from a fast request up to a slow solve.
+var DURATION_BUCKETS []float64 = []float64{0.001, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+var LOOP_GRAPH_SIZE_BUCKETS []float64 = []float64{2, 4, 8, 16, 32, 64, 128, 256, 512}
+
+type metricSeries struct {
+  labelValues []string
+  // Counters and gauges.
+  value float64
+  // Histograms: how many observations fell in each bucket, not counting the ones below it.
+  bucketCounts []uint64
+  sum float64
+  count uint64
+}
+
+type metric struct {
+  name string
+  help string
+  // counter, gauge or histogram
+  kind string
+  labels []string
+  buckets []float64
+  mu sync.Mutex
+  // Keyed by the label values.
+  series map[string]*metricSeries
+}
+
+// The series for the label values, created if there isn't one yet. The metric must be locked.
+func (m *metric) seriesFor(labelValues []string) *metricSeries {
+  if len(labelValues) != len(m.labels) {
+    panic(fmt.Sprintf("metric %s has labels %v, got values %v", m.name, m.labels, labelValues))
+  }
+  key := strings.Join(labelValues, "\xff")
+  s, ok := m.series[key]
+  if !ok {
+    s = &metricSeries{labelValues: labelValues, bucketCounts: make([]uint64, len(m.buckets))}
+    m.series[key] = s
+  }
+  return s
+}
+
+type counter struct {
+  *metric
+}
+
+func (c counter) add(v float64, labelValues ...string) {
+  c.mu.Lock()
+  defer c.mu.Unlock()
+  c.seriesFor(labelValues).value += v
+}
+
+func (c counter) inc(labelValues ...string) {
+  c.add(1, labelValues...)
+}
+
+type gauge struct {
+  *metric
+}
+
+func (g gauge) set(v float64, labelValues ...string) {
+  g.mu.Lock()
+  defer g.mu.Unlock()
+  g.seriesFor(labelValues).value = v
+}
+
+type histogram struct {
+  *metric
+}
+
+func (h histogram) observe(v float64, labelValues ...string) {
+  h.mu.Lock()
+  defer h.mu.Unlock()
+  s := h.seriesFor(labelValues)
+  if i := sort.SearchFloat64s(h.buckets, v); i < len(h.buckets) {
+    s.bucketCounts[i]++
+  }
+  s.sum += v
+  s.count++
+}
+
+func (h histogram) observeDuration(start time.Time, labelValues ...string) {
+  h.observe(time.Since(start).Seconds(), labelValues...)
+}
+
+type metricsRegistry struct {
+  mu sync.Mutex
+  metrics []*metric
+}
+
+func createMetricsRegistry() *metricsRegistry {
+  return &metricsRegistry{}
+}
+
+func (reg *metricsRegistry) register(name string, help string, kind string, buckets []float64, labels []string) *metric {
+  reg.mu.Lock()
+  defer reg.mu.Unlock()
+  for _, m := range reg.metrics {
+    if m.name == name {
+      panic("metric " + name + " is already registered")
+    }
+  }
+  m := &metric{name: name, help: help, kind: kind, labels: labels, buckets: buckets, series: make(map[string]*metricSeries)}
+  reg.metrics = append(reg.metrics, m)
+  return m
+}
+
+func (reg *metricsRegistry) counter(name string, help string, labels ...string) counter {
+  return counter{reg.register(name, help, "counter", nil, labels)}
+}
+
+func (reg *metricsRegistry) gauge(name string, help string, labels ...string) gauge {
+  return gauge{reg.register(name, help, "gauge", nil, labels)}
+}
+
+func (reg *metricsRegistry) histogram(name string, help string, buckets []float64, labels ...string) histogram {
+  return histogram{reg.register(name, help, "histogram", buckets, labels)}
+}
+
+// ==== Text format ====
+
+var METRIC_LABEL_ESCAPER = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+var METRIC_HELP_ESCAPER = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+
+func formatMetricValue(v float64) string {
+  if math.IsInf(v, 1) {
+    return "+Inf"
+  }
+  return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// {a="1",b="2"}, or nothing without labels.
+func formatMetricLabels(names []string, values []string) string {
+  if len(names) == 0 {
+    return ""
+  }
+  pairs := make([]string, len(names))
+  for i, name := range names {
+    pairs[i] = name + "=\"" + METRIC_LABEL_ESCAPER.Replace(values[i]) + "\""
+  }
+  return "{" + strings.Join(pairs, ",") + "}"
+}
+
+// The series' labels with le, the bucket's upper bound.
+func formatBucketLabels(names []string, values []string, bound string) string {
+  return formatMetricLabels(append(names[:len(names):len(names)], "le"), append(values[:len(values):len(values)], bound))
+}
+
+func (m *metric) write(w io.Writer) {
+  m.mu.Lock()
+  defer m.mu.Unlock()
+  fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", m.name, METRIC_HELP_ESCAPER.Replace(m.help), m.name, m.kind)
+  keys := make([]string, 0, len(m.series))
+  for key := range m.series {
+    keys = append(keys, key)
+  }
+  sort.Strings(keys)
+  for _, key := range keys {
+    s := m.series[key]
+    if m.kind != "histogram" {
+      fmt.Fprintf(w, "%s%s %s\n", m.name, formatMetricLabels(m.labels, s.labelValues), formatMetricValue(s.value))
+      continue
+    }
+    var cumulative uint64
+    for i, bound := range m.buckets {
+      cumulative += s.bucketCounts[i]
+      fmt.Fprintf(w, "%s_bucket%s %d\n", m.name, formatBucketLabels(m.labels, s.labelValues, formatMetricValue(bound)), cumulative)
+    }
+    fmt.Fprintf(w, "%s_bucket%s %d\n", m.name, formatBucketLabels(m.labels, s.labelValues, "+Inf"), s.count)
+    fmt.Fprintf(w, "%s_sum%s %s\n", m.name, formatMetricLabels(m.labels, s.labelValues), formatMetricValue(s.sum))
+    fmt.Fprintf(w, "%s_count%s %d\n", m.name, formatMetricLabels(m.labels, s.labelValues), s.count)
+  }
+}
+
+// Every metric in the order they were registered.
+func (reg *metricsRegistry) write(w io.Writer) error {
+  reg.mu.Lock()
+  metrics := append([]*metric{}, reg.metrics...)
+  reg.mu.Unlock()
+  buffered := bufio.NewWriter(w)
+  for _, m := range metrics {
+    m.write(buffered)
+  }
+  return buffered.Flush()
+}
+
+// ==== The metrics ====
+
+var METRICS *metricsRegistry = createMetricsRegistry()
+
+var SOLVES = METRICS.counter("chopsticks_solves_total", "Times the solver explored and scored a Move tree.")
+var SOLVE_DURATION = METRICS.histogram("chopsticks_solve_duration_seconds", "How long solves took.", DURATION_BUCKETS)
+var SOLVE_VISITED_STATES = METRICS.gauge("chopsticks_solve_visited_states", "States the last solve visited. Solves on demand only count the states they explored, not the whole graph.")
+var SOLVE_LEAVES = METRICS.gauge("chopsticks_solve_leaves", "Leaves the last solve found: finished games and positions at the max depth.")
+var SOLVE_LOOPS = METRICS.gauge("chopsticks_solve_loops", "Loops the last solve found in the Move tree.")
+var LOOP_GRAPH_SIZE = METRICS.histogram("chopsticks_loop_graph_size", "Nodes in each loop graph the solver built.", LOOP_GRAPH_SIZE_BUCKETS)
+var HTTP_REQUESTS = METRICS.counter("chopsticks_http_requests_total", "Requests handled, by route, method and status.", "route", "method", "status")
+var HTTP_REQUEST_DURATION = METRICS.histogram("chopsticks_http_request_duration_seconds", "How long requests took, by route.", DURATION_BUCKETS, "route")
+var ACTIVE_GAMES = METRICS.gauge("chopsticks_active_games", "Games on the server that aren't over.")
+var AI_MOVE_DURATION = METRICS.histogram("chopsticks_ai_move_duration_seconds", "How long engines took to pick a Move, by engine.", DURATION_BUCKETS, "engine")
+
+// The engine's Move, timed for the metrics.
+func chooseMoveTimed(engine Engine, node *PlayNode) (Move, error) {
+  defer AI_MOVE_DURATION.observeDuration(time.Now(), engine.name())
+  return engine.chooseMove(node)
+}
+
+// ==== Handlers ====
+
+// Counts requests by the route they matched, so paths with game ids don't each get their own series.
+func requestMetricsMiddleware(next http.Handler) http.Handler {
+  fn := func (w http.ResponseWriter, r *http.Request) {
+    route := "unknown"
+    if current := mux.CurrentRoute(r); current != nil {
+      if template, err := current.GetPathTemplate(); err == nil {
+        route = template
+      }
+    }
+    rec := &statusRecorder{w, http.StatusOK}
+    start := time.Now()
+    next.ServeHTTP(rec, r)
+    HTTP_REQUEST_DURATION.observeDuration(start, route)
+    HTTP_REQUESTS.inc(route, r.Method, strconv.Itoa(rec.status))
+  }
+  return http.HandlerFunc(fn)
+}
+
+// GET /metrics
+func getMetricsHandler(sessions *sessionStore) http.Handler {
+  fn := func (w http.ResponseWriter, r *http.Request) {
+    ACTIVE_GAMES.set(float64(sessions.activeGames()))
+    w.Header().Set("Content-Type", METRICS_CONTENT_TYPE)
+    if err := METRICS.write(w); err != nil {
+      requestLogger(r).Warn("Error writing metrics", "err", err)
+    }
+  }
+  return http.HandlerFunc(fn)
+}
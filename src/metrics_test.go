@@ -0,0 +1,89 @@
+package main
+
+import (
+  "bytes"
+  "fmt"
+  "io"
+  "net/http"
+  "strings"
+  "testing"
+)
+
+func TestMetricsTextFormat(t *testing.T) {
+  fmt.Println("starting TestMetricsTextFormat")
+  reg := createMetricsRegistry()
+  requests := reg.counter("requests_total", "Requests.", "route", "status")
+  games := reg.gauge("games", "Games\nin play.")
+  latency := reg.histogram("latency_seconds", "Latency.", []float64{0.1, 1})
+  requests.inc("/games/{id}", "200")
+  requests.add(2, "/games/{id}", "200")
+  requests.inc(`/say "hi"`, "404")
+  games.set(3)
+  latency.observe(0.05)
+  latency.observe(0.1)
+  latency.observe(0.5)
+  latency.observe(7)
+
+  var out bytes.Buffer
+  if err := reg.write(&out); err != nil {
+    t.Fatal(err.Error())
+  }
+  expected := `# HELP requests_total Requests.
+# TYPE requests_total counter
+requests_total{route="/games/{id}",status="200"} 3
+requests_total{route="/say \"hi\"",status="404"} 1
+# HELP games Games\nin play.
+# TYPE games gauge
+games 3
+# HELP latency_seconds Latency.
+# TYPE latency_seconds histogram
+latency_seconds_bucket{le="0.1"} 2
+latency_seconds_bucket{le="1"} 3
+latency_seconds_bucket{le="+Inf"} 4
+latency_seconds_sum 7.65
+latency_seconds_count 4
+`
+  if out.String() != expected {
+    t.Fatalf("Unexpected metrics:\n%s\nexpected:\n%s", out.String(), expected)
+  }
+}
+
+func TestMetricsEndpoint(t *testing.T) {
+  fmt.Println("starting TestMetricsEndpoint")
+  server, _ := createTestServer(t)
+  defer server.Close()
+
+  var game gameSessionView
+  postJson(t, server.URL + "/games", `{"side": "second", "difficulty": "hard"}`, http.StatusCreated, &game)
+  postJson(t, server.URL + "/games/" + game.Id + "/computer-move", "", http.StatusOK, nil)
+
+  resp, err := http.Get(server.URL + "/metrics")
+  if err != nil {
+    t.Fatal(err.Error())
+  }
+  defer resp.Body.Close()
+  if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != METRICS_CONTENT_TYPE {
+    t.Fatalf("Unexpected response: %d %s", resp.StatusCode, resp.Header.Get("Content-Type"))
+  }
+  body, err := io.ReadAll(resp.Body)
+  if err != nil {
+    t.Fatal(err.Error())
+  }
+  for _, line := range []string{
+    "chopsticks_solves_total ",
+    "chopsticks_solve_duration_seconds_count ",
+    "chopsticks_solve_visited_states ",
+    "chopsticks_solve_leaves ",
+    "chopsticks_solve_loops ",
+    "chopsticks_loop_graph_size_count ",
+    // Game ids are left out of the route.
+    `chopsticks_http_requests_total{route="/games/{id}/computer-move",method="POST",status="200"} `,
+    `chopsticks_http_request_duration_seconds_count{route="/games"} `,
+    "chopsticks_active_games 1\n",
+    `chopsticks_ai_move_duration_seconds_count{engine="hard"} `,
+  } {
+    if !strings.Contains(string(body), "\n" + line) {
+      t.Fatalf("Expected a line starting with %q in:\n%s", line, body)
+    }
+  }
+}
@@ -0,0 +1,263 @@
+package main
+
+import (
+  "errors"
+  "fmt"
+  "regexp"
+  "strconv"
+  "strings"
+  "time"
+)
+
+// Chopsticks Game Notation (CGN), modeled on chess's PGN. A game is a few header tags followed by the numbered Moves:
+//
+//   [Id "a487b5891661d047"]
+//   [Player1 "alice"]
+//   [Player2 "perfect"]
+//   [Fingers "5"]
+//   [Position "11/11 w"]
+//   [Date "2026.10.19"]
+//   [Result "1-0"]
+//
+//   1. L>L L>L 2. L>L R>L 3. R>R R>R 4. R>R 1-0
+//
+// A Move is the mover's hand, then the receiving hand: L>R means the mover's left hand taps the receiver's right
+// hand. Player 1's Moves get numbers; if Player 2 moves first the game starts with "1...". Positions use the same
+// format as position.go.
+
+const CGN_DATE_FORMAT = "2006.01.02"
+// Wrap the movetext so saved games stay readable.
+const CGN_LINE_LENGTH = 80
+
+// Tag values are Go quoted strings, so names can have quotes and backslashes in them.
+var CGN_TAG_REGEXP *regexp.Regexp = regexp.MustCompile(`^\[(\w+)\s+("(?:[^"\\]|\\.)*")\]$`)
+var CGN_MOVE_NUMBER_REGEXP *regexp.Regexp = regexp.MustCompile(`^\d+\.(\.\.)?`)
+
+func handToNotation(h Hand) string {
+  if h == Left {
+    return "L"
+  } else {
+    return "R"
+  }
+}
+
+func parseNotationHand(h string) (Hand, error) {
+  switch strings.ToUpper(h) {
+  case "L":
+    return Left, nil
+  case "R":
+    return Right, nil
+  default:
+    return Left, fmt.Errorf("Invalid hand %q, must be L or R", h)
+  }
+}
+
+func (m *Move) toNotation() string {
+  return handToNotation(m.PlayerHand) + ">" + handToNotation(m.ReceiverHand)
+}
+
+// Parses a single Move like "L>R".
+func parseMoveNotation(m string) (Move, error) {
+  hands := strings.Split(m, ">")
+  if len(hands) != 2 {
+    return Move{}, fmt.Errorf("Invalid move %q, expected something like L>R", m)
+  }
+  playerHand, err := parseNotationHand(hands[0])
+  if err != nil {
+    return Move{}, err
+  }
+  receiverHand, err := parseNotationHand(hands[1])
+  if err != nil {
+    return Move{}, err
+  }
+  return Move{playerHand, receiverHand}, nil
+}
+
+// "1-0" if Player 1 won, "0-1" if Player 2 won, "*" for unfinished games.
+func resultToNotation(result GameResult) string {
+  switch result {
+  case Player1Wins:
+    return "1-0"
+  case Player2Wins:
+    return "0-1"
+  default:
+    return "*"
+  }
+}
+
+func parseResultNotation(result string) (GameResult, bool) {
+  switch result {
+  case "1-0":
+    return Player1Wins, true
+  case "0-1":
+    return Player2Wins, true
+  case "*":
+    return Ongoing, true
+  default:
+    return Ongoing, false
+  }
+}
+
+// The numbered Moves of a game played from start, ending with the result.
+func movesToNotation(start *GameState, moves []Move, result GameResult) string {
+  tokens := make([]string, 0, len(moves) * 3 / 2 + 2)
+  moveNumber := 1
+  t := start.T
+  for i, m := range moves {
+    if t == Player1 {
+      tokens = append(tokens, fmt.Sprintf("%d.", moveNumber))
+    } else if i == 0 {
+      tokens = append(tokens, fmt.Sprintf("%d...", moveNumber))
+    }
+    tokens = append(tokens, m.toNotation())
+    if t == Player2 {
+      moveNumber++
+    }
+    t = invertTurn(t)
+  }
+  tokens = append(tokens, resultToNotation(result))
+
+  var sb strings.Builder
+  lineLength := 0
+  for i, token := range tokens {
+    if i > 0 && lineLength + 1 + len(token) > CGN_LINE_LENGTH {
+      sb.WriteString("\n")
+      lineLength = 0
+    } else if i > 0 {
+      sb.WriteString(" ")
+      lineLength++
+    }
+    sb.WriteString(token)
+    lineLength += len(token)
+  }
+  return sb.String()
+}
+
+func (r *GameRecord) toNotation() (string, error) {
+  start, err := parsePosition(r.StartPosition)
+  if err != nil {
+    return "", err
+  }
+  moves := make([]Move, len(r.Moves))
+  for i, m := range r.Moves {
+    moves[i] = m.Move
+  }
+
+  var sb strings.Builder
+  writeTag := func(name string, value string) {
+    fmt.Fprintf(&sb, "[%s %q]\n", name, value)
+  }
+  if r.Id != "" {
+    writeTag("Id", r.Id)
+  }
+  writeTag("Player1", r.Players["p1"])
+  writeTag("Player2", r.Players["p2"])
+  writeTag("Fingers", strconv.Itoa(int(r.NumFingers)))
+  writeTag("Position", r.StartPosition)
+  if !r.StartTime.IsZero() {
+    writeTag("Date", r.StartTime.Format(CGN_DATE_FORMAT))
+  }
+  writeTag("Result", resultToNotation(r.Result))
+  sb.WriteString("\n")
+  sb.WriteString(movesToNotation(start, moves, r.Result))
+  sb.WriteString("\n")
+  return sb.String(), nil
+}
+
+// Parses a game in CGN and replays it to fill in the states. Tags other than the ones toNotation writes are ignored.
+// Games with a different number of Fingers than NUM_FINGERS can't be replayed, so they're rejected.
+func parseNotation(notation string) (*GameRecord, error) {
+  tags := make(map[string]string)
+  movetext := []string{}
+  for lineNum, line := range strings.Split(notation, "\n") {
+    line = strings.TrimSpace(line)
+    if line == "" {
+      continue
+    }
+    if strings.HasPrefix(line, "[") {
+      matches := CGN_TAG_REGEXP.FindStringSubmatch(line)
+      if matches == nil {
+        return nil, fmt.Errorf("line %d: invalid tag %q", lineNum + 1, line)
+      }
+      value, err := strconv.Unquote(matches[2])
+      if err != nil {
+        return nil, fmt.Errorf("line %d: invalid tag value %s", lineNum + 1, matches[2])
+      }
+      tags[matches[1]] = value
+      continue
+    }
+    movetext = append(movetext, strings.Fields(line)...)
+  }
+
+  if fingers, ok := tags["Fingers"]; ok && fingers != strconv.Itoa(int(NUM_FINGERS)) {
+    return nil, fmt.Errorf("Game is played with %s fingers, only %d is supported", fingers, NUM_FINGERS)
+  }
+  position := tags["Position"]
+  if position == "" {
+    position = initGame().toPositionString()
+  }
+  start, err := parsePosition(position)
+  if err != nil {
+    return nil, err
+  }
+  if err := validatePosition(start); err != nil {
+    return nil, err
+  }
+  players := map[string]string{"p1": tags["Player1"], "p2": tags["Player2"]}
+  record := createGameRecord(tags["Id"], start, players)
+  record.StartTime = time.Time{}
+  if date, ok := tags["Date"]; ok {
+    if record.StartTime, err = time.Parse(CGN_DATE_FORMAT, date); err != nil {
+      return nil, fmt.Errorf("Invalid date %q: %v", date, err)
+    }
+  }
+
+  gs := *start
+  finalResult, hasResult := Ongoing, false
+  for _, token := range movetext {
+    if hasResult {
+      return nil, fmt.Errorf("Unexpected %q after the result", token)
+    }
+    if result, ok := parseResultNotation(token); ok {
+      finalResult, hasResult = result, true
+      continue
+    }
+    token = CGN_MOVE_NUMBER_REGEXP.ReplaceAllString(token, "")
+    if token == "" {
+      continue
+    }
+    m, err := parseMoveNotation(token)
+    if err != nil {
+      return nil, err
+    }
+    if checkGameResult(&gs) != Ongoing {
+      return nil, fmt.Errorf("Move %s after the game is over", token)
+    }
+    if !gs.isMoveValid(m) {
+      return nil, fmt.Errorf("Illegal move %s in %s", token, gs.toPositionString())
+    }
+    gs.playMove(m)
+    record.addMove(m, &gs)
+  }
+  // CGN doesn't have times for Moves.
+  record.EndTime = time.Time{}
+  for i := range record.Moves {
+    record.Moves[i].Time = time.Time{}
+  }
+
+  if tagResult, ok := tags["Result"]; ok {
+    result, valid := parseResultNotation(tagResult)
+    if !valid {
+      return nil, fmt.Errorf("Invalid result %q", tagResult)
+    }
+    if !hasResult {
+      finalResult, hasResult = result, true
+    } else if result != finalResult {
+      return nil, errors.New("Result tag doesn't match the result after the moves")
+    }
+  }
+  if hasResult && finalResult != record.Result {
+    return nil, fmt.Errorf("Result %s doesn't match the final position %s", resultToNotation(finalResult), gs.toPositionString())
+  }
+  return record, nil
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+  "fmt"
+  "strings"
+  "testing"
+)
+
+func TestMoveNotation(t *testing.T) {
+  fmt.Println("starting TestMoveNotation")
+  for _, m := range []Move{{Left, Left}, {Left, Right}, {Right, Left}, {Right, Right}} {
+    parsed, err := parseMoveNotation(m.toNotation())
+    if err != nil || parsed != m {
+      t.Fatalf("Move %+v didn't survive a round trip: %+v, %v", m, parsed, err)
+    }
+  }
+  if m, err := parseMoveNotation("l>r"); err != nil || m != (Move{Left, Right}) {
+    t.Fatalf("Unexpected move for l>r: %+v, %v", m, err)
+  }
+  for _, invalid := range []string{"", "L", "L>", "L>X", "L>R>L"} {
+    if _, err := parseMoveNotation(invalid); err == nil {
+      t.Fatalf("Expected an error parsing %q", invalid)
+    }
+  }
+}
+
+func TestParseCliMove(t *testing.T) {
+  fmt.Println("starting TestParseCliMove")
+  for input, expected := range map[string]Move{
+    "LH->RH": {Left, Right}, "RH->LH": {Right, Left}, "R>R": {Right, Right}, "l r": {Left, Right}, "Right left": {Right, Left},
+    " lh -> rh ": {Left, Right},
+  } {
+    if m, err := parseCliMove(input); err != nil || m != expected {
+      t.Fatalf("Unexpected move for %s: %+v, %v", input, m, err)
+    }
+  }
+  for _, invalid := range []string{"LH->", "LH", "", "l r l", "up down"} {
+    if _, err := parseCliMove(invalid); err == nil {
+      t.Fatalf("Expected an error for %q", invalid)
+    }
+  }
+}
+
+func TestNotationRoundTrip(t *testing.T) {
+  fmt.Println("starting TestNotationRoundTrip")
+  notation := `[Id "game1"]
+[Player1 "alice"]
+[Player2 "perfect"]
+[Fingers "5"]
+[Position "11/11 w"]
+[Date "2026.10.19"]
+[Result "1-0"]
+
+1. L>L L>L 2. L>L R>L 3. R>R R>R 4. R>R 1-0
+`
+  record, err := parseNotation(notation)
+  if err != nil {
+    t.Fatal(err.Error())
+  }
+  if record.Id != "game1" || record.Players["p1"] != "alice" || record.Players["p2"] != "perfect" || len(record.Moves) != 7 {
+    t.Fatalf("Unexpected record: %+v", record)
+  }
+  final := GameState{Player{4, 3}, Player{0, 0}, Player2}
+  if record.Result != Player1Wins || !record.Moves[6].State.equals(&final) {
+    t.Fatalf("Unexpected final state: %+v", record.Moves[6].State)
+  }
+  written, err := record.toNotation()
+  if err != nil {
+    t.Fatal(err.Error())
+  }
+  if written != notation {
+    t.Fatalf("Notation changed after a round trip:\n%s\nvs\n%s", written, notation)
+  }
+}
+
+func TestNotationQuotedNames(t *testing.T) {
+  fmt.Println("starting TestNotationQuotedNames")
+  names := map[string]string{"p1": `Robert "Bobby" Tables`, "p2": `back\slash`}
+  record := createGameRecord("quoted", initGame(), names)
+  record.addMove(Move{Left, Left}, &GameState{Player{1, 1}, Player{2, 1}, Player2})
+  notation, err := record.toNotation()
+  if err != nil {
+    t.Fatal(err.Error())
+  }
+  parsed, err := parseNotation(notation)
+  if err != nil {
+    t.Fatalf("Can't parse %s: %s", notation, err.Error())
+  }
+  if parsed.Players["p1"] != names["p1"] || parsed.Players["p2"] != names["p2"] {
+    t.Fatalf("Names changed after a round trip: %+v vs %+v", parsed.Players, names)
+  }
+  if _, err := parseNotation(`[Player1 "bad \q escape"]`); err == nil {
+    t.Fatal("Expected an error parsing a bad escape")
+  }
+}
+
+func TestNotationFromPlayer2(t *testing.T) {
+  fmt.Println("starting TestNotationFromPlayer2")
+  start := GameState{Player{2, 1}, Player{1, 3}, Player2}
+  moves := []Move{{Left, Left}, {Right, Left}, {Left, Right}}
+  if notation := movesToNotation(&start, moves, Ongoing); notation != "1... L>L 2. R>L L>R *" {
+    t.Fatalf("Unexpected notation: %s", notation)
+  }
+
+  record, err := parseNotation("[Position \"21/13 b\"]\n1... L>L 2. R>L L>R *")
+  if err != nil {
+    t.Fatal(err.Error())
+  }
+  if len(record.Moves) != 3 || record.Moves[0].State.T != Player1 || record.Result != Ongoing {
+    t.Fatalf("Unexpected record: %+v", record)
+  }
+}
+
+func TestNotationWrapsLongGames(t *testing.T) {
+  fmt.Println("starting TestNotationWrapsLongGames")
+  // Players bouncing between the same states make for an arbitrarily long game.
+  moves := make([]Move, 60)
+  for i := range moves {
+    moves[i] = Move{Left, Left}
+  }
+  start := GameState{Player{1, 1}, Player{1, 1}, Player1}
+  for _, line := range strings.Split(movesToNotation(&start, moves, Ongoing), "\n") {
+    if len(line) > CGN_LINE_LENGTH {
+      t.Fatalf("Line is too long: %s", line)
+    }
+  }
+}
+
+func TestInvalidNotation(t *testing.T) {
+  fmt.Println("starting TestInvalidNotation")
+  for _, notation := range []string{
+    // Hand with zero fingers
+    "[Position \"10/11 w\"]\n1. R>L",
+    // Wrong result
+    "1. L>L 0-1",
+    "[Result \"1-0\"]\n1. L>L *",
+    // Moves after the result
+    "1. L>L * R>R",
+    "[Fingers \"4\"]\n1. L>L",
+    "[Position \"11/11\"]",
+    "[Broken tag\n1. L>L",
+    "1. L>X",
+  } {
+    if _, err := parseNotation(notation); err == nil {
+      t.Fatalf("Expected an error parsing %q", notation)
+    }
+  }
+}
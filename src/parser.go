@@ -61,6 +61,45 @@ func PlayerFromMap(playerMap map[string]interface{}) (*Player, error) {
 }
 
 
+func parseUiHand(hand string) (Hand, error) {
+    if hand == "lh" {
+        return Left, nil
+    } else if hand == "rh" {
+        return Right, nil
+    } else {
+        return Left, fmt.Errorf("Unrecognized hand %q, must be lh or rh", hand)
+    }
+}
+
+func parseUiHands(playerHand string, receiverHand string) (Move, error) {
+    ph, err := parseUiHand(playerHand)
+    if err != nil {
+        return Move{}, err
+    }
+    rh, err := parseUiHand(receiverHand)
+    if err != nil {
+        return Move{}, err
+    }
+    return Move{ph, rh}, nil
+}
+
+// The difficulty is optional, missing means the default difficulty.
+func parseUiDifficulty(jsonBody []byte) (string, error) {
+    var body map[string]interface{}
+    if err := json.Unmarshal(jsonBody, &body); err != nil {
+        return "", err
+    }
+    difficultyIf, ok := body["difficulty"]
+    if !ok {
+        return DEFAULT_DIFFICULTY, nil
+    }
+    difficulty, ok := difficultyIf.(string)
+    if !ok {
+        return "", fmt.Errorf("Difficulty is not a string %+v", difficultyIf)
+    }
+    return difficulty, nil
+}
+
 // HOLY MOTHER OF FUCK THIS SUCKSSSSS
 func parseUiMove(jsonBody []byte) (*GameState, error) {
     var body map[string]interface{}
@@ -98,6 +137,5 @@ func parseUiMove(jsonBody []byte) (*GameState, error) {
         return nil, errors.New("Missing key: turn")
     }
 
-    fmt.Printf("Got %+v\n", gs)
     return gs, nil
 }
\ No newline at end of file
@@ -27,4 +27,20 @@ func TestMarshalState(t *testing.T) {
     t.Fatal(err.Error())
   }
   fmt.Printf("Serialized as %s", string(jsonResp))
-}
\ No newline at end of file
+}
+
+func TestUnmarshalDifficulty(t *testing.T) {
+  fmt.Println("starting TestUnmarshalDifficulty")
+  j := `{"p1":{"lh":1,"rh":1},"p2":{"lh":1,"rh":1},"turn":"p1","difficulty":"easy"}`
+  difficulty, err := parseUiDifficulty([]byte(j))
+  if err != nil {
+    t.Fatal(err.Error())
+  }
+  if difficulty != "easy" {
+    t.Fatalf("Unexpected difficulty: %s", difficulty)
+  }
+  difficulty, err = parseUiDifficulty([]byte(`{"p1":{"lh":1,"rh":1},"p2":{"lh":1,"rh":1},"turn":"p1"}`))
+  if err != nil || difficulty != DEFAULT_DIFFICULTY {
+    t.Fatalf("Missing difficulty should be the default: %s, %v", difficulty, err)
+  }
+}
@@ -2,6 +2,7 @@ package main
 
 import (
   "fmt"
+  "log/slog"
   "strings"
   "errors"
   "math"
@@ -19,7 +20,7 @@ func (m *Move) toString() string {
 
 func normalizeHandForPlayer(h Hand, p *Player) Hand {
   if p.isEliminated() {
-    fmt.Println("Warning: normalizing hand for eliminated Player")
+    slog.Warn("Normalizing hand for eliminated Player")
   }
   if p.Lh == p.Rh {
     return Left
@@ -88,7 +89,9 @@ func createPlayNodeReuseGs(gs *GameState) *PlayNode {
 
 // Scores
 // Note: the node must not be a leaf (i.e. it must have children) or this function will fail
-func getBestMoveAndScoreForCurrentPlayer(childNodes map[Move]*PlayNode, log bool, allowUnscoredChild bool) (Move, float32, error) {
+// The moves it considers are logged on the debug level, unless logger is nil.
+func getBestMoveAndScoreForCurrentPlayer(childNodes map[Move]*PlayNode, logger *slog.Logger, allowUnscoredChild bool) (Move, float32, error) {
+  log := logger != nil && debugEnabled(logger)
   // Our best Move is the Move that puts our opponent in the worst position.
   // The score of the current node is the negative of the score of our opponent in the node after our best Move.
   var worstNextScoreForOpp float32 = 2 // This is an impossible score, so we should always trigger an update in the loop.
@@ -101,14 +104,15 @@ func getBestMoveAndScoreForCurrentPlayer(childNodes map[Move]*PlayNode, log bool
     }
     oppScore := nextNode.scoreForCurrentPlayer() 
     if log {
-      fmt.Printf("-- Move: %+v, GS %+v, oppScore (for them): %f, worstNextScoreForOpp: %f, bestMoveForUs: %+v\n", nextMove, nextNode.gs, oppScore, worstNextScoreForOpp, bestMoveForUs)
+      logger.Debug("Considering Move", "move", nextMove.toString(), "position", nextNode.gs.toPositionString(),
+        "opp_score", oppScore, "worst_next_score_for_opp", worstNextScoreForOpp, "best_move", bestMoveForUs.toString())
     }
     if oppScore < worstNextScoreForOpp {
       worstNextScoreForOpp = oppScore
       // Tricky bug! next Move gets reused within the for loop, need to copy. Don't use pointers here.
       bestMoveForUs = nextMove
       if log {
-        fmt.Printf("--- Update triggered, new worstNextScoreForOpp: %f, new bestMoveForUs %+v\n", worstNextScoreForOpp, bestMoveForUs)
+        logger.Debug("Found better Move", "worst_next_score_for_opp", worstNextScoreForOpp, "best_move", bestMoveForUs.toString())
       }
     }
   }
@@ -117,17 +121,17 @@ func getBestMoveAndScoreForCurrentPlayer(childNodes map[Move]*PlayNode, log bool
   } else {
     // Note the negative sign!! worst score for opp is the best score for us.
     if log {
-      fmt.Printf("-- result %+v, %f\n", bestMoveForUs, -worstNextScoreForOpp)
+      logger.Debug("Best Move", "move", bestMoveForUs.toString(), "score", -worstNextScoreForOpp)
     }
     return bestMoveForUs, -worstNextScoreForOpp, nil
   }
 }
 
-func (node *PlayNode) getBestMoveAndScoreForCurrentPlayer(log bool, allowUnscoredChild bool) (Move, float32, error) {
-  if log {
-    fmt.Printf("-- Running getBestMoveAndScoreForCurrentPlayer() for %+v\n", node.gs)
+func (node *PlayNode) getBestMoveAndScoreForCurrentPlayer(logger *slog.Logger, allowUnscoredChild bool) (Move, float32, error) {
+  if logger != nil {
+    logger.Debug("Finding best Move", "position", lazyLogValue(node.gs.toPositionString))
   }
-  return getBestMoveAndScoreForCurrentPlayer(node.nextNodes, log, allowUnscoredChild)
+  return getBestMoveAndScoreForCurrentPlayer(node.nextNodes, logger, allowUnscoredChild)
 }
 
 
@@ -138,7 +142,7 @@ func (node *PlayNode) computeScore(allowUnscoredChild bool) (float32, error) {
     return node.getHeuristicScore(), nil
   } else {
     // Compute the score based on child Moves. 
-    _, scoreForCurrentPlayer, err := node.getBestMoveAndScoreForCurrentPlayer(false, allowUnscoredChild)
+    _, scoreForCurrentPlayer, err := node.getBestMoveAndScoreForCurrentPlayer(nil, allowUnscoredChild)
     if err != nil {
       return 0, err
     }
@@ -146,18 +150,14 @@ func (node *PlayNode) computeScore(allowUnscoredChild bool) (float32, error) {
   }
 }
 
-func (node *PlayNode) updateScore() error {
+func (node *PlayNode) updateScore(logger *slog.Logger) error {
   if score, err := node.computeScore(false); err != nil {
-    if DEBUG {
-      fmt.Println("ERR when updating score: " + node.toString())
-    }
+    logger.Debug("Error updating score", "node", lazyLogValue(node.toString), "err", err)
     return err
   } else {
     node.score = score
     node.isScored = true
-    if DEBUG {
-      fmt.Println("Computed score for node: " + node.toString())
-    }
+    logger.Debug("Computed score", "node", lazyLogValue(node.toString))
     return nil
   }
 }
@@ -234,6 +234,11 @@ func (node *PlayNode) isTerminal() bool {
   return node.gs.Player1.isEliminated() || node.gs.Player2.isEliminated()
 }
 
+// Unexplored leaves are non-terminal nodes that the explorer stopped at because it hit the max depth.
+func (node *PlayNode) isUnexploredLeaf() bool {
+  return len(node.nextNodes) == 0 && !node.isTerminal()
+}
+
 func (node *PlayNode) toString() string {
   return node.toStringImpl(0, 0, make(map[GameState]bool))
 }
@@ -316,7 +321,7 @@ func (node *PlayNode) validateEdgesImpl(recurse bool, curPath []*PlayNode, valid
     return curDepth, curDepth, nil
   }
 
-  // Is there a bug in this??
+  // Without recursing there's nothing to remember.
   if validatedStates != nil {
     validatedStates[*node.gs] = true
   }
@@ -350,15 +355,21 @@ func (node *PlayNode) validateEdgesImpl(recurse bool, curPath []*PlayNode, valid
   }
 
   // All parents of this node must list this node as a child
-  validatedStates[*node.gs] = true
+  if validatedStates != nil {
+    validatedStates[*node.gs] = true
+  }
   for _, parentNode := range node.prevNodes {
     parentMove := findNodeInMap(node, parentNode.nextNodes)
     if parentMove == nil {
        return curDepth, curDepth, errors.New(fmt.Sprintf("Parent node does not contain child that points to it: parent: %s, child %s",parentNode.toTreeString(1), node.toString()))
     }
-    // Recurse up the graph to catch invalid parents
+    // Recurse up the graph to catch invalid parents. Going up past the node we started from leaves the path empty.
     if recurse {
-      if _, _, err := parentNode.validateEdgesImpl(recurse, curPath[:len(curPath) - 1], validatedStates); err != nil {
+      parentPath := curPath
+      if len(parentPath) > 0 {
+        parentPath = parentPath[:len(parentPath) - 1]
+      }
+      if _, _, err := parentNode.validateEdgesImpl(recurse, parentPath, validatedStates); err != nil {
         return curDepth, curDepth, err
       }
     }
@@ -3,6 +3,7 @@ package main
 import (
 	"errors"
 	"fmt"
+	"log/slog"
 )
 
 type gamePlayState struct {
@@ -49,7 +50,7 @@ func validateGameAndNormPlayers(gamePlayer Player, normalizedPlayer Player) erro
 }
 
 func getNormalizedHandForGameMoveAndPlayers(MoveHand Hand, gamePlayer Player, normalizedPlayer Player) (Hand, error) {
-	if DEBUG {
+	if debugEnabled(slog.Default()) {
 		if err := validateGameAndNormPlayers(gamePlayer, normalizedPlayer); err != nil {
 			return MoveHand, err
 		}
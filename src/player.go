@@ -1,8 +1,6 @@
 package main
 
-import (
-	"fmt"
-)
+import "log/slog"
 
 var NUM_FINGERS int8 = 5
 // var NUM_FINGERS int8 = 4
@@ -11,7 +9,7 @@ var NUM_FINGERS int8 = 5
 func setNumFingers(numFingers int8) int8 {
 	prevNumFingers := NUM_FINGERS
 	NUM_FINGERS = numFingers
-	fmt.Printf("Setting NUM_FINGERS to %d (previously %d)\n", NUM_FINGERS, prevNumFingers)
+	slog.Info("Setting NUM_FINGERS", "fingers", NUM_FINGERS, "previous", prevNumFingers)
 	return prevNumFingers
 }
 
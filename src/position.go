@@ -0,0 +1,123 @@
+package main
+
+import (
+  "errors"
+  "fmt"
+  "regexp"
+  "strings"
+)
+
+// Positions are written as "<p1 lh><p1 rh>/<p2 lh><p2 rh> <turn>", e.g. "11/11 w" for the start of a game.
+// Like FEN in chess, w(hite) is Player 1 (who moves first) and b(lack) is Player 2.
+var POSITION_REGEXP *regexp.Regexp = regexp.MustCompile(`^(\d)(\d)/(\d)(\d) ([wb])$`)
+
+func turnToPositionString(t Turn) string {
+  if t == Player1 {
+    return "w"
+  } else {
+    return "b"
+  }
+}
+
+func (gs *GameState) toPositionString() string {
+  return fmt.Sprintf("%d%d/%d%d %s", gs.Player1.Lh, gs.Player1.Rh, gs.Player2.Lh, gs.Player2.Rh, turnToPositionString(gs.T))
+}
+
+// Parses a position string. Doesn't check that the position is legal, use validatePosition for that.
+func parsePosition(position string) (*GameState, error) {
+  matches := POSITION_REGEXP.FindStringSubmatch(strings.TrimSpace(position))
+  if matches == nil {
+    return nil, fmt.Errorf("Invalid position %q, expected something like \"11/11 w\"", position)
+  }
+  // The regexp guarantees single digits.
+  hands := make([]int8, 4)
+  for i := 0; i < 4; i++ {
+    hands[i] = int8(matches[i+1][0] - '0')
+  }
+  gs := &GameState{
+    Player{hands[0], hands[1]},
+    Player{hands[2], hands[3]},
+    Player1,
+  }
+  if matches[5] == "b" {
+    gs.T = Player2
+  }
+  return gs, nil
+}
+
+// A position is legal if every hand has fewer than NUM_FINGERS fingers and the game isn't already over.
+func validatePosition(gs *GameState) error {
+  if err := validateHands(gs); err != nil {
+    return err
+  }
+  if checkGameResult(gs) != Ongoing {
+    return errors.New("Game is already over: " + gs.toPositionString())
+  }
+  return nil
+}
+
+// Whether the turn and every hand make sense, even if the game is over.
+func validateHands(gs *GameState) error {
+  if gs.T != Player1 && gs.T != Player2 {
+    return fmt.Errorf("Invalid turn %d", gs.T)
+  }
+  for _, p := range []Player{gs.Player1, gs.Player2} {
+    for _, fingers := range []int8{p.Lh, p.Rh} {
+      if fingers < 0 || fingers >= NUM_FINGERS {
+        return fmt.Errorf("Invalid hand with %d fingers, must be between 0 and %d: %s", fingers, NUM_FINGERS - 1, gs.toPositionString())
+      }
+    }
+  }
+  return nil
+}
+
+// Returns all normalized states that can be reached by playing from the given state.
+func reachableStates(start *GameState) map[GameState]bool {
+  reached := map[GameState]bool{*start.copyAndNormalize(): true}
+  frontier := createDumbQueue() // Values are *GameState
+  frontier.enqueue(start.copyAndNormalize())
+  for !frontier.isEmpty() {
+    gsIf, _ := frontier.dequeue()
+    gs := gsIf.(*GameState)
+    if checkGameResult(gs) != Ongoing {
+      continue
+    }
+    for _, playerHand := range gs.getPlayer().getDistinctPlayableHands() {
+      for _, receiverHand := range gs.getReceiver().getDistinctPlayableHands() {
+        nextState, err := gs.copyAndPlayTurn(playerHand, receiverHand)
+        if err != nil {
+          continue
+        }
+        nextState.normalize()
+        if !reached[*nextState] {
+          reached[*nextState] = true
+          frontier.enqueue(nextState)
+        }
+      }
+    }
+  }
+  return reached
+}
+
+// Whether the state can come up in a game that starts from initGame.
+func isReachable(gs *GameState) bool {
+  return reachableStates(initGame())[*gs.copyAndNormalize()]
+}
+
+// Parse and validate a starting position. An empty string means the usual start of the game.
+func startPosition(position string, allowUnreachable bool) (*GameState, error) {
+  if position == "" {
+    return initGame(), nil
+  }
+  gs, err := parsePosition(position)
+  if err != nil {
+    return nil, err
+  }
+  if err := validatePosition(gs); err != nil {
+    return nil, err
+  }
+  if !allowUnreachable && !isReachable(gs) {
+    return nil, errors.New("Position " + gs.toPositionString() + " can't be reached from the start of a game")
+  }
+  return gs, nil
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+  "errors"
+  "fmt"
+  "strconv"
+  "strings"
+)
+
+// What the bestmove and eval commands print about a position, for scripts.
+
+// Exit code for bestmove from a position where the game is already over, so scripts can tell it apart from errors.
+const EXIT_GAME_OVER = 2
+
+type positionReport struct {
+  Position string
+  // p1 or p2
+  ToMove string
+  // win, loss or draw for the Player to move, with best play from both sides.
+  Outcome string
+  // Plies until the game ends with best play, 0 for draws.
+  Depth int
+  // The solver's score for the Player to move: 1 is a forced win, -1 a forced loss.
+  Score float32
+  // The best game Move, in the position's own hands. Empty once the game is over.
+  BestMove string `json:",omitempty"`
+  turn Turn
+}
+
+func (r positionReport) toString() string {
+  return fmt.Sprintf("%s to move: %s (score %+.2f)", turnToString(r.turn), r.evalString(), r.Score)
+}
+
+func (r positionReport) evalString() string {
+  if r.Outcome == Draw.toString() {
+    return r.Outcome
+  }
+  return fmt.Sprintf("%s in %d", r.Outcome, r.Depth)
+}
+
+// Rules like "fingers=5". Only the rules the solver was built for can be played, so anything else is an error.
+func checkRules(rules string) error {
+  for _, rule := range strings.Split(rules, ",") {
+    if strings.TrimSpace(rule) == "" {
+      continue
+    }
+    parts := strings.SplitN(rule, "=", 2)
+    if len(parts) != 2 || strings.TrimSpace(parts[0]) != "fingers" {
+      return fmt.Errorf("Unknown rule %q, expected fingers=<n>", rule)
+    }
+    if fingers, err := strconv.Atoi(strings.TrimSpace(parts[1])); err != nil || fingers != int(NUM_FINGERS) {
+      return fmt.Errorf("Only games with %d fingers are supported, not %s", NUM_FINGERS, parts[1])
+    }
+  }
+  return nil
+}
+
+// Like startPosition, but positions where the game is over are fine too, there's just no best Move from them.
+func reportStartPosition(position string, allowUnreachable bool) (*GameState, error) {
+  gs, err := parsePosition(position)
+  if position == "" || err != nil || validateHands(gs) != nil || checkGameResult(gs) == Ongoing {
+    return startPosition(position, allowUnreachable)
+  }
+  if !allowUnreachable && !isReachable(gs) {
+    return nil, errors.New("Position " + gs.toPositionString() + " can't be reached from the start of a game")
+  }
+  return gs, nil
+}
+
+// Solves the position if it hasn't been, and works out who wins from it and the best Move.
+func reportPosition(graph *solveGraph, gs *GameState) (positionReport, error) {
+  node, err := graph.lookupOrSolve(gs)
+  if err != nil {
+    return positionReport{}, err
+  }
+  evals := graph.snapshot().evaluations()
+  eval, ok := evals[*node.gs]
+  if !ok {
+    return positionReport{}, errors.New("Position " + gs.toPositionString() + " wasn't solved")
+  }
+  report := positionReport{
+    gs.toPositionString(), turnToUiString(gs.T), eval.Outcome.toString(), eval.Plies, turnToSign(gs.T) * node.score, "", gs.T,
+  }
+  if checkGameResult(gs) != Ongoing {
+    return report, nil
+  }
+  normalizedMove, _, err := bestMoveForEvals(node, evals)
+  if err != nil {
+    return positionReport{}, err
+  }
+  stateCopy := *gs
+  gameMove, err := createGamePlayState(&stateCopy).getGameMoveForNormalizedMove(normalizedMove)
+  if err != nil {
+    return positionReport{}, err
+  }
+  report.BestMove = gameMove.toNotation()
+  return report, nil
+}
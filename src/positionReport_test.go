@@ -0,0 +1,66 @@
+package main
+
+import (
+  "fmt"
+  "log/slog"
+  "testing"
+)
+
+func TestCheckRules(t *testing.T) {
+  fmt.Println("starting TestCheckRules")
+  for _, rules := range []string{"", "fingers=5", " fingers = 5 "} {
+    if err := checkRules(rules); err != nil {
+      t.Fatalf("Expected %q to be fine: %s", rules, err.Error())
+    }
+  }
+  for _, rules := range []string{"fingers=6", "fingers", "hands=2", "fingers=five"} {
+    if err := checkRules(rules); err == nil {
+      t.Fatalf("Expected an error for %q", rules)
+    }
+  }
+}
+
+func reportForTest(t *testing.T, position string) positionReport {
+  gs, err := reportStartPosition(position, true)
+  if err != nil {
+    t.Fatal(err.Error())
+  }
+  report, err := reportPosition(createSolveGraph(make(map[GameState]*PlayNode), DEFAULT_MAX_DEPTH, slog.Default()), gs)
+  if err != nil {
+    t.Fatal(err.Error())
+  }
+  return report
+}
+
+func TestReportPosition(t *testing.T) {
+  fmt.Println("starting TestReportPosition")
+  report := reportForTest(t, "")
+  if report.Position != "11/11 w" || report.Outcome != "draw" || report.Depth != 0 || report.BestMove == "" {
+    t.Fatalf("Expected the start of the game to be a draw: %+v", report)
+  }
+
+  // The best Move is in the position's hands, not the normalized ones: Player 2's only hand is on the left.
+  report = reportForTest(t, "10/40 b")
+  if report.ToMove != "p2" || report.Outcome != "win" || report.Depth != 1 || report.Score != 1 || report.BestMove != "L>L" {
+    t.Fatalf("Expected a win in 1 with L>L: %+v", report)
+  }
+  if report.toString() != "Player 2 to move: win in 1 (score +1.00)" {
+    t.Fatalf("Unexpected text: %s", report.toString())
+  }
+  report = reportForTest(t, "04/01 b")
+  if report.BestMove != "R>R" {
+    t.Fatalf("Expected R>R: %+v", report)
+  }
+
+  report = reportForTest(t, "00/11 w")
+  if report.Outcome != "loss" || report.Depth != 0 || report.BestMove != "" {
+    t.Fatalf("Expected a finished game with no best Move: %+v", report)
+  }
+  // Player 1 is knocked out on their own turn, so it must be Player 1 to move.
+  if _, err := reportStartPosition("00/11 b", false); err == nil {
+    t.Fatal("Expected an unreachable finished game to be rejected")
+  }
+  if _, err := reportStartPosition("50/11 w", true); err == nil {
+    t.Fatal("Expected an invalid hand to be rejected")
+  }
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+  "fmt"
+  "testing"
+)
+
+func TestPositionRoundTrip(t *testing.T) {
+  fmt.Println("starting TestPositionRoundTrip")
+  states := []GameState{
+    *initGame(),
+    GameState{Player{2, 1}, Player{0, 4}, Player2},
+    GameState{Player{0, 3}, Player{1, 1}, Player1},
+  }
+  for _, gs := range states {
+    position := gs.toPositionString()
+    parsed, err := parsePosition(position)
+    if err != nil {
+      t.Fatal(err.Error())
+    }
+    if !parsed.equals(&gs) {
+      t.Fatalf("Position %s parsed to %+v, expected %+v", position, parsed, gs)
+    }
+  }
+  if initGame().toPositionString() != "11/11 w" {
+    t.Fatalf("Unexpected start position: %s", initGame().toPositionString())
+  }
+}
+
+func TestParseInvalidPositions(t *testing.T) {
+  fmt.Println("starting TestParseInvalidPositions")
+  for _, position := range []string{"", "11/11", "11/11 x", "1/11 w", "111/11 b", "ab/11 w"} {
+    if gs, err := parsePosition(position); err == nil {
+      t.Fatalf("Expected an error parsing %q, got %+v", position, gs)
+    }
+  }
+}
+
+func TestValidatePosition(t *testing.T) {
+  fmt.Println("starting TestValidatePosition")
+  prevNumFingers := setNumFingers(5)
+  if err := validatePosition(&GameState{Player{4, 4}, Player{0, 1}, Player2}); err != nil {
+    t.Fatal(err.Error())
+  }
+  if err := validatePosition(&GameState{Player{5, 1}, Player{1, 1}, Player1}); err == nil {
+    t.Fatal("Expected an error for a hand with too many fingers")
+  }
+  if err := validatePosition(&GameState{Player{0, 0}, Player{1, 1}, Player2}); err == nil {
+    t.Fatal("Expected an error for a finished game")
+  }
+  setNumFingers(3)
+  if err := validatePosition(&GameState{Player{1, 3}, Player{1, 1}, Player1}); err == nil {
+    t.Fatal("Expected an error for a hand with too many fingers")
+  }
+  setNumFingers(prevNumFingers)
+}
+
+func TestStartPosition(t *testing.T) {
+  fmt.Println("starting TestStartPosition")
+  prevNumFingers := setNumFingers(5)
+  gs, err := startPosition("", false)
+  if err != nil || !gs.equals(initGame()) {
+    t.Fatalf("Empty position should be the start of the game: %+v, %v", gs, err)
+  }
+  if _, err := startPosition("12/34 b", false); err != nil {
+    t.Fatal(err.Error())
+  }
+  // Player 2 can't ever be to move with both Players at one finger each.
+  if _, err := startPosition("11/11 b", false); err == nil {
+    t.Fatal("Expected an error for an unreachable position")
+  }
+  if _, err := startPosition("10/11 b", true); err != nil {
+    t.Fatal(err.Error())
+  }
+  setNumFingers(prevNumFingers)
+}
+
+func TestReachableStatesMatchesSolve(t *testing.T) {
+  fmt.Println("starting TestReachableStatesMatchesSolve")
+  _, visitedStates, _, _, _, err := solve(initGame(), DEFAULT_MAX_DEPTH)
+  if err != nil {
+    t.Fatal(err.Error())
+  }
+  reached := reachableStates(initGame())
+  if len(reached) != len(visitedStates) {
+    t.Fatalf("Reachable states and solved states differ: %d vs %d", len(reached), len(visitedStates))
+  }
+  for gs, _ := range visitedStates {
+    if !reached[gs] {
+      t.Fatalf("Solved state is not reachable: %+v", gs)
+    }
+  }
+}
@@ -0,0 +1,236 @@
+package main
+
+import (
+  "errors"
+  "fmt"
+  "net/http"
+  "regexp"
+  "sort"
+  "strconv"
+  "strings"
+)
+
+// Puzzles are positions where the Player to move has a forced win and exactly one Move that keeps it. They're
+// exported one per line in a format modeled on chess's EPD: the position, then the best Move and the number of plies
+// until the win, counting the solution itself:
+//
+//   14/44 w bm L>L; pl 5;
+//
+// Positions use the format from position.go and Moves use game notation.
+
+var PUZZLE_LINE_REGEXP *regexp.Regexp = regexp.MustCompile(`^(\d\d/\d\d [wb]) bm (\S+); pl (\d+);$`)
+
+const PUZZLE_DEFAULT_COUNT = 10
+
+var ERR_NOT_A_PUZZLE error = errors.New("the Player to move doesn't have a forced win")
+
+type puzzle struct {
+  Position string
+  State GameState
+  // Plies until the game is won with best play from both sides, counting the solution.
+  Depth int
+  Solution Move
+  SolutionNotation string
+}
+
+func (p *puzzle) toLine() string {
+  return fmt.Sprintf("%s bm %s; pl %d;", p.Position, p.SolutionNotation, p.Depth)
+}
+
+func parsePuzzleLine(line string) (*puzzle, error) {
+  matches := PUZZLE_LINE_REGEXP.FindStringSubmatch(strings.TrimSpace(line))
+  if matches == nil {
+    return nil, fmt.Errorf("Invalid puzzle %q, expected something like \"14/44 w bm L>L; pl 5;\"", line)
+  }
+  gs, err := parsePosition(matches[1])
+  if err != nil {
+    return nil, err
+  }
+  if err := validatePosition(gs); err != nil {
+    return nil, err
+  }
+  solution, err := parseMoveNotation(matches[2])
+  if err != nil {
+    return nil, err
+  }
+  if !gs.isMoveValid(solution) {
+    return nil, fmt.Errorf("Solution %s can't be played from %s", matches[2], matches[1])
+  }
+  depth, err := strconv.Atoi(matches[3])
+  if err != nil {
+    return nil, err
+  }
+  return &puzzle{gs.toPositionString(), *gs, depth, solution, solution.toNotation()}, nil
+}
+
+// Mines the snapshot's solved (normalized) states for puzzles that are won in exactly depth plies, sorted by position.
+func findPuzzles(snap *solveSnapshot, depth int) ([]puzzle, error) {
+  if depth < 1 || depth % 2 == 0 {
+    // The winner always makes the last Move.
+    return nil, fmt.Errorf("Invalid depth %d, must be odd and positive", depth)
+  }
+  evals := snap.evaluations()
+  puzzles := []puzzle{}
+  for state, node := range snap.states {
+    if evals[state] != (positionEval{Win, depth}) {
+      continue
+    }
+    var solution Move
+    winningMoves := 0
+    for m, next := range node.nextNodes {
+      if evals[*next.gs].forMover().Outcome == Win {
+        solution = m
+        winningMoves++
+      }
+    }
+    if winningMoves == 1 {
+      puzzles = append(puzzles, puzzle{state.toPositionString(), state, depth, solution, solution.toNotation()})
+    }
+  }
+  sort.Slice(puzzles, func(i, j int) bool {
+    return puzzles[i].Position < puzzles[j].Position
+  })
+  return puzzles, nil
+}
+
+// The result of playing a Move in a puzzle.
+type puzzleCheck struct {
+  // Whether the Move wins as fast as possible
+  Correct bool
+  // The state after the Move
+  State GameState
+  // Whether the Move ended the game
+  Solved bool
+  // The defender's best reply to a correct Move that didn't end the game, and the state after it.
+  Reply *Move `json:",omitempty"`
+  ReplyState *GameState `json:",omitempty"`
+  Comment string
+}
+
+// Checks a Move against the graph. Puzzles go on after the first Move: the defender replies with whatever holds out
+// the longest, and every Move of the winner has to keep the fastest win.
+func checkPuzzleMove(graph *solveGraph, gs *GameState, gameMove Move) (*puzzleCheck, error) {
+  if !gs.isMoveValid(gameMove) {
+    return nil, &illegalMoveError{fmt.Sprintf("%s can't be played from %s", gameMove.toNotation(), gs.toPositionString())}
+  }
+  node, err := graph.lookupOrSolve(gs)
+  if err != nil {
+    return nil, err
+  }
+  evals := graph.snapshot().evaluations()
+  if evals[*node.gs].Outcome != Win {
+    return nil, ERR_NOT_A_PUZZLE
+  }
+  gsCopy := *gs
+  normalizedMove, err := createGamePlayState(&gsCopy).getNormalizedMoveForGameMove(gameMove)
+  if err != nil {
+    return nil, err
+  }
+  next, ok := node.nextNodes[normalizedMove]
+  if !ok {
+    return nil, fmt.Errorf("Move %s not found from %s", gameMove.toNotation(), gs.toPositionString())
+  }
+  _, best, err := bestMoveForEvals(node, evals)
+  if err != nil {
+    return nil, err
+  }
+  after := evals[*next.gs].forMover()
+  nextState, err := gs.copyAndPlayTurn(gameMove.PlayerHand, gameMove.ReceiverHand)
+  if err != nil {
+    return nil, err
+  }
+
+  check := &puzzleCheck{after == best, *nextState, checkGameResult(nextState) != Ongoing, nil, nil, ""}
+  switch {
+  case check.Solved:
+    check.Comment = fmt.Sprintf("%s wins!", gameMove.toNotation())
+  case !check.Correct && after.Outcome == Win:
+    check.Comment = fmt.Sprintf("%s still wins, but in %d plies instead of %d.", gameMove.toNotation(), after.Plies, best.Plies)
+  case !check.Correct:
+    check.Comment = fmt.Sprintf("%s leads to a %s.", gameMove.toNotation(), after.toString())
+  default:
+    replyNormalized, _, err := bestMoveForEvals(next, evals)
+    if err != nil {
+      return nil, err
+    }
+    replyState := *nextState
+    reply, err := createGamePlayState(&replyState).playNormalizedTurn(replyNormalized)
+    if err != nil {
+      return nil, err
+    }
+    check.Reply, check.ReplyState = &reply, &replyState
+    check.Comment = fmt.Sprintf("%s, still a %s. The reply is %s.", gameMove.toNotation(), after.toString(), reply.toNotation())
+  }
+  return check, nil
+}
+
+// ==== Handlers ====
+
+// GET /puzzles?depth=N&count=K, every puzzle at that depth if there's no count.
+func getPuzzlesHandler(graph *solveGraph) http.Handler {
+  fn := func (w http.ResponseWriter, r *http.Request) {
+    depth, err := strconv.Atoi(r.URL.Query().Get("depth"))
+    if err != nil {
+      http.Error(w, "depth must be a number", http.StatusBadRequest)
+      return
+    }
+    puzzles, err := findPuzzles(graph.snapshot(), depth)
+    if err != nil {
+      http.Error(w, err.Error(), http.StatusBadRequest)
+      return
+    }
+    if countParam := r.URL.Query().Get("count"); countParam != "" {
+      count, err := strconv.Atoi(countParam)
+      if err != nil || count < 0 {
+        http.Error(w, "count must be a positive number", http.StatusBadRequest)
+        return
+      }
+      if count < len(puzzles) {
+        puzzles = puzzles[:count]
+      }
+    }
+    writeJson(w, r, http.StatusOK, puzzles)
+  }
+  return http.HandlerFunc(fn)
+}
+
+type puzzleCheckRequest struct {
+  Position string `json:"position"`
+  PlayerHand string `json:"playerHand"`
+  ReceiverHand string `json:"receiverHand"`
+}
+
+// POST /puzzles/check
+func getPuzzleCheckHandler(graph *solveGraph) http.Handler {
+  fn := func (w http.ResponseWriter, r *http.Request) {
+    var req puzzleCheckRequest
+    if err := readJsonBody(r, &req); err != nil {
+      http.Error(w, "can't read body: " + err.Error(), http.StatusBadRequest)
+      return
+    }
+    gs, err := parsePosition(req.Position)
+    if err == nil {
+      err = validatePosition(gs)
+    }
+    if err != nil {
+      http.Error(w, err.Error(), http.StatusBadRequest)
+      return
+    }
+    gameMove, err := parseUiHands(req.PlayerHand, req.ReceiverHand)
+    if err != nil {
+      http.Error(w, err.Error(), http.StatusBadRequest)
+      return
+    }
+    check, err := checkPuzzleMove(graph, gs, gameMove)
+    if errors.Is(err, ERR_NOT_A_PUZZLE) || isIllegalMoveError(err) {
+      http.Error(w, err.Error(), http.StatusBadRequest)
+      return
+    } else if err != nil {
+      requestLogger(r).Error("Error checking puzzle move", "position", req.Position, "err", err)
+      http.Error(w, "can't check move", http.StatusInternalServerError)
+      return
+    }
+    writeJson(w, r, http.StatusOK, check)
+  }
+  return http.HandlerFunc(fn)
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+  "encoding/json"
+  "fmt"
+  "log/slog"
+  "net/http"
+  "testing"
+)
+
+func TestFindPuzzles(t *testing.T) {
+  fmt.Println("starting TestFindPuzzles")
+  _, visitedStates, _, _, _, err := solve(initGame(), DEFAULT_MAX_DEPTH)
+  if err != nil {
+    t.Fatal(err.Error())
+  }
+  evals := evaluatePositions(visitedStates)
+  for _, depth := range []int{1, 3, 5} {
+    puzzles, err := findPuzzles(createSolveSnapshot(visitedStates), depth)
+    if err != nil {
+      t.Fatal(err.Error())
+    }
+    if len(puzzles) == 0 {
+      t.Fatalf("Expected some puzzles at depth %d", depth)
+    }
+    for _, p := range puzzles {
+      node, ok := visitedStates[p.State]
+      if !ok || evals[p.State] != (positionEval{Win, depth}) {
+        t.Fatalf("Expected a win in %d: %+v", depth, p)
+      }
+      // The solution is the only Move that wins.
+      for m, next := range node.nextNodes {
+        wins := evals[*next.gs].forMover().Outcome == Win
+        if wins != (m == p.Solution) {
+          t.Fatalf("Expected %s to be the only winning move in %s", p.SolutionNotation, p.Position)
+        }
+      }
+      parsed, err := parsePuzzleLine(p.toLine())
+      if err != nil {
+        t.Fatal(err.Error())
+      }
+      if *parsed != p {
+        t.Fatalf("Puzzle changed after a round trip: %+v vs %+v", *parsed, p)
+      }
+    }
+  }
+  if _, err := findPuzzles(createSolveSnapshot(visitedStates), 2); err == nil {
+    t.Fatal("Expected an error for an even depth")
+  }
+  if _, err := parsePuzzleLine("14/44 w bm L>L"); err == nil {
+    t.Fatal("Expected an error for a puzzle without a depth")
+  }
+}
+
+func TestCheckPuzzleMove(t *testing.T) {
+  fmt.Println("starting TestCheckPuzzleMove")
+  graph := createSolveGraph(make(map[GameState]*PlayNode), DEFAULT_MAX_DEPTH, slog.Default())
+  p, err := parsePuzzleLine("14/44 w bm L>L; pl 5;")
+  if err != nil {
+    t.Fatal(err.Error())
+  }
+
+  wrong, err := checkPuzzleMove(graph, &p.State, Move{Right, Left})
+  if err != nil {
+    t.Fatal(err.Error())
+  }
+  if wrong.Correct || wrong.Solved || wrong.Reply != nil {
+    t.Fatalf("Expected R>L to be wrong: %+v", wrong)
+  }
+
+  // Play the puzzle out: every correct Move gets a reply until the win.
+  gs := p.State
+  plies := 0
+  for {
+    var check *puzzleCheck
+    for _, m := range []Move{{Left, Left}, {Left, Right}, {Right, Left}, {Right, Right}} {
+      if !gs.isMoveValid(m) {
+        continue
+      }
+      c, err := checkPuzzleMove(graph, &gs, m)
+      if err != nil {
+        t.Fatal(err.Error())
+      }
+      if c.Correct {
+        check = c
+        break
+      }
+    }
+    if check == nil {
+      t.Fatalf("No correct move from %s", gs.toPositionString())
+    }
+    plies++
+    if check.Solved {
+      break
+    }
+    if check.Reply == nil || check.ReplyState == nil {
+      t.Fatalf("Expected a reply: %+v", check)
+    }
+    gs = *check.ReplyState
+    plies++
+  }
+  if plies != p.Depth {
+    t.Fatalf("Expected the puzzle to take %d plies, took %d", p.Depth, plies)
+  }
+
+  if _, err := checkPuzzleMove(graph, initGame(), Move{Left, Left}); err != ERR_NOT_A_PUZZLE {
+    t.Fatalf("Expected the start not to be a puzzle: %v", err)
+  }
+  onePly := GameState{Player{0, 1}, Player{0, 4}, Player1}
+  if _, err := checkPuzzleMove(graph, &onePly, Move{Left, Right}); !isIllegalMoveError(err) {
+    t.Fatalf("Expected an illegal move: %v", err)
+  }
+}
+
+func TestPuzzleHandlers(t *testing.T) {
+  fmt.Println("starting TestPuzzleHandlers")
+  server, _ := createTestServer(t)
+  defer server.Close()
+
+  resp, err := http.Get(server.URL + "/puzzles?depth=3&count=2")
+  if err != nil {
+    t.Fatal(err.Error())
+  }
+  defer resp.Body.Close()
+  var puzzles []puzzle
+  if err := json.NewDecoder(resp.Body).Decode(&puzzles); err != nil {
+    t.Fatal(err.Error())
+  }
+  if len(puzzles) != 2 || puzzles[0].Depth != 3 {
+    t.Fatalf("Unexpected puzzles: %+v", puzzles)
+  }
+
+  for _, query := range []string{"", "?depth=4", "?depth=3&count=x"} {
+    bad, err := http.Get(server.URL + "/puzzles" + query)
+    if err != nil {
+      t.Fatal(err.Error())
+    }
+    bad.Body.Close()
+    if bad.StatusCode != http.StatusBadRequest {
+      t.Fatalf("Expected a bad request for %q, got %d", query, bad.StatusCode)
+    }
+  }
+
+  var check puzzleCheck
+  postJson(t, server.URL + "/puzzles/check", `{"position": "14/44 w", "playerHand": "lh", "receiverHand": "lh"}`, http.StatusOK, &check)
+  if !check.Correct || check.Reply == nil {
+    t.Fatalf("Unexpected check: %+v", check)
+  }
+  postJson(t, server.URL + "/puzzles/check", `{"position": "11/11 w", "playerHand": "lh", "receiverHand": "lh"}`, http.StatusBadRequest, nil)
+  postJson(t, server.URL + "/puzzles/check", `{"position": "01/04 w", "playerHand": "lh", "receiverHand": "rh"}`, http.StatusBadRequest, nil)
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+  "fmt"
+  "math"
+  "net/http"
+  "sort"
+  "sync"
+)
+
+// Elo ratings for the office ladder. Ratings come from the saved games, played in the order they finished, so the
+// game store is the only thing that needs persisting. The computer difficulties are anchors: their ratings never
+// move, which keeps everyone else's ratings comparable over time.
+
+const ELO_INITIAL_RATING = 1200
+const ELO_K_FACTOR = 32
+
+var ELO_ANCHORS map[string]float64 = map[string]float64{
+  "perfect": 2000,
+  "hard": 1600,
+  "medium": 1350,
+  "easy": 1100,
+  "random": 800,
+}
+
+type playerRating struct {
+  Name string
+  Rating float64
+  Games int
+  Wins int
+  Losses int
+  // Computer difficulties have fixed ratings.
+  Anchor bool
+}
+
+type ratingTable struct {
+  mu sync.Mutex
+  players map[string]*playerRating
+  // Ids of the games already counted
+  rated map[string]bool
+}
+
+func createRatingTable() *ratingTable {
+  players := make(map[string]*playerRating, len(ELO_ANCHORS))
+  for name, rating := range ELO_ANCHORS {
+    players[name] = &playerRating{name, rating, 0, 0, 0, true}
+  }
+  return &ratingTable{sync.Mutex{}, players, make(map[string]bool)}
+}
+
+// Humans can't take the name of an anchor, or their games would be rated as the computer's.
+func validatePlayerName(name string) error {
+  if _, ok := ELO_ANCHORS[name]; ok || name == ANONYMOUS_PLAYER {
+    return fmt.Errorf("The name %q is taken by the computer, pick another one", name)
+  }
+  return nil
+}
+
+// The chance that a Player rated a beats a Player rated b.
+func eloExpectedScore(a float64, b float64) float64 {
+  return 1 / (1 + math.Pow(10, (b - a) / 400))
+}
+
+func (rt *ratingTable) getOrCreateLocked(name string) *playerRating {
+  p, ok := rt.players[name]
+  if !ok {
+    p = &playerRating{name, ELO_INITIAL_RATING, 0, 0, 0, false}
+    rt.players[name] = p
+  }
+  return p
+}
+
+// Rates a finished game. Games that are still going, were already rated, have an anonymous Player, are the
+// computer playing itself, or didn't start from the usual opening don't count; a game from a won position would
+// give away free rating. Returns whether the game was rated.
+func (rt *ratingTable) addGame(record *GameRecord) bool {
+  rt.mu.Lock()
+  defer rt.mu.Unlock()
+  p1Name, p2Name := record.Players["p1"], record.Players["p2"]
+  if record.Result == Ongoing || rt.rated[record.Id] || p1Name == p2Name {
+    return false
+  }
+  if record.StartPosition != initGame().toPositionString() {
+    return false
+  }
+  for _, name := range []string{p1Name, p2Name} {
+    if name == "" || name == ANONYMOUS_PLAYER {
+      return false
+    }
+  }
+  _, p1Anchor := ELO_ANCHORS[p1Name]
+  _, p2Anchor := ELO_ANCHORS[p2Name]
+  if p1Anchor && p2Anchor {
+    return false
+  }
+
+  p1, p2 := rt.getOrCreateLocked(p1Name), rt.getOrCreateLocked(p2Name)
+  winner, loser := p1, p2
+  if record.Result == Player2Wins {
+    winner, loser = p2, p1
+  }
+  change := ELO_K_FACTOR * (1 - eloExpectedScore(winner.Rating, loser.Rating))
+  if !winner.Anchor {
+    winner.Rating += change
+  }
+  if !loser.Anchor {
+    loser.Rating -= change
+  }
+  winner.Games++
+  winner.Wins++
+  loser.Games++
+  loser.Losses++
+  rt.rated[record.Id] = true
+  return true
+}
+
+// Rates every saved game again, in the order the games finished. Elo depends on the order, and games aren't saved
+// in it: the cli saves games while the server is running, and unfinished games saved at shutdown keep their place
+// once they finish. Starting over every time means the cli and the server always agree.
+func (rt *ratingTable) update(games GameStore) error {
+  records, err := games.list()
+  if err != nil {
+    return err
+  }
+  sortByEndTime(records)
+  fresh := createRatingTable()
+  for _, record := range records {
+    fresh.addGame(record)
+  }
+  rt.mu.Lock()
+  defer rt.mu.Unlock()
+  rt.players, rt.rated = fresh.players, fresh.rated
+  return nil
+}
+
+// Ties, e.g. games saved in the same instant, go by Id so the order never changes.
+func sortByEndTime(records []*GameRecord) {
+  sort.Slice(records, func(i, j int) bool {
+    if !records[i].EndTime.Equal(records[j].EndTime) {
+      return records[i].EndTime.Before(records[j].EndTime)
+    }
+    return records[i].Id < records[j].Id
+  })
+}
+
+// Everyone with a rating, best first. Anchors are always included so new players can see where they stand.
+func (rt *ratingTable) leaderboard() []playerRating {
+  rt.mu.Lock()
+  defer rt.mu.Unlock()
+  ratings := make([]playerRating, 0, len(rt.players))
+  for _, p := range rt.players {
+    ratings = append(ratings, *p)
+  }
+  sort.Slice(ratings, func(i, j int) bool {
+    if ratings[i].Rating != ratings[j].Rating {
+      return ratings[i].Rating > ratings[j].Rating
+    }
+    return ratings[i].Name < ratings[j].Name
+  })
+  return ratings
+}
+
+func printLeaderboard(games GameStore) error {
+  ratings := createRatingTable()
+  if err := ratings.update(games); err != nil {
+    return err
+  }
+  for i, p := range ratings.leaderboard() {
+    anchor := ""
+    if p.Anchor {
+      anchor = "  (computer)"
+    }
+    fmt.Printf("%3d. %-20s %6.0f  %d games, %d wins, %d losses%s\n", i + 1, p.Name, p.Rating, p.Games, p.Wins, p.Losses, anchor)
+  }
+  return nil
+}
+
+// ==== Handlers ====
+
+// GET /leaderboard
+func getLeaderboardHandler(games GameStore, ratings *ratingTable) http.Handler {
+  fn := func (w http.ResponseWriter, r *http.Request) {
+    if err := ratings.update(games); err != nil {
+      requestLogger(r).Error("Error rating games", "err", err)
+      http.Error(w, "can't rate games", http.StatusInternalServerError)
+      return
+    }
+    writeJson(w, r, http.StatusOK, ratings.leaderboard())
+  }
+  return http.HandlerFunc(fn)
+}
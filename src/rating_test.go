@@ -0,0 +1,148 @@
+package main
+
+import (
+  "encoding/json"
+  "fmt"
+  "log/slog"
+  "net/http"
+  "net/http/httptest"
+  "path/filepath"
+  "testing"
+  "time"
+)
+
+func finishedRecord(id string, p1 string, p2 string, result GameResult) *GameRecord {
+  record := createGameRecord(id, initGame(), map[string]string{"p1": p1, "p2": p2})
+  record.Result = result
+  return record
+}
+
+func fromPosition(record *GameRecord, position string) *GameRecord {
+  record.StartPosition = position
+  return record
+}
+
+func TestRatingTable(t *testing.T) {
+  fmt.Println("starting TestRatingTable")
+  ratings := createRatingTable()
+  if !ratings.addGame(finishedRecord("1", "alice", "perfect", Player1Wins)) {
+    t.Fatal("Expected the game to be rated")
+  }
+  for _, record := range []*GameRecord{
+    finishedRecord("1", "alice", "perfect", Player1Wins),
+    finishedRecord("2", "alice", ANONYMOUS_PLAYER, Player1Wins),
+    finishedRecord("3", "easy", "perfect", Player2Wins),
+    finishedRecord("4", "alice", "bob", Ongoing),
+    finishedRecord("5", "alice", "alice", Player2Wins),
+    fromPosition(finishedRecord("7", "alice", "perfect", Player1Wins), "10/40 w"),
+  } {
+    if ratings.addGame(record) {
+      t.Fatalf("Didn't expect game %s to be rated", record.Id)
+    }
+  }
+  ratings.addGame(finishedRecord("6", "bob", "alice", Player1Wins))
+
+  leaderboard := ratings.leaderboard()
+  if len(leaderboard) != len(ELO_ANCHORS) + 2 || leaderboard[0].Name != "perfect" || leaderboard[0].Rating != 2000 {
+    t.Fatalf("Unexpected leaderboard: %+v", leaderboard)
+  }
+  byName := make(map[string]playerRating)
+  for _, p := range leaderboard {
+    byName[p.Name] = p
+  }
+  // Beating a much stronger Player is worth almost the whole K factor.
+  alice, bob := byName["alice"], byName["bob"]
+  if alice.Games != 2 || alice.Wins != 1 || alice.Losses != 1 || alice.Rating < 1200 {
+    t.Fatalf("Unexpected rating: %+v", alice)
+  }
+  if bob.Games != 1 || bob.Wins != 1 || bob.Rating <= 1200 {
+    t.Fatalf("Unexpected rating: %+v", bob)
+  }
+  if perfect := byName["perfect"]; !perfect.Anchor || perfect.Games != 1 || perfect.Losses != 1 {
+    t.Fatalf("Unexpected anchor: %+v", perfect)
+  }
+  if eloExpectedScore(1500, 1500) != 0.5 {
+    t.Fatalf("Expected even chances for equal ratings: %f", eloExpectedScore(1500, 1500))
+  }
+  if validatePlayerName("hard") == nil || validatePlayerName(ANONYMOUS_PLAYER) == nil || validatePlayerName("alice") != nil {
+    t.Fatal("Unexpected name validation")
+  }
+}
+
+func TestRatingsFollowFinishOrder(t *testing.T) {
+  fmt.Println("starting TestRatingsFollowFinishOrder")
+  games := createFileGameStore(filepath.Join(t.TempDir(), "games.jsonl"))
+  start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+  // Game a was started first and saved unfinished, but game b finished first.
+  a := finishedRecord("a", "alice", "bob", Ongoing)
+  a.StartTime = start
+  if err := games.save(a); err != nil {
+    t.Fatal(err.Error())
+  }
+  b := finishedRecord("b", "alice", "bob", Player1Wins)
+  b.StartTime, b.EndTime = start.Add(time.Minute), start.Add(2 * time.Minute)
+  if err := games.save(b); err != nil {
+    t.Fatal(err.Error())
+  }
+  a.Result, a.EndTime = Player2Wins, start.Add(3 * time.Minute)
+  if err := games.save(a); err != nil {
+    t.Fatal(err.Error())
+  }
+
+  ratings := createRatingTable()
+  if err := ratings.update(games); err != nil {
+    t.Fatal(err.Error())
+  }
+  expected := createRatingTable()
+  expected.addGame(b)
+  expected.addGame(a)
+  // Rating again, like the server does for every request, changes nothing.
+  if err := ratings.update(games); err != nil {
+    t.Fatal(err.Error())
+  }
+  got, want := ratings.leaderboard(), expected.leaderboard()
+  if fmt.Sprintf("%+v", got) != fmt.Sprintf("%+v", want) {
+    t.Fatalf("Expected ratings in finish order:\n%+v\ngot:\n%+v", want, got)
+  }
+}
+
+func TestLeaderboardHandler(t *testing.T) {
+  fmt.Println("starting TestLeaderboardHandler")
+  _, visitedStates, _, _, _, err := solve(initGame(), DEFAULT_MAX_DEPTH)
+  if err != nil {
+    t.Fatal(err.Error())
+  }
+  graph := createSolveGraph(visitedStates, DEFAULT_MAX_DEPTH, slog.Default())
+  games := createFileGameStore(filepath.Join(t.TempDir(), "games.jsonl"))
+  server := httptest.NewServer(createRouter(initGame(), graph, createSessionStore(games, slog.Default()), createLobbyStore(slog.Default()), nil, getFrontendHandler(embeddedFrontend(), false), slog.Default()))
+  defer server.Close()
+
+  // alice beat the easy computer from the start, e.g. on the cli.
+  if err := games.save(finishedRecord("cli", "alice", "easy", Player1Wins)); err != nil {
+    t.Fatal(err.Error())
+  }
+  // Winning in one from a won position doesn't count.
+  var game gameSessionView
+  postJson(t, server.URL + "/games", `{"position": "10/40 w", "allowUnreachable": true, "difficulty": "easy", "name": "alice"}`, http.StatusCreated, &game)
+  postJson(t, server.URL + "/games/" + game.Id + "/move", `{"playerHand": "lh", "receiverHand": "lh"}`, http.StatusOK, nil)
+  postJson(t, server.URL + "/games", `{"difficulty": "easy", "name": "perfect"}`, http.StatusBadRequest, nil)
+
+  resp, err := http.Get(server.URL + "/leaderboard")
+  if err != nil {
+    t.Fatal(err.Error())
+  }
+  defer resp.Body.Close()
+  var leaderboard []playerRating
+  if err := json.NewDecoder(resp.Body).Decode(&leaderboard); err != nil {
+    t.Fatal(err.Error())
+  }
+  for _, p := range leaderboard {
+    if p.Name == "alice" {
+      if p.Games != 1 || p.Wins != 1 || p.Rating <= ELO_INITIAL_RATING {
+        t.Fatalf("Unexpected rating: %+v", p)
+      }
+      return
+    }
+  }
+  t.Fatalf("Expected alice on the leaderboard: %+v", leaderboard)
+}
@@ -0,0 +1,367 @@
+package main
+
+import (
+  "bufio"
+  "errors"
+  "fmt"
+  "io"
+  "os"
+  "strings"
+  "time"
+
+  "golang.org/x/term"
+)
+
+// The interactive cli. Every line is either a Move or a command; the game carries on between commands, and the
+// computer moves whenever it's its turn. Reading stops at quit or the end of the input, so games can be scripted.
+
+const REPL_HELP = `Moves: the hand you play with, then the hand you tap, e.g. L>R, LH->RH, l r or left right.
+Commands:
+  help             this help
+  board            show the hands
+  moves            the Moves played so far, in game notation
+  hint             the best Move for whoever is to move
+  eval             who wins from here with best play
+  undo             take back your last Move (and the computer's reply)
+  new              start over from the starting position
+  rules            how to play
+  save <file>      save the game in game notation
+  load <file>      carry on from a game saved in game notation
+  quit             stop playing
+`
+
+const REPL_RULES = `Each Player starts with one finger up on each hand. On your turn, tap one of your opponent's hands with one of
+yours: their hand gets as many more fingers as yours has up. A hand that gets to %d fingers or more wraps around, and
+a hand with exactly %d is out of play, as is a hand with no fingers. Knock out both of your opponent's hands to win.
+`
+
+// Where the repl reads its lines from.
+type lineReader interface {
+  // Returns io.EOF once there's nothing left to read.
+  ReadLine() (string, error)
+}
+
+type scannerLineReader struct {
+  scanner *bufio.Scanner
+}
+
+func createScannerLineReader(in io.Reader) *scannerLineReader {
+  return &scannerLineReader{bufio.NewScanner(in)}
+}
+
+func (s *scannerLineReader) ReadLine() (string, error) {
+  if s.scanner.Scan() {
+    return s.scanner.Text(), nil
+  }
+  if err := s.scanner.Err(); err != nil {
+    return "", err
+  }
+  return "", io.EOF
+}
+
+// Reads from a terminal with line editing, and the up and down arrows going through what was typed before. The
+// terminal is only in raw mode while a line is being typed, so everything else prints as usual.
+type terminalLineReader struct {
+  fd int
+  terminal *term.Terminal
+}
+
+func createTerminalLineReader(in *os.File, out io.Writer) *terminalLineReader {
+  return &terminalLineReader{int(in.Fd()), term.NewTerminal(struct {
+    io.Reader
+    io.Writer
+  }{in, out}, "> ")}
+}
+
+func (t *terminalLineReader) ReadLine() (string, error) {
+  state, err := term.MakeRaw(t.fd)
+  if err != nil {
+    return "", err
+  }
+  defer term.Restore(t.fd, state)
+  // Ctrl-C and Ctrl-D come back as io.EOF, which ends the game like the end of a script does.
+  return t.terminal.ReadLine()
+}
+
+// Reads stdin with line editing if it's a terminal, and line by line otherwise.
+func stdinLineReader() lineReader {
+  if term.IsTerminal(int(os.Stdin.Fd())) {
+    return createTerminalLineReader(os.Stdin, os.Stdout)
+  }
+  return createScannerLineReader(os.Stdin)
+}
+
+type repl struct {
+  *cliGame
+  in lineReader
+  out io.Writer
+  computerDelay time.Duration
+}
+
+func createRepl(in lineReader, out io.Writer, players cliPlayers, graph *solveGraph, start *GameState, humanName string, games GameStore) (*repl, error) {
+  game, err := createCliGame(players, graph, start, humanName, games)
+  if err != nil {
+    return nil, err
+  }
+  return &repl{cliGame: game, in: in, out: out}, nil
+}
+
+func (r *repl) printf(format string, args ...interface{}) {
+  fmt.Fprintf(r.out, format, args...)
+}
+
+func (r *repl) printBoard() {
+  r.printf("%s", r.gps.state.prettyString())
+}
+
+// Runs until the input ends or someone types quit. Without a human, it returns once the game is over.
+func (r *repl) run() error {
+  r.printBoard()
+  if r.hasHuman() {
+    r.printf("Type help to see what you can do.\n")
+  }
+  for {
+    if result := checkGameResult(r.gps.state); result != Ongoing {
+      if !r.announced {
+        r.announced = true
+        if err := r.finishGame(result); err != nil {
+          return err
+        }
+      }
+      if !r.hasHuman() {
+        return nil
+      }
+    } else if !r.players.isHuman(r.gps.state.T) {
+      time.Sleep(r.computerDelay)
+      if err := r.playComputerTurn(); err != nil {
+        return err
+      }
+      continue
+    } else if !r.players.isHuman(invertTurn(r.gps.state.T)) {
+      r.printf("Your turn.\n")
+    } else {
+      r.printf("%s to move.\n", turnToString(r.gps.state.T))
+    }
+
+    line, err := r.in.ReadLine()
+    if err == io.EOF {
+      r.printf("Bye!\n")
+      return nil
+    } else if err != nil {
+      return err
+    }
+    quit, err := r.execute(strings.TrimSpace(line))
+    if err != nil {
+      return err
+    }
+    if quit {
+      r.printf("Bye!\n")
+      return nil
+    }
+  }
+}
+
+func (r *repl) playComputerTurn() error {
+  mover := r.gps.state.T
+  gameMove, err := r.cliGame.playComputerTurn()
+  if err != nil {
+    return err
+  }
+  if r.againstComputer() {
+    r.printf("I'll play: %s\n", gameMove.toNotation())
+  } else {
+    r.printf("%s plays: %s\n", r.players.describe(mover), gameMove.toNotation())
+  }
+  r.printBoard()
+  return nil
+}
+
+// Says who won, and shows the game and its analysis. Finished games get saved.
+func (r *repl) finishGame(result GameResult) error {
+  r.printf("Game over!\n%s\n", r.winnerMessage(result))
+  if notation, err := r.record.toNotation(); err == nil {
+    r.printf("\n%s", notation)
+  }
+  if analysis, err := analyzeGame(r.record, r.graph); err == nil {
+    r.printf("\n%s", analysis.toString())
+  } else {
+    r.printf("Can't analyze the game: %s\n", err.Error())
+  }
+  saved, err := r.saveFinished()
+  if err != nil {
+    return err
+  }
+  if saved {
+    r.printf("Saved game %s\n", r.record.Id)
+  }
+  if r.hasHuman() {
+    r.printf("Type new to play again, undo to take a Move back, or quit.\n")
+  }
+  return nil
+}
+
+// Runs one line of input. Mistakes are reported and otherwise ignored; only errors the game can't carry on from are
+// returned.
+func (r *repl) execute(line string) (bool, error) {
+  fields := strings.Fields(line)
+  if len(fields) == 0 {
+    return false, nil
+  }
+  command, args := strings.ToLower(fields[0]), fields[1:]
+  switch command {
+  case "help", "?":
+    r.printf("%s", REPL_HELP)
+  case "board":
+    r.printBoard()
+  case "moves", "history":
+    r.printf("%s\n", movesToNotation(r.start, r.moves(), r.record.Result))
+  case "hint":
+    r.hint()
+  case "eval":
+    r.eval()
+  case "undo":
+    return false, r.undo()
+  case "new":
+    if err := r.newGame(r.start); err != nil {
+      return false, err
+    }
+    r.printBoard()
+  case "rules":
+    r.printf(REPL_RULES, NUM_FINGERS, NUM_FINGERS)
+  case "save":
+    r.save(args)
+  case "load":
+    return false, r.load(args)
+  case "quit", "exit", "q":
+    return true, nil
+  default:
+    return false, r.playHumanMove(line)
+  }
+  return false, nil
+}
+
+func (r *repl) playHumanMove(line string) error {
+  m, err := parseCliMove(line)
+  if err != nil {
+    r.printf("I don't recognize %q. Type a Move like L>R or left right, or help for the commands.\n", line)
+    return nil
+  }
+  if checkGameResult(r.gps.state) != Ongoing {
+    r.printf("The game is over. Type new to play again.\n")
+    return nil
+  }
+  if !r.gps.state.isMoveValid(m) {
+    r.printf("You can't do that, hands with zero fingers are out of play.\n")
+    return nil
+  }
+  if err := r.playMove(m); err != nil {
+    return err
+  }
+  r.printf("You played: %s\n", m.toNotation())
+  r.printBoard()
+  return nil
+}
+
+func (r *repl) hint() {
+  if checkGameResult(r.gps.state) != Ongoing {
+    r.printf("The game is over.\n")
+    return
+  }
+  m, eval, err := r.cliGame.hint()
+  if err != nil {
+    r.printf("Can't work out a hint: %s\n", err.Error())
+    return
+  }
+  r.printf("Try %s, it's a %s.\n", m.toNotation(), eval.toString())
+}
+
+func (r *repl) eval() {
+  if result := checkGameResult(r.gps.state); result != Ongoing {
+    r.printf("%s.\n", result.toString())
+    return
+  }
+  eval, err := r.currentEval()
+  if err != nil {
+    r.printf("Can't evaluate the position: %s\n", err.Error())
+    return
+  }
+  r.printf("%s to move: %s.\n", turnToString(r.gps.state.T), eval.toString())
+}
+
+func (r *repl) undo() error {
+  undone, err := r.cliGame.undo()
+  if err != nil {
+    return err
+  }
+  if undone == 0 {
+    r.printf("There's nothing of yours to undo.\n")
+    return nil
+  }
+  r.printf("Took back %d Moves.\n", undone)
+  r.printBoard()
+  return nil
+}
+
+func (r *repl) save(args []string) {
+  if len(args) != 1 {
+    r.printf("Usage: save <file>\n")
+    return
+  }
+  notation, err := r.record.toNotation()
+  if err == nil {
+    err = os.WriteFile(args[0], []byte(notation), 0644)
+  }
+  if err != nil {
+    r.printf("Can't save the game: %s\n", err.Error())
+    return
+  }
+  r.printf("Saved the game to %s\n", args[0])
+}
+
+func (r *repl) load(args []string) error {
+  if len(args) != 1 {
+    r.printf("Usage: load <file>\n")
+    return nil
+  }
+  record, err := readNotationFile(args[0])
+  if err == nil {
+    err = r.loadRecord(record)
+  }
+  if err != nil {
+    r.printf("Can't load %s: %s\n", args[0], err.Error())
+    return nil
+  }
+  r.printf("Loaded %s, %d Moves in.\n", args[0], len(r.record.Moves))
+  r.printBoard()
+  return nil
+}
+
+// Moves typed on the cli: the Player's hand then the receiver's, as L>R, LH->RH, "l r" or "left right".
+func parseCliMove(input string) (Move, error) {
+  normalized := strings.ToLower(input)
+  for _, separator := range []string{"->", ">", ","} {
+    normalized = strings.ReplaceAll(normalized, separator, " ")
+  }
+  hands := strings.Fields(normalized)
+  if len(hands) != 2 {
+    return Move{}, errors.New("Invalid move " + input)
+  }
+  m := Move{}
+  for i, hand := range hands {
+    var h Hand
+    switch hand {
+    case "l", "lh", "left":
+      h = Left
+    case "r", "rh", "right":
+      h = Right
+    default:
+      return Move{}, fmt.Errorf("Invalid hand %q in move %s", hand, input)
+    }
+    if i == 0 {
+      m.PlayerHand = h
+    } else {
+      m.ReceiverHand = h
+    }
+  }
+  return m, nil
+}
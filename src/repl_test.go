@@ -0,0 +1,114 @@
+package main
+
+import (
+  "bytes"
+  "fmt"
+  "log/slog"
+  "path/filepath"
+  "strings"
+  "testing"
+)
+
+// Runs the repl on the script against the perfect engine, with the human moving first, and returns what it printed.
+func runTestRepl(t *testing.T, position string, script string, games GameStore) (*repl, string) {
+  start, err := startPosition(position, true)
+  if err != nil {
+    t.Fatal(err.Error())
+  }
+  _, visitedStates, _, _, _, err := solve(start, DEFAULT_MAX_DEPTH)
+  if err != nil {
+    t.Fatal(err.Error())
+  }
+  engine, err := createSolverEngine("perfect", 1)
+  if err != nil {
+    t.Fatal(err.Error())
+  }
+  players := cliPlayers{start.T: nil, invertTurn(start.T): engine}
+  var out bytes.Buffer
+  r, err := createRepl(createScannerLineReader(strings.NewReader(script)), &out, players, createSolveGraph(visitedStates, DEFAULT_MAX_DEPTH, slog.Default()), start, "alice", games)
+  if err != nil {
+    t.Fatal(err.Error())
+  }
+  if err := r.run(); err != nil {
+    t.Fatal(err.Error())
+  }
+  return r, out.String()
+}
+
+func expectOutput(t *testing.T, out string, expected ...string) {
+  for _, e := range expected {
+    if !strings.Contains(out, e) {
+      t.Fatalf("Expected %q in the output:\n%s", e, out)
+    }
+  }
+}
+
+func TestReplWinsAndSaves(t *testing.T) {
+  fmt.Println("starting TestReplWinsAndSaves")
+  games := createFileGameStore(filepath.Join(t.TempDir(), "games.jsonl"))
+  r, out := runTestRepl(t, "10/40 w", "eval\nhint\nleft left\nL>L\n", games)
+  expectOutput(t, out, "Player 1 to move: win in 1.", "Try L>L, it's a win in 1.", "You played: L>L", "You win!",
+    "The game is over. Type new to play again.", "Bye!")
+  saved, ok, err := games.get(r.record.Id)
+  if err != nil || !ok || saved.Result != Player1Wins || saved.Players["p1"] != "alice" {
+    t.Fatalf("Expected the game to be saved: %+v, %v", saved, err)
+  }
+}
+
+func TestReplIgnoresNonsense(t *testing.T) {
+  fmt.Println("starting TestReplIgnoresNonsense")
+  r, out := runTestRepl(t, "", "\nLH\nLH->\nL>R>L\nforward march\nundo\nsave\nload /nonexistent/game.cgn\nquit\nL>L\n", nil)
+  expectOutput(t, out, `I don't recognize "LH".`, `I don't recognize "LH->".`, "There's nothing of yours to undo.",
+    "Usage: save <file>", "Can't load /nonexistent/game.cgn")
+  if len(r.record.Moves) != 0 {
+    t.Fatalf("Expected no Moves to be played, and nothing read after quit: %+v", r.record.Moves)
+  }
+}
+
+func TestReplUndo(t *testing.T) {
+  fmt.Println("starting TestReplUndo")
+  r, out := runTestRepl(t, "", "l l\nr l\nundo\nmoves\n", nil)
+  // Undo takes back the computer's reply along with the human's Move.
+  expectOutput(t, out, "I'll play: ", "Took back 2 Moves.")
+  if len(r.record.Moves) != 2 || r.record.Moves[0].Move != (Move{Left, Left}) {
+    t.Fatalf("Expected only the first two Moves to be left: %+v", r.record.Moves)
+  }
+  expectOutput(t, out, "1. L>L " + r.record.Moves[1].Move.toNotation() + " *")
+}
+
+func TestReplSaveAndLoad(t *testing.T) {
+  fmt.Println("starting TestReplSaveAndLoad")
+  path := filepath.Join(t.TempDir(), "game.cgn")
+  saved, _ := runTestRepl(t, "", "l l\nsave " + path + "\n", nil)
+  loaded, out := runTestRepl(t, "", "r r\nload " + path + "\n", nil)
+  expectOutput(t, out, "Loaded " + path + ", 2 Moves in.")
+  if *loaded.gps.state != *saved.gps.state || len(loaded.record.Moves) != 2 || loaded.record.Id == saved.record.Id {
+    t.Fatalf("Expected to carry on from the saved game as a new game: %+v vs %+v", loaded.record, saved.record)
+  }
+}
+
+func TestReplWithoutHuman(t *testing.T) {
+  fmt.Println("starting TestReplWithoutHuman")
+  start, err := parsePosition("10/40 w")
+  if err != nil {
+    t.Fatal(err.Error())
+  }
+  _, visitedStates, _, _, _, err := solve(start, DEFAULT_MAX_DEPTH)
+  if err != nil {
+    t.Fatal(err.Error())
+  }
+  players := cliPlayers{Player1: createAlphaBetaEngine(2), Player2: createAlphaBetaEngine(2)}
+  var out bytes.Buffer
+  // Nothing gets read when the computer plays both sides.
+  r, err := createRepl(createScannerLineReader(strings.NewReader("quit\n")), &out, players, createSolveGraph(visitedStates, DEFAULT_MAX_DEPTH, slog.Default()), start, "", nil)
+  if err != nil {
+    t.Fatal(err.Error())
+  }
+  if err := r.run(); err != nil {
+    t.Fatal(err.Error())
+  }
+  expectOutput(t, out.String(), "Player 1 (alphabeta:2) plays: L>L", "Player 1 (alphabeta:2) wins!")
+  if strings.Contains(out.String(), "Bye!") {
+    t.Fatalf("Expected the game to end without reading anything:\n%s", out.String())
+  }
+}
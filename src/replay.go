@@ -0,0 +1,134 @@
+package main
+
+import (
+  "fmt"
+  "net/http"
+  "os"
+
+  "github.com/gorilla/mux"
+)
+
+// Replays step through a saved game one Move at a time, with the solver's evaluation of every position.
+
+type replayStep struct {
+  // 0 for the start position
+  MoveNumber int
+  // The Player who moved ("p1" or "p2") and their Move, empty for the start position.
+  Player string
+  Move *Move `json:",omitempty"`
+  Notation string
+  // The state after the Move
+  State GameState
+  Position string
+  // +1 means Player 1 wins with perfect play from here, -1 means Player 2 wins.
+  Evaluation float32
+  Outcome string
+}
+
+// Describes an evaluation from Player 1's point of view, like chess engines do for white.
+func evaluationToString(evaluation float32) string {
+  if evaluation == 0 {
+    // Don't print -0.00
+    evaluation = 0
+  }
+  return fmt.Sprintf("%+.2f (%s)", evaluation, evaluationToOutcomeString(evaluation))
+}
+
+func evaluationToOutcomeString(evaluation float32) string {
+  switch scoreToOutcome(evaluation) {
+  case Win:
+    return "Player 1 wins"
+  case Loss:
+    return "Player 2 wins"
+  default:
+    return "draw"
+  }
+}
+
+func evaluate(graph *solveGraph, gs *GameState) (float32, error) {
+  node, err := graph.lookupOrSolve(gs)
+  if err != nil {
+    return 0, err
+  }
+  return node.score, nil
+}
+
+func buildReplay(record *GameRecord, graph *solveGraph) ([]replayStep, error) {
+  start, err := parsePosition(record.StartPosition)
+  if err != nil {
+    return nil, err
+  }
+  evaluation, err := evaluate(graph, start)
+  if err != nil {
+    return nil, err
+  }
+  steps := []replayStep{
+    {0, "", nil, "", *start, start.toPositionString(), evaluation, evaluationToOutcomeString(evaluation)},
+  }
+  mover := start.T
+  for i, recorded := range record.Moves {
+    evaluation, err := evaluate(graph, &recorded.State)
+    if err != nil {
+      return nil, err
+    }
+    m := recorded.Move
+    steps = append(steps, replayStep{
+      i + 1, turnToUiString(mover), &m, m.toNotation(), recorded.State, recorded.State.toPositionString(), evaluation,
+      evaluationToOutcomeString(evaluation),
+    })
+    mover = invertTurn(mover)
+  }
+  return steps, nil
+}
+
+func printReplay(record *GameRecord, steps []replayStep) {
+  fmt.Printf("%s vs %s, from %s\n", record.Players["p1"], record.Players["p2"], record.StartPosition)
+  for i, step := range steps {
+    if step.MoveNumber == 0 {
+      fmt.Println("Start position:")
+    } else {
+      fmt.Printf("Move %d: %s played %s (%s)\n", step.MoveNumber, turnToString(steps[i-1].State.T), step.Notation, step.Move.toString())
+      fmt.Printf("Evaluation before: %s, after: %s\n", evaluationToString(steps[i-1].Evaluation), evaluationToString(step.Evaluation))
+    }
+    fmt.Print(step.State.prettyString())
+    if step.MoveNumber == 0 {
+      fmt.Printf("Evaluation: %s\n", evaluationToString(step.Evaluation))
+    }
+  }
+  fmt.Println("Result: " + record.Result.toString())
+}
+
+// Loads a game from a file in game notation.
+func readNotationFile(path string) (*GameRecord, error) {
+  notation, err := os.ReadFile(path)
+  if err != nil {
+    return nil, err
+  }
+  return parseNotation(string(notation))
+}
+
+// ==== Handlers ====
+
+func getReplayHandler(games GameStore, graph *solveGraph) http.Handler {
+  fn := func (w http.ResponseWriter, r *http.Request) {
+    id := mux.Vars(r)["id"]
+    record, ok, err := games.get(id)
+    if err != nil {
+      requestLogger(r).Error("Error fetching game", "game", id, "err", err)
+      http.Error(w, "can't fetch game", http.StatusInternalServerError)
+      return
+    }
+    if !ok {
+      http.Error(w, fmt.Sprintf("no saved game with id %s", id), http.StatusNotFound)
+      return
+    }
+    steps, err := buildReplay(record, graph)
+    if err != nil {
+      requestLogger(r).Error("Error replaying game", "game", id, "err", err)
+      http.Error(w, "can't replay game", http.StatusInternalServerError)
+      return
+    }
+    writeJson(w, r, http.StatusOK, steps)
+  }
+  return http.HandlerFunc(fn)
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+  "encoding/json"
+  "fmt"
+  "log/slog"
+  "net/http"
+  "net/http/httptest"
+  "path/filepath"
+  "testing"
+)
+
+const REPLAY_TEST_GAME = `[Id "replayed"]
+[Player1 "alice"]
+[Player2 "bob"]
+
+1. L>L L>L 2. L>L R>L 3. R>R R>R 4. R>R 1-0`
+
+func TestBuildReplay(t *testing.T) {
+  fmt.Println("starting TestBuildReplay")
+  record, err := parseNotation(REPLAY_TEST_GAME)
+  if err != nil {
+    t.Fatal(err.Error())
+  }
+  steps, err := buildReplay(record, createSolveGraph(make(map[GameState]*PlayNode), DEFAULT_MAX_DEPTH, slog.Default()))
+  if err != nil {
+    t.Fatal(err.Error())
+  }
+  if len(steps) != len(record.Moves) + 1 {
+    t.Fatalf("Expected a step per move plus the start, got %d", len(steps))
+  }
+  if steps[0].MoveNumber != 0 || steps[0].Move != nil || steps[0].Position != "11/11 w" || steps[0].Outcome != "draw" {
+    t.Fatalf("Unexpected start step: %+v", steps[0])
+  }
+  for i, step := range steps[1:] {
+    expectedPlayer := "p1"
+    if i % 2 == 1 {
+      expectedPlayer = "p2"
+    }
+    if step.MoveNumber != i + 1 || step.Player != expectedPlayer || *step.Move != record.Moves[i].Move {
+      t.Fatalf("Unexpected step %d: %+v", i + 1, step)
+    }
+  }
+  last := steps[len(steps) - 1]
+  if last.Evaluation != 1 || last.Outcome != "Player 1 wins" || last.Notation != "R>R" {
+    t.Fatalf("Unexpected last step: %+v", last)
+  }
+  if evaluationToString(0) != "+0.00 (draw)" || evaluationToString(-1) != "-1.00 (Player 2 wins)" {
+    t.Fatalf("Unexpected evaluation strings: %s, %s", evaluationToString(0), evaluationToString(-1))
+  }
+}
+
+func TestReplayHandler(t *testing.T) {
+  fmt.Println("starting TestReplayHandler")
+  games := createFileGameStore(filepath.Join(t.TempDir(), "games.jsonl"))
+  record, err := parseNotation(REPLAY_TEST_GAME)
+  if err != nil {
+    t.Fatal(err.Error())
+  }
+  if err := games.save(record); err != nil {
+    t.Fatal(err.Error())
+  }
+  graph := createSolveGraph(make(map[GameState]*PlayNode), DEFAULT_MAX_DEPTH, slog.Default())
+  server := httptest.NewServer(createRouter(initGame(), graph, createSessionStore(games, slog.Default()), createLobbyStore(slog.Default()), nil, getFrontendHandler(embeddedFrontend(), false), slog.Default()))
+  defer server.Close()
+
+  resp, err := http.Get(server.URL + "/records/replayed/replay")
+  if err != nil {
+    t.Fatal(err.Error())
+  }
+  defer resp.Body.Close()
+  var steps []replayStep
+  if err := json.NewDecoder(resp.Body).Decode(&steps); err != nil {
+    t.Fatal(err.Error())
+  }
+  if len(steps) != 8 || steps[7].Outcome != "Player 1 wins" {
+    t.Fatalf("Unexpected steps: %+v", steps)
+  }
+
+  missing, err := http.Get(server.URL + "/records/nope/replay")
+  if err != nil {
+    t.Fatal(err.Error())
+  }
+  missing.Body.Close()
+  if missing.StatusCode != http.StatusNotFound {
+    t.Fatalf("Expected a 404 for a missing game, got %d", missing.StatusCode)
+  }
+}
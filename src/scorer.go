@@ -3,6 +3,7 @@ package main
 import (
   "fmt"
   "errors"
+  "log/slog"
   "math"
 )
 
@@ -78,7 +79,8 @@ func enqueueLoopParent(dq *DumbQueue, ln *loopNode) {
   enqueueLoopNode(dq, ln.prevNode)
 }
 
-func scoreLoop(lg *loopGraph) error {
+func scoreLoop(lg *loopGraph, run *solveRun) error {
+  logger := run.logger
   // Step one: find the most "winning" exit edges of the loop **for each Player**.
   //  -- winning means: best score for current Player. Most winning states are +1/Player1Turn, -1/Player2Turn. If both
   //     exist we have to score both
@@ -94,17 +96,13 @@ func scoreLoop(lg *loopGraph) error {
     applyScore(b1) 
     enqueueLoopParent(nodesToScore, b1.node)
     numNodesProcessed++
-    if DEBUG {
-      fmt.Printf("Most winning node for p1: %s (%f)\n", b1.node.pn.toString(), b1.score)
-    }
+    logger.Debug("Most winning node for p1", "node", lazyLogValue(b1.node.pn.toString), "score", b1.score)
   }
   if b2.node != nil {
     applyScore(b2) 
     enqueueLoopParent(nodesToScore, b2.node)
     numNodesProcessed++
-    if DEBUG {
-      fmt.Printf("Most winning node for p2: %s (%f)\n", b2.node.pn.toString(), b2.score)
-    }
+    logger.Debug("Most winning node for p2", "node", lazyLogValue(b2.node.pn.toString), "score", b2.score)
   }
   // Step three: propagate the scores up **within the loop** from the most winning nodes. 
   // Repeat BFS until all nodes in the loop are scored, OR until we run out of ways to propagate up the loop.
@@ -114,29 +112,23 @@ func scoreLoop(lg *loopGraph) error {
     if err != nil {
       return err
     }
-    if DEBUG {
-      fmt.Printf("Dequeued: %s\n", curLoopNode.pn.toString())
-    }
+    logger.Debug("Dequeued loop node", "node", lazyLogValue(curLoopNode.pn.toString))
     curPlayNode := curLoopNode.pn
     // If node is already scored, we've already processed it, so just skip it.
     if curPlayNode.isScored {
-      if DEBUG {
-        fmt.Printf("Loop node is already scored, rescoring: %s\n", curPlayNode.toString())
-      }
+      logger.Debug("Loop node is already scored, rescoring", "node", lazyLogValue(curPlayNode.toString))
     }
     // If all children are scored, we can score this node. If NOT all children are scored, there's another loop intersection or an unscored
     // exit node of some kind.
     if curPlayNode.allChildrenAreScored() {
-      if err := curPlayNode.updateScore(); err != nil {
+      if err := curPlayNode.updateScore(logger); err != nil {
         return err
       }
-      if DEBUG {
-        fmt.Printf("Scored loop node: %+v\n", curPlayNode)
-      }
+      logger.Debug("Scored loop node", "node", lazyLogValue(curPlayNode.toString))
     } else {
       // Killer heuristic: if the max score of a child of this node is == the score of the most winning exit, give the node that score.
       // Alternatively, if the max score of a child node is a maxed out score, give it that score as well.
-      _, maxChildScoreCurPlayer, err := curPlayNode.getBestMoveAndScoreForCurrentPlayer(false, true)
+      _, maxChildScoreCurPlayer, err := curPlayNode.getBestMoveAndScoreForCurrentPlayer(nil, true)
       if err != nil {
         return err
       }
@@ -154,16 +146,16 @@ func scoreLoop(lg *loopGraph) error {
       // Sanity check: if this fires our most winning score code is broken, or there's some kind of loop propagation error.
       if maxChildScoreCurPlayer > mostWinningScore {
         // return fmt.Errorf("Max child score greater than most winning score: %s, %f > %f", curPlayNode.toTreeString(1), maxChildScoreCurPlayer, mostWinningScore)
-
-        fmt.Printf("Max child score greater than most winning score: %s, %f > %f\n", curPlayNode.toTreeString(1), maxChildScoreCurPlayer, mostWinningScore)
+        run.stats.MostWinningScoreViolations++
+        logger.Warn("Max child score greater than most winning score", "node", lazyLogValue(func() string {
+          return curPlayNode.toTreeString(1)
+        }), "max_child_score", maxChildScoreCurPlayer, "most_winning_score", mostWinningScore)
       }
 
       if maxChildScoreCurPlayer >= mostWinningScore || maxChildScoreCurPlayer > 0.9 {
         curPlayNode.score = turnToSign(curPlayNode.gs.T) * maxChildScoreCurPlayer
         curPlayNode.isScored = true
-        if DEBUG {
-          fmt.Printf("Scored loop node based on max child score: %+v\n", curPlayNode)
-        }
+        logger.Debug("Scored loop node based on max child score", "node", lazyLogValue(curPlayNode.toString))
       }
     }
 
@@ -172,23 +164,25 @@ func scoreLoop(lg *loopGraph) error {
   }
   // Step four: go over the loop one last time and give all unscored nodes a heuristic score - they're stuck in an
   // infinite loop or are otherwise not scorable.
-  applyHeuristicScores(lg)
+  run.stats.HeuristicScores += applyHeuristicScores(lg, logger)
   // At this point, all nodes in the loop should be scored (though perhaps not optimally)
   return nil
 }
 
-func applyHeuristicScores(lg *loopGraph) {
+// Returns how many nodes got heuristic scores.
+func applyHeuristicScores(lg *loopGraph, logger *slog.Logger) int {
+  applied := 0
   for i, ln := 0, lg.head; i < lg.size; i, ln = i+1, ln.nextNode {
     pn := ln.pn
     // If no score, apply the heuristic score
     if !pn.isScored {
       pn.score = pn.getHeuristicScore()
       pn.isScored = true
-      if DEBUG {
-        fmt.Printf("Applied heuristic score to unscored loop node: %+v (%s)\n", ln, ln.pn.toString())
-      }
+      applied++
+      logger.Debug("Applied heuristic score to unscored loop node", "node", lazyLogValue(pn.toString))
     }
   }
+  return applied
 }
 
 func isScorable(node *PlayNode) bool {
@@ -196,7 +190,7 @@ func isScorable(node *PlayNode) bool {
   // return node.allChildrenAreScored()
 }
 
-func enqueueScorableParents(scorableFrontier *DumbQueue, node *PlayNode) error {
+func enqueueScorableParents(scorableFrontier *DumbQueue, node *PlayNode, logger *slog.Logger) error {
   // Assume node has been scored; 
   // enqueue all parents of node that are now scorable (i.e. they are not scored but all their children are scored)
   // Note: loop nodes are never scorable in this sense; they should never be on the scorable frontier
@@ -205,9 +199,7 @@ func enqueueScorableParents(scorableFrontier *DumbQueue, node *PlayNode) error {
     if isScorable(parentNode) && len(parentNode.lns) == 0 {
       enqueuePlayNode(scorableFrontier, parentNode)
     } else {
-      if DEBUG {
-        fmt.Println("Can't enqueue parent, not scorable: " + parentNode.toString())
-      }
+      logger.Debug("Can't enqueue parent, not scorable", "node", lazyLogValue(parentNode.toString))
     }
   }
   return nil
@@ -215,7 +207,7 @@ func enqueueScorableParents(scorableFrontier *DumbQueue, node *PlayNode) error {
 
 
 func updateStateForScoredNode(curNode *PlayNode, scorableFrontier *DumbQueue, 
-    remainingExitNodes map[*loopGraph]map[*PlayNode]int, exitNodesToLoopGraph map[*PlayNode][]*loopGraph) error {
+    remainingExitNodes map[*loopGraph]map[*PlayNode]int, exitNodesToLoopGraph map[*PlayNode][]*loopGraph, logger *slog.Logger) error {
 
   // Check if this is an exit node, and update the remainingExitNodes map if so.
   if lgs, ok := exitNodesToLoopGraph[curNode]; ok {
@@ -230,7 +222,7 @@ func updateStateForScoredNode(curNode *PlayNode, scorableFrontier *DumbQueue,
     }
   }
 
-  if err := enqueueScorableParents(scorableFrontier, curNode); err != nil {
+  if err := enqueueScorableParents(scorableFrontier, curNode, logger); err != nil {
     return err
   }
   return nil
@@ -238,7 +230,7 @@ func updateStateForScoredNode(curNode *PlayNode, scorableFrontier *DumbQueue,
 
 
 // Handle enqueueing scorable parents of a loop after the loop has been scored.
-func enqueueScorableParentsOfLoop(lg *loopGraph, scorableFrontier *DumbQueue, remainingExitNodes map[*loopGraph]map[*PlayNode]int, exitNodesToLoopGraph map[*PlayNode][]*loopGraph) error {
+func enqueueScorableParentsOfLoop(lg *loopGraph, scorableFrontier *DumbQueue, remainingExitNodes map[*loopGraph]map[*PlayNode]int, exitNodesToLoopGraph map[*PlayNode][]*loopGraph, logger *slog.Logger) error {
   for i, ln := 0, lg.head; i < lg.size; i, ln = i+1, ln.nextNode {
     pn := ln.pn
     // Invariant: all loop nodes should be scored when we try this.
@@ -246,7 +238,7 @@ func enqueueScorableParentsOfLoop(lg *loopGraph, scorableFrontier *DumbQueue, re
       return errors.New(fmt.Sprintf("Loop node is not scored: %+v", ln))
     }
     // Enqueue scorable parents and update our exit node graphs
-    if err := updateStateForScoredNode(pn, scorableFrontier, remainingExitNodes, exitNodesToLoopGraph); err != nil {
+    if err := updateStateForScoredNode(pn, scorableFrontier, remainingExitNodes, exitNodesToLoopGraph, logger); err != nil {
       return err
     }
   }
@@ -272,20 +264,20 @@ func dequeuePlayNode(dq *DumbQueue) (*PlayNode, error) {
 }
 
 func scoreNodeAndUpdateState(curNode *PlayNode, scorableFrontier *DumbQueue, 
-    remainingExitNodes map[*loopGraph]map[*PlayNode]int, exitNodesToLoopGraph map[*PlayNode][]*loopGraph) error {
+    remainingExitNodes map[*loopGraph]map[*PlayNode]int, exitNodesToLoopGraph map[*PlayNode][]*loopGraph, logger *slog.Logger) error {
     // Nodes on the scorable frontier must be scorable. If they're not, they might have been enqueued twice,
     // so drop them
     if !isScorable(curNode) {
-      fmt.Printf("Node on frontier is not scorable: %s\n", curNode.toString())
+      logger.Debug("Node on frontier is not scorable", "node", lazyLogValue(curNode.toString))
       return nil
     }
 
     // Score the node
-    if err := curNode.updateScore(); err != nil {
+    if err := curNode.updateScore(logger); err != nil {
       return err
     }
 
-    if err := updateStateForScoredNode(curNode, scorableFrontier, remainingExitNodes, exitNodesToLoopGraph); err != nil {
+    if err := updateStateForScoredNode(curNode, scorableFrontier, remainingExitNodes, exitNodesToLoopGraph, logger); err != nil {
       return err
     }
     return nil
@@ -297,7 +289,7 @@ func scoreNodeAndUpdateState(curNode *PlayNode, scorableFrontier *DumbQueue,
 // Idea: loop over the scorable frontier until it's empty. At that point, we've scored all the nodes that we can without
 // processing loops. Therefore there should be some loops that have all exit nodes scored. Score those exit nodes, then
 // put the parents of the loop onto the scorable frontier, and repeat.
-func propagateScores(scorableFrontier *DumbQueue, remainingExitNodes map[*loopGraph]map[*PlayNode]int, exitNodesToLoopGraph map[*PlayNode][]*loopGraph) error {
+func propagateScores(scorableFrontier *DumbQueue, remainingExitNodes map[*loopGraph]map[*PlayNode]int, exitNodesToLoopGraph map[*PlayNode][]*loopGraph, logger *slog.Logger) error {
   // Drain the scorable frontier
   for loopCount := 0; scorableFrontier.size > 0; loopCount++ {
     if loopCount > 10000 {
@@ -307,7 +299,7 @@ func propagateScores(scorableFrontier *DumbQueue, remainingExitNodes map[*loopGr
     if err != nil {
       return err
     }
-    if err := scoreNodeAndUpdateState(curNode, scorableFrontier, remainingExitNodes, exitNodesToLoopGraph); err != nil {
+    if err := scoreNodeAndUpdateState(curNode, scorableFrontier, remainingExitNodes, exitNodesToLoopGraph, logger); err != nil {
       return err
     }
   }
@@ -341,13 +333,14 @@ func getLoopsWithFewestUnscoredExitNodes(unscoredLoopGraphs map[*loopGraph]bool,
   return returnLoops
 }
 
-func scorePlayGraph(leaves map[*PlayNode][]*PlayNode, loopsToExitNodes map[*loopGraph]map[*PlayNode]int) error {
+func scorePlayGraph(leaves map[*PlayNode][]*PlayNode, loopsToExitNodes map[*loopGraph]map[*PlayNode]int, run *solveRun) error {
+  logger := run.logger
   // TODO also pass loops?
   // Compute the exit nodes; this map maintains all unscored exit nodes of a loop
   loopsToUnscoredExitNodes := copyLoopsToExitNodes(loopsToExitNodes)
   unscoredLoopGraphs := createUnscoredLoopGraphMap(loopsToExitNodes)
   // Need the inverse map too:
-  exitNodesToLoopGraph := invertExitNodesMap(loopsToUnscoredExitNodes) 
+  exitNodesToLoopGraph := invertExitNodesMap(loopsToUnscoredExitNodes, logger) 
   // Keep a running set of nodes that can (definitely?) be scored(?)
   scorableFrontier := createDumbQueue() // Values are *PlayNode
 
@@ -357,16 +350,12 @@ func scorePlayGraph(leaves map[*PlayNode][]*PlayNode, loopsToExitNodes map[*loop
     if len(leaf.nextNodes) != 0 {
       return errors.New("Not a leaf: " + leaf.toString()) 
     }
-    if err := scoreNodeAndUpdateState(leaf, scorableFrontier, loopsToUnscoredExitNodes, exitNodesToLoopGraph); err != nil {
+    if err := scoreNodeAndUpdateState(leaf, scorableFrontier, loopsToUnscoredExitNodes, exitNodesToLoopGraph, logger); err != nil {
       return err
     }
   }
 
-  if DEBUG {
-    fmt.Printf("scorePlayGraph: before loop: frontier size %d, unscoredLoopGraphs size %d\n", scorableFrontier.size, len(unscoredLoopGraphs))
-    fmt.Printf("Loops to exit nodes: %+v\n", loopsToUnscoredExitNodes)
-    fmt.Printf("Exit nodes to loops: %+v\n", exitNodesToLoopGraph)
-  }
+  logger.Debug("Scoring play graph", "frontier_size", scorableFrontier.size, "unscored_loop_graphs", len(unscoredLoopGraphs))
   // Scoring iteration: consists of two steps.
   // Step 1: for all loops that have no unscored exit nodes, compute their scores and enqueue their scorable parents.
   // Step 2: propagate scores from the scorable frontier until the frontier is empty.
@@ -379,24 +368,19 @@ func scorePlayGraph(leaves map[*PlayNode][]*PlayNode, loopsToExitNodes map[*loop
     if loopCount > maxLoopCount {
       return errors.New("maxLoopCount exceeded in scoring iteration, frontier: " + scorableFrontier.toString(PlayNodeToString))
     }
-    if DEBUG {
-      fmt.Printf("scorePlayGraph: loop count %d, frontier size %d, unscoredLoopGraphs size %d\n", loopCount, scorableFrontier.size, len(unscoredLoopGraphs))
-      fmt.Printf("Loops to exit nodes: %+v\n", loopsToUnscoredExitNodes)
-      fmt.Printf("===== Unscored loop graphs: %+v\n", unscoredLoopGraphs)
-      fmt.Printf("Exit nodes to loops: %+v\n", exitNodesToLoopGraph)
-    }
+    logger.Debug("Scoring iteration", "loop_count", loopCount, "frontier_size", scorableFrontier.size,
+      "unscored_loop_graphs", len(unscoredLoopGraphs))
+    run.stats.PropagationRounds++
     // if the frontier is empty, score a loop instead.
     if scorableFrontier.isEmpty() {
 
       loopGraphsToScore := getLoopsWithFewestUnscoredExitNodes(unscoredLoopGraphs, loopsToUnscoredExitNodes)
       for _, lg := range loopGraphsToScore {
-        if DEBUG {
-          fmt.Printf("Scoring loop graph %p\n", lg)
-        }
-        if err := scoreLoop(lg); err != nil {
+        logger.Debug("Scoring loop graph", "size", lg.size)
+        if err := scoreLoop(lg, run); err != nil {
           return err
         }
-        if err := enqueueScorableParentsOfLoop(lg, scorableFrontier, loopsToUnscoredExitNodes, exitNodesToLoopGraph); err != nil {
+        if err := enqueueScorableParentsOfLoop(lg, scorableFrontier, loopsToUnscoredExitNodes, exitNodesToLoopGraph, logger); err != nil {
           return err
         }
       }
@@ -407,7 +391,7 @@ func scorePlayGraph(leaves map[*PlayNode][]*PlayNode, loopsToExitNodes map[*loop
     }
 
     // Propagate the scores
-    if err := propagateScores(scorableFrontier, loopsToUnscoredExitNodes, exitNodesToLoopGraph); err != nil {
+    if err := propagateScores(scorableFrontier, loopsToUnscoredExitNodes, exitNodesToLoopGraph, logger); err != nil {
       return err
     }
   }
@@ -418,10 +402,11 @@ func scorePlayGraph(leaves map[*PlayNode][]*PlayNode, loopsToExitNodes map[*loop
 
 // Instead of doing fancy loop detection, just give all loop nodes a heuristic score off the bat,
 // then to a score solidification down to the leaves. 
-func simpleScore(root *PlayNode, loopGraphs map[*loopGraph]int, maxDepth int) error {
+func simpleScore(root *PlayNode, loopGraphs map[*loopGraph]int, maxDepth int, run *solveRun) error {
   for lg, _ := range loopGraphs {
-    applyHeuristicScores(lg)  
+    run.stats.HeuristicScores += applyHeuristicScores(lg, run.logger)
   }
-  solidifyScores(root, maxDepth)
+  solidifyScores(root, maxDepth, run.logger)
+  run.stats.SolidifyIterations++
   return nil
 }
@@ -2,6 +2,7 @@ package main
 
 import (
   "fmt"
+  "log/slog"
   "testing"
 )
 
@@ -48,7 +49,7 @@ func TestScorePropagateScoresSimple(t *testing.T) {
   // Score
   leaves := make(map[*PlayNode][]*PlayNode, 1)
   leaves[sonNode] = []*PlayNode{}
-  if err := scorePlayGraph(leaves, make(map[*loopGraph]map[*PlayNode]int)); err != nil {
+  if err := scorePlayGraph(leaves, make(map[*loopGraph]map[*PlayNode]int), createSolveRun(nil, slog.Default())); err != nil {
     t.Fatal(err.Error())
   }
 
@@ -91,7 +92,7 @@ func TestScorePropagateScoresFork(t *testing.T) {
   leaves := make(map[*PlayNode][]*PlayNode, 1)
   leaves[three] = []*PlayNode{}
   // Should require exactly two nodes on the frontier (two and two prime)
-  if err := scorePlayGraph(leaves, make(map[*loopGraph]map[*PlayNode]int)); err != nil {
+  if err := scorePlayGraph(leaves, make(map[*loopGraph]map[*PlayNode]int), createSolveRun(nil, slog.Default())); err != nil {
     t.Fatal(err.Error())
   }
 
@@ -142,7 +143,7 @@ func TestScorePropagateScoresLoop1(t *testing.T) {
   loopGraphs := createLoopGraphs(loops) 
   loopGraphsToExitNodes := getAllExitNodes(loopGraphs)
 
-  if err := scorePlayGraph(leaves, loopGraphsToExitNodes); err != nil {
+  if err := scorePlayGraph(leaves, loopGraphsToExitNodes, createSolveRun(nil, slog.Default())); err != nil {
     t.Fatal(err.Error())
   }
 
@@ -201,7 +202,7 @@ func TestScorePropagateScoresLoop2(t *testing.T) {
   loopGraphs := createLoopGraphs(loops) 
   loopGraphsToExitNodes := getAllExitNodes(loopGraphs)
 
-  if err := scorePlayGraph(leaves, loopGraphsToExitNodes); err != nil {
+  if err := scorePlayGraph(leaves, loopGraphsToExitNodes, createSolveRun(nil, slog.Default())); err != nil {
     t.Fatal(err.Error())
   }
 
@@ -289,7 +290,7 @@ func TestScorePropagateScoresComplex(t *testing.T) {
   loopGraphs := createLoopGraphs(loops) 
   loopGraphsToExitNodes := getAllExitNodes(loopGraphs)
 
-  if err := scorePlayGraph(leaves, loopGraphsToExitNodes); err != nil {
+  if err := scorePlayGraph(leaves, loopGraphsToExitNodes, createSolveRun(nil, slog.Default())); err != nil {
     t.Fatal(err.Error())
   }
 
@@ -315,7 +316,7 @@ func TestScoreSimpleLoop(t *testing.T) {
   loops := createSimpleLoop()
   distinctLoopGraphs := createLoopGraphs(loops) 
   loopGraphsToExitNodes := getAllExitNodes(distinctLoopGraphs)
-  if err := scorePlayGraph(make(map[*PlayNode][]*PlayNode), loopGraphsToExitNodes); err != nil {
+  if err := scorePlayGraph(make(map[*PlayNode][]*PlayNode), loopGraphsToExitNodes, createSolveRun(nil, slog.Default())); err != nil {
     t.Fatal(err.Error())
   }
   for _, loop := range loops {
@@ -0,0 +1,275 @@
+package main
+
+import (
+  "fmt"
+  "log/slog"
+  "math"
+  "math/rand"
+  "strconv"
+  "strings"
+  "sync"
+)
+
+// Engines that search the graph themselves instead of reading the solver's scores, so they can be pitted against
+// the solver in tournaments. They only use the graph for its Moves; a node's score is never looked at.
+
+const ALPHA_BETA_DEFAULT_DEPTH = 4
+const MCTS_DEFAULT_BUDGET = 1000
+// Playouts that go on this long count as draws, random games can cycle forever.
+const MCTS_PLAYOUT_LIMIT = 100
+const MCTS_EXPLORATION = 1.4
+
+// Engine specs are a difficulty, or an engine name with an optional parameter: greedy, alphabeta:<depth>,
+// mcts:<playouts>, or exec:<command> for an external engine.
+var ENGINE_SPEC_NAMES []string = append(append([]string{}, DIFFICULTY_NAMES...), "greedy", "alphabeta:<depth>", "mcts:<playouts>", EXTERNAL_ENGINE_PREFIX + "<command>")
+
+func parseEngineParam(spec string, param string, defaultValue int) (int, error) {
+  if param == "" {
+    return defaultValue, nil
+  }
+  value, err := strconv.Atoi(param)
+  if err != nil || value < 1 {
+    return 0, fmt.Errorf("Bad engine %s, expected a positive number after the colon", spec)
+  }
+  return value, nil
+}
+
+// Parse an engine spec into an engine. Seeded engines play the same games every time. External engines are started
+// right away, see closeEngines.
+func engineForSpec(spec string, seed int64) (Engine, error) {
+  if strings.HasPrefix(spec, EXTERNAL_ENGINE_PREFIX) {
+    engine, err := startExternalEngine(strings.TrimPrefix(spec, EXTERNAL_ENGINE_PREFIX), CONFIG.EngineProcesses, slog.Default())
+    if err != nil {
+      return nil, err
+    }
+    return engine, nil
+  }
+  parts := strings.SplitN(spec, ":", 2)
+  name, param := parts[0], ""
+  if len(parts) == 2 {
+    param = parts[1]
+  }
+  switch name {
+  case "greedy":
+    if param != "" {
+      return nil, fmt.Errorf("Bad engine %s, greedy doesn't take a parameter", spec)
+    }
+    return createGreedyEngine(seed), nil
+  case "alphabeta":
+    depth, err := parseEngineParam(spec, param, ALPHA_BETA_DEFAULT_DEPTH)
+    if err != nil {
+      return nil, err
+    }
+    return createAlphaBetaEngine(depth), nil
+  case "mcts":
+    budget, err := parseEngineParam(spec, param, MCTS_DEFAULT_BUDGET)
+    if err != nil {
+      return nil, err
+    }
+    return createMctsEngine(budget, seed), nil
+  }
+  if _, ok := DIFFICULTY_MISTAKE_RATES[spec]; !ok {
+    return nil, fmt.Errorf("Unknown engine %s, must be one of: %s", spec, strings.Join(ENGINE_SPEC_NAMES, ", "))
+  }
+  return createSolverEngine(spec, seed)
+}
+
+// The heuristic from the Player to move's point of view, with wins and losses stretched so sooner is better.
+// depthLeft is how many more plies the search would have looked at.
+func searchScore(node *PlayNode, depthLeft int) float64 {
+  score := float64(node.getHeuristicScoreForCurrentPlayer())
+  if node.isTerminal() {
+    return score * float64(1 + depthLeft)
+  }
+  return score
+}
+
+// ==== Greedy ====
+
+// Plays whichever Move leaves the opponent worst off right now, picking randomly between equally good Moves.
+type greedyEngine struct {
+  mu sync.Mutex
+  rng *rand.Rand
+}
+
+func createGreedyEngine(seed int64) *greedyEngine {
+  return &greedyEngine{sync.Mutex{}, rand.New(rand.NewSource(seed))}
+}
+
+func (e *greedyEngine) name() string {
+  return "greedy"
+}
+
+func (e *greedyEngine) chooseMove(node *PlayNode) (Move, error) {
+  if len(node.nextNodes) == 0 {
+    return Move{}, fmt.Errorf("No Moves available from %s", node.gs.toString())
+  }
+  var best []Move
+  bestScore := math.Inf(-1)
+  for _, m := range sortedMoves(node.nextNodes) {
+    score := -searchScore(node.nextNodes[m], 0)
+    if score > bestScore {
+      best, bestScore = []Move{m}, score
+    } else if score == bestScore {
+      best = append(best, m)
+    }
+  }
+  e.mu.Lock()
+  defer e.mu.Unlock()
+  return best[e.rng.Intn(len(best))], nil
+}
+
+// ==== Alpha-beta ====
+
+// Looks depth plies ahead with the heuristic at the horizon.
+type alphaBetaEngine struct {
+  depth int
+}
+
+func createAlphaBetaEngine(depth int) *alphaBetaEngine {
+  return &alphaBetaEngine{depth}
+}
+
+func (e *alphaBetaEngine) name() string {
+  return "alphabeta:" + strconv.Itoa(e.depth)
+}
+
+// Negamax: the score is for the Player to move at node.
+func alphaBeta(node *PlayNode, depthLeft int, alpha float64, beta float64) float64 {
+  if depthLeft == 0 || len(node.nextNodes) == 0 {
+    return searchScore(node, depthLeft)
+  }
+  best := math.Inf(-1)
+  for _, m := range sortedMoves(node.nextNodes) {
+    score := -alphaBeta(node.nextNodes[m], depthLeft - 1, -beta, -alpha)
+    best = math.Max(best, score)
+    alpha = math.Max(alpha, score)
+    if alpha >= beta {
+      break
+    }
+  }
+  return best
+}
+
+func (e *alphaBetaEngine) chooseMove(node *PlayNode) (Move, error) {
+  if len(node.nextNodes) == 0 {
+    return Move{}, fmt.Errorf("No Moves available from %s", node.gs.toString())
+  }
+  var bestMove Move
+  bestScore := math.Inf(-1)
+  for _, m := range sortedMoves(node.nextNodes) {
+    // Searching each root Move with a window just above the best so far still prunes, and ties go to the first Move.
+    score := -alphaBeta(node.nextNodes[m], e.depth - 1, math.Inf(-1), -bestScore)
+    if score > bestScore {
+      bestMove, bestScore = m, score
+    }
+  }
+  return bestMove, nil
+}
+
+// ==== Monte Carlo tree search ====
+
+// Plays budget random playouts per Move, steering them towards promising Moves with UCT. Positions repeat, so the
+// tree is keyed by node and statistics are shared between transpositions.
+type mctsEngine struct {
+  budget int
+  mu sync.Mutex
+  rng *rand.Rand
+}
+
+type mctsStats struct {
+  visits int
+  // Reward for the Player who moved into the node: 1 per win, 0.5 per draw.
+  reward float64
+}
+
+func createMctsEngine(budget int, seed int64) *mctsEngine {
+  return &mctsEngine{budget, sync.Mutex{}, rand.New(rand.NewSource(seed))}
+}
+
+func (e *mctsEngine) name() string {
+  return "mcts:" + strconv.Itoa(e.budget)
+}
+
+// How good the result is for the given Player.
+func rewardForTurn(result GameResult, t Turn) float64 {
+  if result == Ongoing {
+    return 0.5
+  }
+  if (result == Player1Wins) == (t == Player1) {
+    return 1
+  }
+  return 0
+}
+
+// Plays random Moves until the game ends or the playout limit is hit.
+func (e *mctsEngine) playout(node *PlayNode) GameResult {
+  for i := 0; i < MCTS_PLAYOUT_LIMIT && len(node.nextNodes) > 0; i++ {
+    moves := sortedMoves(node.nextNodes)
+    node = node.nextNodes[moves[e.rng.Intn(len(moves))]]
+  }
+  return checkGameResult(node.gs)
+}
+
+// Picks the child with the best upper confidence bound, or an unvisited one.
+func (e *mctsEngine) selectChild(node *PlayNode, tree map[*PlayNode]*mctsStats) *PlayNode {
+  parentVisits := float64(tree[node].visits)
+  var best *PlayNode
+  bestBound := math.Inf(-1)
+  for _, m := range sortedMoves(node.nextNodes) {
+    child := node.nextNodes[m]
+    stats, ok := tree[child]
+    if !ok || stats.visits == 0 {
+      return child
+    }
+    bound := stats.reward / float64(stats.visits) + MCTS_EXPLORATION * math.Sqrt(math.Log(parentVisits) / float64(stats.visits))
+    if bound > bestBound {
+      best, bestBound = child, bound
+    }
+  }
+  return best
+}
+
+func (e *mctsEngine) chooseMove(node *PlayNode) (Move, error) {
+  if len(node.nextNodes) == 0 {
+    return Move{}, fmt.Errorf("No Moves available from %s", node.gs.toString())
+  }
+  e.mu.Lock()
+  defer e.mu.Unlock()
+
+  tree := map[*PlayNode]*mctsStats{node: &mctsStats{}}
+  for i := 0; i < e.budget; i++ {
+    // Walk down the tree, stopping at a new node or when the path loops back on itself.
+    path := []*PlayNode{node}
+    onPath := map[*PlayNode]bool{node: true}
+    cur := node
+    for len(cur.nextNodes) > 0 {
+      next := e.selectChild(cur, tree)
+      if onPath[next] {
+        break
+      }
+      path = append(path, next)
+      onPath[next] = true
+      if _, ok := tree[next]; !ok {
+        tree[next] = &mctsStats{}
+        break
+      }
+      cur = next
+    }
+    result := e.playout(path[len(path) - 1])
+    for _, n := range path {
+      stats := tree[n]
+      stats.visits++
+      stats.reward += rewardForTurn(result, invertTurn(n.gs.T))
+    }
+  }
+
+  var bestMove Move
+  bestVisits := -1
+  for _, m := range sortedMoves(node.nextNodes) {
+    if stats, ok := tree[node.nextNodes[m]]; ok && stats.visits > bestVisits {
+      bestMove, bestVisits = m, stats.visits
+    }
+  }
+  return bestMove, nil
+}
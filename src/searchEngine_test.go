@@ -0,0 +1,81 @@
+package main
+
+import (
+  "fmt"
+  "testing"
+)
+
+func TestEngineForSpec(t *testing.T) {
+  fmt.Println("starting TestEngineForSpec")
+  for spec, name := range map[string]string{"perfect": "perfect", "greedy": "greedy", "alphabeta": "alphabeta:4", "alphabeta:2": "alphabeta:2", "mcts:50": "mcts:50"} {
+    engine, err := engineForSpec(spec, 1)
+    if err != nil {
+      t.Fatal(err.Error())
+    }
+    if engine.name() != name {
+      t.Fatalf("Expected %s for %s, got %s", name, spec, engine.name())
+    }
+  }
+  for _, spec := range []string{"", "impossible", "greedy:2", "alphabeta:0", "mcts:lots"} {
+    if _, err := engineForSpec(spec, 1); err == nil {
+      t.Fatalf("Expected an error for engine %q", spec)
+    }
+  }
+}
+
+func TestSearchEnginesTakeWins(t *testing.T) {
+  fmt.Println("starting TestSearchEnginesTakeWins")
+  _, visitedStates, _, _, _, err := solve(initGame(), DEFAULT_MAX_DEPTH)
+  if err != nil {
+    t.Fatal(err.Error())
+  }
+  for _, spec := range []string{"greedy", "alphabeta:3", "mcts:50"} {
+    engine, err := engineForSpec(spec, 1)
+    if err != nil {
+      t.Fatal(err.Error())
+    }
+    for _, node := range visitedStates {
+      if len(node.nextNodes) == 0 {
+        continue
+      }
+      m, err := engine.chooseMove(node)
+      if err != nil {
+        t.Fatal(err.Error())
+      }
+      if node.nextNodes[m] == nil {
+        t.Fatalf("%s played an illegal Move %+v from %s", spec, m, node.gs.toPositionString())
+      }
+      // Every engine sees a win in one.
+      for _, child := range node.nextNodes {
+        if child.isTerminal() && !node.nextNodes[m].isTerminal() {
+          t.Fatalf("%s missed a win in one from %s", spec, node.gs.toPositionString())
+        }
+      }
+    }
+  }
+}
+
+func TestAlphaBetaAvoidsLosses(t *testing.T) {
+  fmt.Println("starting TestAlphaBetaAvoidsLosses")
+  _, visitedStates, _, _, _, err := solve(initGame(), DEFAULT_MAX_DEPTH)
+  if err != nil {
+    t.Fatal(err.Error())
+  }
+  evals := evaluatePositions(visitedStates)
+  engine := createAlphaBetaEngine(4)
+  // Looking 4 plies ahead is enough to play any win in 3 and to not walk into a loss in 2.
+  for state, node := range visitedStates {
+    eval := evals[state]
+    if len(node.nextNodes) == 0 || eval.Outcome == Draw || eval.Plies > 3 {
+      continue
+    }
+    m, err := engine.chooseMove(node)
+    if err != nil {
+      t.Fatal(err.Error())
+    }
+    played := evals[*node.nextNodes[m].gs].forMover()
+    if played.isBetterThan(eval) || eval.isBetterThan(played) {
+      t.Fatalf("alphabeta:4 played %+v from %s, which is %s instead of %s", m, state.toPositionString(), played.toString(), eval.toString())
+    }
+  }
+}
@@ -1,10 +1,16 @@
 package main
 
 import (
-    "os"
+    "context"
+    "errors"
     "fmt"
-    "log"
+    "log/slog"
+    "net"
     "net/http"
+    "os"
+    "os/signal"
+    "sync/atomic"
+    "syscall"
     "io/ioutil"
     "github.com/gorilla/mux"
     "encoding/json"
@@ -15,63 +21,51 @@ type NextStateAndMove struct {
     M Move
 }
 
-func getImageRequestHandler(path string) http.Handler {
-    fn := func (w http.ResponseWriter, r *http.Request) {
-        fmt.Printf("serving image for %s\n", r.URL)
-        fileBytes, err := ioutil.ReadFile(path)
-        if err != nil {
-            panic(err)
-        }
-        fmt.Printf("num fyle bytes: %d\n", len(fileBytes))
-        w.WriteHeader(http.StatusOK)
-        w.Header().Set("Content-Type", "application/octet-stream")
-        w.Write(fileBytes)
-    }
-    return http.HandlerFunc(fn)
-}
-
-func getHomeHandler(_ *GameState) http.Handler {
-    fn := func (w http.ResponseWriter, r *http.Request) {
-        fmt.Printf("serving request %+v\n", r)
-        body, err := os.ReadFile("frontend/index.html")
-        if err != nil {
-            log.Fatalf("Error when serving index.html, Err: %s", err)
-        }
-        fmt.Fprintf(w, "%s", body)
-    }
-    return http.HandlerFunc(fn)
-}
-
-func getMoveHandler(solveMap map[GameState]*PlayNode) http.Handler {
+func getMoveHandler(graph *solveGraph) http.Handler {
     fn := func (w http.ResponseWriter, r *http.Request) {
         body, err := ioutil.ReadAll(r.Body)
         if err != nil {
-            log.Printf("Error reading body: %v", err)
+            requestLogger(r).Warn("Error reading body", "err", err)
             http.Error(w, "can't read body", http.StatusBadRequest)
             return
         }
         gs, err := parseUiMove(body)
         if err != nil {
-            fmt.Printf("ERROR PARSING JSON: " + err.Error())
+            requestLogger(r).Debug("Error parsing move", "err", err)
             w.WriteHeader(http.StatusBadRequest)
             return
         }
-        fmt.Printf("Got %+v\n", gs)
+        difficulty, err := parseUiDifficulty(body)
+        if err != nil {
+            requestLogger(r).Debug("Error parsing difficulty", "err", err)
+            http.Error(w, err.Error(), http.StatusBadRequest)
+            return
+        }
+        engine, err := engineForDifficulty(difficulty)
+        if err != nil {
+            http.Error(w, err.Error(), http.StatusBadRequest)
+            return
+        }
+
+        if err := validatePosition(gs); err != nil {
+            http.Error(w, err.Error(), http.StatusBadRequest)
+            return
+        }
 
         // Normalize the game state:
         gps := createGamePlayState(gs)
-        // Look up the state in our solve map
-        curNode, exists := solveMap[*gps.normalizedState]
-        if !exists {
-            log.Printf("Did not find game state in solve map: %s", gps.toString())
-            http.Error(w, "can't read body", http.StatusBadRequest)
+        // Look up the state in our solve map, solving from it if we haven't seen it before
+        curNode, err := graph.lookupOrSolve(gps.normalizedState)
+        if err != nil {
+            requestLogger(r).Error("Error solving game state", "position", gs.toPositionString(), "err", err)
+            http.Error(w, "can't solve game state", http.StatusInternalServerError)
             return
         }
         // Get the best Move for the current node:
-        normalizedComputerMove, _, err := curNode.getBestMoveAndScoreForCurrentPlayer(DEBUG, true) // TODO: don't allow unscored child?
+        normalizedComputerMove, err := chooseMoveTimed(engine, curNode)
         if err != nil {
-            log.Printf("Error finding best Move for %s", curNode.toString())
-            http.Error(w, "can't read body", http.StatusBadRequest)
+            requestLogger(r).Error("Error finding best Move", "node", curNode.toString(), "err", err)
+            http.Error(w, "can't find a move", http.StatusInternalServerError)
             return
         }
         // And translate it into the Move the Player would see
@@ -86,11 +80,10 @@ func getMoveHandler(solveMap map[GameState]*PlayNode) http.Handler {
         w.Header().Set("Content-Type", "application/json")
         jsonResp, err := json.Marshal(next) 
         if err != nil {
-            log.Printf("Error parsing json %s", err)
+            requestLogger(r).Error("Error serializing json", "err", err)
             http.Error(w, "can't read body", http.StatusBadRequest)
             return
         }
-        fmt.Printf("Serialized as %s", string(jsonResp))
 
         w.Write(jsonResp)
         // Send them our Move and the new state.
@@ -98,13 +91,182 @@ func getMoveHandler(solveMap map[GameState]*PlayNode) http.Handler {
     return http.HandlerFunc(fn)
 }
 
-func serve(initGs *GameState, solveMap map[GameState]*PlayNode) {
+// A nil authenticator turns accounts off. Paths that aren't part of the API are served by the frontend handler.
+// Requests are logged to logger.
+func createRouter(initGs *GameState, graph *solveGraph, sessions *sessionStore, lobbies *lobbyStore, auth *authenticator, frontend http.Handler, logger *slog.Logger) *mux.Router {
     r := mux.NewRouter()
-    r.Handle("/", getHomeHandler(initGs))
-    r.Handle("/static/hands.png", getImageRequestHandler("./frontend/static/hands.png"))
-    r.Handle("/static/hands_green.png", getImageRequestHandler("./frontend/static/hands_green.png"))
-    r.Handle("/static/hands_red.png", getImageRequestHandler("./frontend/static/hands_red.png"))
-    r.Handle("/move", getMoveHandler(solveMap))
-    http.Handle("/", r)
-    log.Fatal(http.ListenAndServe(":8888", nil))
-}
\ No newline at end of file
+    r.Use(requestLoggingMiddleware(logger), requestMetricsMiddleware)
+    if auth != nil {
+        r.Use(auth.middleware)
+        r.Handle("/accounts", getCreateAccountHandler(auth)).Methods("POST")
+        r.Handle("/login", getLoginHandler(auth)).Methods("POST")
+        me := r.PathPrefix("/me").Subrouter()
+        me.Use(requireAccount)
+        me.Handle("", getMeHandler(auth)).Methods("GET")
+        me.Handle("/games", getMyGamesHandler(sessions.games)).Methods("GET")
+        me.Handle("/logout", getLogoutHandler(auth)).Methods("POST")
+    }
+    r.Handle("/", frontend).Methods("GET", "HEAD")
+    r.PathPrefix("/static/").Handler(frontend).Methods("GET", "HEAD")
+    r.Handle("/move", getMoveHandler(graph))
+    r.Handle("/engines", getEnginesHandler(sessions)).Methods("GET")
+    r.Handle("/metrics", getMetricsHandler(sessions)).Methods("GET")
+    r.Handle("/games", getCreateGameHandler(sessions, graph, auth)).Methods("POST")
+    r.Handle("/games/{id}", getGameHandler(sessions)).Methods("GET")
+    r.Handle("/games/{id}/move", getGameMoveHandler(sessions)).Methods("POST")
+    r.Handle("/games/{id}/computer-move", getComputerMoveHandler(sessions, graph)).Methods("POST")
+    r.Handle("/games/{id}/events", getGameEventsHandler(sessions)).Methods("GET")
+    r.Handle("/games/{id}/analysis", getGameAnalysisHandler(sessions, graph)).Methods("GET")
+    if sessions.games != nil {
+        r.Handle("/records", getGameRecordsHandler(sessions.games)).Methods("GET")
+        r.Handle("/records/{id}", getGameRecordHandler(sessions.games)).Methods("GET")
+        r.Handle("/records/{id}/{format:cgn}", getGameRecordHandler(sessions.games)).Methods("GET")
+        r.Handle("/records/{id}/replay", getReplayHandler(sessions.games, graph)).Methods("GET")
+        r.Handle("/records/{id}/analysis", getRecordAnalysisHandler(sessions.games, graph)).Methods("GET")
+        r.Handle("/leaderboard", getLeaderboardHandler(sessions.games, createRatingTable())).Methods("GET")
+    }
+    r.Handle("/puzzles", getPuzzlesHandler(graph)).Methods("GET")
+    r.Handle("/puzzles/check", getPuzzleCheckHandler(graph)).Methods("POST")
+    r.Handle("/lobbies", getCreateLobbyHandler(lobbies, sessions, graph, auth)).Methods("POST")
+    r.Handle("/lobbies/{code}", getLobbyHandler(lobbies)).Methods("GET")
+    r.Handle("/lobbies/{code}/join", getJoinLobbyHandler(lobbies, auth)).Methods("POST")
+    r.Handle("/lobbies/{code}/ws", getLobbySocketHandler(lobbies)).Methods("GET")
+    return r
+}
+
+// ==== Serving ====
+
+// Answers health checks from the moment the server listens, and hands everything else to the router once the game is
+// solved. Orchestrators restart the server when /healthz fails, and only send it traffic while /readyz succeeds.
+type readinessHandler struct {
+    // The http.Handler for the API and frontend, unset until the server is ready.
+    router atomic.Value
+    draining atomic.Bool
+}
+
+func (h *readinessHandler) setReady(router http.Handler) {
+    h.router.Store(router)
+}
+
+// Stops /readyz succeeding, so no new traffic is sent while the server shuts down.
+func (h *readinessHandler) drain() {
+    h.draining.Store(true)
+}
+
+func (h *readinessHandler) isReady() bool {
+    return h.router.Load() != nil && !h.draining.Load()
+}
+
+func (h *readinessHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+    router, _ := h.router.Load().(http.Handler)
+    switch {
+    case r.URL.Path == "/healthz":
+        fmt.Fprintln(w, "ok")
+    case r.URL.Path == "/readyz" && h.isReady():
+        fmt.Fprintln(w, "ready")
+    case r.URL.Path == "/readyz":
+        http.Error(w, "not ready", http.StatusServiceUnavailable)
+    case router == nil:
+        w.Header().Set("Retry-After", "5")
+        http.Error(w, "the server is starting", http.StatusServiceUnavailable)
+    default:
+        router.ServeHTTP(w, r)
+    }
+}
+
+// Finished games get saved to the given store, if it's not nil. Likewise players can only sign up and log in if
+// there's an account store. Games can pick bots by name as their difficulty. Runs until SIGINT or SIGTERM, then shuts
+// down gracefully.
+func serve(initGs *GameState, loadGraph func() (*solveGraph, error), games GameStore, accounts AccountStore, bots map[string]Engine, cfg config, logger *slog.Logger) error {
+    ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+    defer stop()
+    listener, err := net.Listen("tcp", cfg.Listen)
+    if err != nil {
+        return err
+    }
+    return runServer(ctx, listener, initGs, loadGraph, games, accounts, bots, cfg, logger)
+}
+
+// Serves on the listener while loadGraph runs, and is ready once it's done. When ctx is done it stops taking new
+// requests, ends game event streams and lobby connections, lets the requests in flight finish, and saves the games
+// that are still going.
+func runServer(ctx context.Context, listener net.Listener, initGs *GameState, loadGraph func() (*solveGraph, error), games GameStore, accounts AccountStore, bots map[string]Engine, cfg config, logger *slog.Logger) error {
+    logger = componentLogger(logger, SERVER_COMPONENT)
+    var auth *authenticator
+    if accounts != nil {
+        auth = createAuthenticator(accounts)
+    }
+    sessions := createSessionStore(games, logger)
+    for name, bot := range bots {
+        if err := sessions.addBot(name, bot); err != nil {
+            return err
+        }
+    }
+    lobbies := createLobbyStore(logger)
+    ready := &readinessHandler{}
+    // Handlers only watch their request's context to end streams, so cancelling it ends every stream.
+    streams, endStreams := context.WithCancel(context.Background())
+    defer endStreams()
+    server := &http.Server{
+        Handler: ready,
+        ReadTimeout: cfg.ReadTimeout.Duration,
+        WriteTimeout: cfg.WriteTimeout.Duration,
+        IdleTimeout: cfg.IdleTimeout.Duration,
+        BaseContext: func(net.Listener) context.Context { return streams },
+    }
+    served := make(chan error, 1)
+    go func() {
+        served <- server.Serve(listener)
+    }()
+    logger.Info("Listening", "address", listener.Addr().String())
+
+    type loadResult struct {
+        graph *solveGraph
+        err error
+    }
+    loaded := make(chan loadResult, 1)
+    go func() {
+        graph, err := loadGraph()
+        loaded <- loadResult{graph, err}
+    }()
+    var serveErr error
+    select {
+    case result := <-loaded:
+        if result.err != nil {
+            serveErr = result.err
+            break
+        }
+        ready.setReady(createRouter(initGs, result.graph, sessions, lobbies, auth, getFrontendHandler(frontendFromConfig(cfg), cfg.Dev), logger))
+        logger.Info("Ready")
+        select {
+        case <-ctx.Done():
+        case serveErr = <-served:
+        }
+    case <-ctx.Done():
+    case serveErr = <-served:
+    }
+
+    logger.Info("Shutting down", "timeout", cfg.ShutdownTimeout.Duration)
+    ready.drain()
+    shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout.Duration)
+    defer cancel()
+    shutdown := make(chan error, 1)
+    go func() {
+        shutdown <- server.Shutdown(shutdownCtx)
+    }()
+    endStreams()
+    lobbies.closeConnections()
+    if err := <-shutdown; err != nil {
+        logger.Warn("Requests didn't finish in time, closing their connections", "err", err)
+        server.Close()
+    }
+    saved, err := sessions.saveUnfinished()
+    if err != nil {
+        logger.Error("Error saving unfinished games", "err", err)
+    }
+    logger.Info("Stopped", "saved_games", saved)
+    if serveErr == nil || errors.Is(serveErr, http.ErrServerClosed) {
+        return err
+    }
+    return serveErr
+}
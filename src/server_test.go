@@ -0,0 +1,164 @@
+package main
+
+import (
+  "context"
+  "encoding/json"
+  "fmt"
+  "io"
+  "log/slog"
+  "net"
+  "net/http"
+  "path/filepath"
+  "strings"
+  "sync"
+  "testing"
+  "time"
+)
+
+func TestMoveHandlerSolvesOnDemand(t *testing.T) {
+  fmt.Println("starting TestMoveHandlerSolvesOnDemand")
+  server, graph := createTestServer(t)
+  defer server.Close()
+
+  // Can't come up in a game from the start, so it's not in the solve map.
+  missing := &GameState{Player{1, 1}, Player{1, 1}, Player2}
+  if _, ok := graph.lookup(missing); ok {
+    t.Fatalf("Expected %+v to be missing from the solve graph", missing)
+  }
+  var next NextStateAndMove
+  postJson(t, server.URL + "/move", `{"p1":{"lh":1,"rh":1},"p2":{"lh":1,"rh":1},"turn":"p2"}`, http.StatusOK, &next)
+  if next.NextState.T != Player1 {
+    t.Fatalf("Unexpected response: %+v", next)
+  }
+  if _, ok := graph.lookup(missing); !ok {
+    t.Fatalf("Expected %+v to be solved on demand", missing)
+  }
+
+  postJson(t, server.URL + "/move", `{"p1":{"lh":0,"rh":0},"p2":{"lh":1,"rh":1},"turn":"p2"}`, http.StatusBadRequest, nil)
+  postJson(t, server.URL + "/move", `{"p1":{"lh":7,"rh":1},"p2":{"lh":1,"rh":1},"turn":"p2"}`, http.StatusBadRequest, nil)
+}
+
+// Run with go test -race to catch data races on the shared solve graph.
+func TestConcurrentMoveRequests(t *testing.T) {
+  fmt.Println("starting TestConcurrentMoveRequests")
+  server, graph := createTestServer(t)
+  defer server.Close()
+
+  // A mix of states from the initial solve and states that get solved on demand.
+  bodies := []string{
+    `{"p1":{"lh":1,"rh":1},"p2":{"lh":1,"rh":1},"turn":"p1"}`,
+    `{"p1":{"lh":1,"rh":1},"p2":{"lh":1,"rh":1},"turn":"p2"}`,
+    `{"p1":{"lh":2,"rh":1},"p2":{"lh":1,"rh":3},"turn":"p1","difficulty":"easy"}`,
+    `{"p1":{"lh":1,"rh":0},"p2":{"lh":1,"rh":1},"turn":"p2","difficulty":"random"}`,
+    `{"p1":{"lh":0,"rh":1},"p2":{"lh":0,"rh":1},"turn":"p1"}`,
+  }
+  var wg sync.WaitGroup
+  for i := 0; i < 40; i++ {
+    wg.Add(1)
+    go func(body string) {
+      defer wg.Done()
+      resp, err := http.Post(server.URL + "/move", "application/json", strings.NewReader(body))
+      if err != nil {
+        t.Error(err.Error())
+        return
+      }
+      defer resp.Body.Close()
+      var next NextStateAndMove
+      if resp.StatusCode != http.StatusOK {
+        t.Errorf("Unexpected status %d for %s", resp.StatusCode, body)
+      } else if err := json.NewDecoder(resp.Body).Decode(&next); err != nil {
+        t.Error(err.Error())
+      }
+    }(bodies[i % len(bodies)])
+  }
+  // Rebuild the graph while requests are in flight.
+  wg.Add(1)
+  go func() {
+    defer wg.Done()
+    if err := graph.rebuild(initGame()); err != nil {
+      t.Error(err.Error())
+    }
+  }()
+  wg.Wait()
+}
+
+func getStatus(t *testing.T, url string) int {
+  resp, err := http.Get(url)
+  if err != nil {
+    t.Fatal(err.Error())
+  }
+  resp.Body.Close()
+  return resp.StatusCode
+}
+
+func TestServerReadinessAndShutdown(t *testing.T) {
+  fmt.Println("starting TestServerReadinessAndShutdown")
+  listener, err := net.Listen("tcp", "127.0.0.1:0")
+  if err != nil {
+    t.Fatal(err.Error())
+  }
+  url := "http://" + listener.Addr().String()
+  release := make(chan struct{})
+  loadGraph := func() (*solveGraph, error) {
+    <-release
+    _, visitedStates, _, _, _, err := solve(initGame(), DEFAULT_MAX_DEPTH)
+    return createSolveGraph(visitedStates, DEFAULT_MAX_DEPTH, slog.Default()), err
+  }
+  games := createFileGameStore(filepath.Join(t.TempDir(), "games.jsonl"))
+  ctx, stop := context.WithCancel(context.Background())
+  defer stop()
+  stopped := make(chan error, 1)
+  go func() {
+    stopped <- runServer(ctx, listener, initGame(), loadGraph, games, nil, nil, defaultConfig(), slog.Default())
+  }()
+
+  // Alive but not ready while the game is solved.
+  if status := getStatus(t, url + "/healthz"); status != http.StatusOK {
+    t.Fatalf("Expected /healthz to succeed while loading, got %d", status)
+  }
+  for _, path := range []string{"/readyz", "/engines"} {
+    if status := getStatus(t, url + path); status != http.StatusServiceUnavailable {
+      t.Fatalf("Expected %s to be unavailable while loading, got %d", path, status)
+    }
+  }
+  close(release)
+  for deadline := time.Now().Add(10 * time.Second); getStatus(t, url + "/readyz") != http.StatusOK; {
+    if time.Now().After(deadline) {
+      t.Fatal("The server never got ready")
+    }
+    time.Sleep(10 * time.Millisecond)
+  }
+
+  var game gameSessionView
+  postJson(t, url + "/games", `{"side": "second", "difficulty": "hard"}`, http.StatusCreated, &game)
+  postJson(t, url + "/games/" + game.Id + "/computer-move", "", http.StatusOK, nil)
+  events, err := http.Get(url + "/games/" + game.Id + "/events")
+  if err != nil {
+    t.Fatal(err.Error())
+  }
+  defer events.Body.Close()
+
+  stop()
+  select {
+  case err := <-stopped:
+    if err != nil {
+      t.Fatal(err.Error())
+    }
+  case <-time.After(10 * time.Second):
+    t.Fatal("The server didn't stop")
+  }
+  // The event stream was ended rather than cut off.
+  if _, err := io.ReadAll(events.Body); err != nil {
+    t.Fatal(err.Error())
+  }
+  record, ok, err := games.get(game.Id)
+  if err != nil || !ok {
+    t.Fatalf("Expected the unfinished game to be saved: %v", err)
+  }
+  if record.Result != Ongoing || len(record.Moves) != 1 {
+    t.Fatalf("Unexpected saved game: %+v", record)
+  }
+  if _, err := http.Get(url + "/healthz"); err == nil {
+    t.Fatal("Expected the server to stop listening")
+  }
+}
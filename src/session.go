@@ -0,0 +1,658 @@
+package main
+
+import (
+  "crypto/rand"
+  "encoding/hex"
+  "encoding/json"
+  "errors"
+  "fmt"
+  "io/ioutil"
+  "log/slog"
+  "net/http"
+  "sort"
+  "sync"
+
+  "github.com/gorilla/mux"
+)
+
+// Game sessions live on the server so the browser doesn't have to be trusted with the game state.
+type gameSession struct {
+  mu sync.Mutex
+  id string
+  startState GameState
+  gps *gamePlayState
+  // Engines for the computer Players; a nil engine means a human plays that side.
+  engines map[Turn]Engine
+  // Who plays, and the game Moves (i.e. in the hands the Players see) played so far.
+  record *GameRecord
+  // Where the record goes once the game is over, if anywhere.
+  store GameStore
+  // Networked games only take Moves from the lobby, where Players are identified by their seat.
+  remote bool
+  // If set, every Move gets graded against this solve graph.
+  commentator *solveGraph
+  // Channels that get every gameEvent, see subscribe.
+  subscribers map[chan gameEvent]bool
+  // The account that created the game, if they were logged in. Only they can play it.
+  owner string
+  // The MoveNumber of the last gameEvent sent to subscribers.
+  published int
+  // Events ready to send but waiting for an earlier Move's, keyed by MoveNumber. Commenting takes longer for some
+  // Moves than others, but subscribers get every Move in order.
+  pending map[int]gameEvent
+  // Where errors saving and commenting on the game go, the store's logger once it's added to one.
+  logger *slog.Logger
+}
+
+// Sent to subscribers after every Move.
+type gameEvent struct {
+  GameId string
+  MoveNumber int
+  // The Player who moved, "p1" or "p2"
+  Player string
+  // The Move in the hands the Players see
+  Move Move
+  State GameState
+  Result GameResult
+  Comment *moveComment `json:",omitempty"`
+}
+
+// Errors caused by the client, e.g. playing out of turn. The server responds with 400 for these.
+type illegalMoveError struct {
+  msg string
+}
+
+func (e *illegalMoveError) Error() string {
+  return "illegalMove: " + e.msg
+}
+
+func isIllegalMoveError(err error) bool {
+  var illegalMove *illegalMoveError
+  return errors.As(err, &illegalMove)
+}
+
+func createGameSession(id string, start *GameState, engines map[Turn]Engine) *gameSession {
+  stateCopy := *start
+  players := make(map[string]string, 2)
+  for _, t := range []Turn{Player1, Player2} {
+    if engines[t] == nil {
+      players[turnToUiString(t)] = ANONYMOUS_PLAYER
+    } else {
+      players[turnToUiString(t)] = engines[t].name()
+    }
+  }
+  return &gameSession{
+    sync.Mutex{}, id, *start, createGamePlayState(&stateCopy), engines, createGameRecord(id, start, players), nil, false, nil,
+    make(map[chan gameEvent]bool), "", 0, make(map[int]gameEvent), slog.Default(),
+  }
+}
+
+// Record who's playing the given side, e.g. the name they gave when joining a lobby.
+func (s *gameSession) setPlayerName(t Turn, name string) {
+  s.mu.Lock()
+  defer s.mu.Unlock()
+  if name != "" {
+    s.record.Players[turnToUiString(t)] = name
+  }
+}
+
+// Returns a channel that gets every following gameEvent, and a function to call when done listening.
+// Subscribers that fall behind miss events rather than holding up the game; every event carries the full state.
+func (s *gameSession) subscribe() (chan gameEvent, func()) {
+  s.mu.Lock()
+  defer s.mu.Unlock()
+  events := make(chan gameEvent, 16)
+  s.subscribers[events] = true
+  unsubscribe := func() {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    delete(s.subscribers, events)
+  }
+  return events, unsubscribe
+}
+
+// A Move that was just recorded, for finishMove once s.mu is released.
+type playedMove struct {
+  before GameState
+  ev gameEvent
+  // A copy of the record if the Move ended the game and it has somewhere to be saved.
+  finished *GameRecord
+  store GameStore
+}
+
+// Record a Move that was just played from the before state. Must hold s.mu. Returns what to save and publish.
+func (s *gameSession) recordMoveLocked(before GameState, gameMove Move) playedMove {
+  s.record.addMove(gameMove, s.gps.state)
+  played := playedMove{before: before, ev: gameEvent{
+    s.id, len(s.record.Moves), turnToUiString(before.T), gameMove, *s.gps.state, s.record.Result, nil,
+  }}
+  if s.record.Result != Ongoing && s.store != nil {
+    played.finished, played.store = s.recordCopyLocked(), s.store
+  }
+  return played
+}
+
+// Saves the game if the Move finished it, then publishes the Move. Must not hold s.mu: saving writes to disk, and
+// nobody watching the game should wait for that.
+func (s *gameSession) finishMove(played playedMove) {
+  if played.finished != nil {
+    if err := played.store.save(played.finished); err != nil {
+      s.logger.Error("Error saving game", "game", s.id, "err", err)
+    }
+  }
+  s.publish(played)
+}
+
+// Comments on the Move and lets subscribers know about it, once they've heard about every Move before it. Must not
+// hold s.mu: commenting can mean solving, and the game shouldn't be locked meanwhile.
+func (s *gameSession) publish(played playedMove) {
+  ev := played.ev
+  if s.commentator != nil {
+    comment, err := commentOnMove(s.commentator, &played.before, ev.Move)
+    if err != nil {
+      s.logger.Error("Error commenting on move", "game", s.id, "move", ev.Move.toNotation(),
+        "position", played.before.toPositionString(), "err", err)
+    }
+    ev.Comment = comment
+  }
+  s.mu.Lock()
+  defer s.mu.Unlock()
+  s.pending[ev.MoveNumber] = ev
+  for {
+    next, ok := s.pending[s.published + 1]
+    if !ok {
+      return
+    }
+    delete(s.pending, next.MoveNumber)
+    s.published = next.MoveNumber
+    for events, _ := range s.subscribers {
+      select {
+      case events <- next:
+      default:
+      }
+    }
+  }
+}
+
+// What the API returns for a game.
+type gameSessionView struct {
+  Id string
+  State GameState
+  Position string
+  // The Player the human controls ("p1" or "p2"), empty if the computer plays itself.
+  Human string
+  // Difficulty of each computer Player, keyed by "p1"/"p2"
+  Difficulties map[string]string
+  // Moves played so far, in the hands the Players see
+  Moves []Move
+  Result GameResult
+}
+
+func turnToUiString(t Turn) string {
+  if t == Player1 {
+    return "p1"
+  } else {
+    return "p2"
+  }
+}
+
+// A copy of the game's record that's safe to use without holding s.mu.
+func (s *gameSession) recordCopy() *GameRecord {
+  s.mu.Lock()
+  defer s.mu.Unlock()
+  return s.recordCopyLocked()
+}
+
+// Must hold s.mu.
+func (s *gameSession) recordCopyLocked() *GameRecord {
+  record := *s.record
+  record.Moves = append([]recordedMove{}, s.record.Moves...)
+  record.Players = make(map[string]string, len(s.record.Players))
+  for p, name := range s.record.Players {
+    record.Players[p] = name
+  }
+  return &record
+}
+
+// Must hold s.mu.
+func (s *gameSession) playedMoves() []Move {
+  moves := make([]Move, len(s.record.Moves))
+  for i, m := range s.record.Moves {
+    moves[i] = m.Move
+  }
+  return moves
+}
+
+func (s *gameSession) isHuman(t Turn) bool {
+  return s.engines[t] == nil
+}
+
+func (s *gameSession) view() gameSessionView {
+  s.mu.Lock()
+  defer s.mu.Unlock()
+  human := ""
+  difficulties := make(map[string]string, 2)
+  for _, t := range []Turn{Player1, Player2} {
+    if s.isHuman(t) {
+      human = turnToUiString(t)
+    } else {
+      difficulties[turnToUiString(t)] = s.engines[t].name()
+    }
+  }
+  return gameSessionView{
+    s.id, *s.gps.state, s.startState.toPositionString(), human, difficulties, s.playedMoves(), checkGameResult(s.gps.state),
+  }
+}
+
+func (s *gameSession) checkCanMove(human bool) error {
+  if checkGameResult(s.gps.state) != Ongoing {
+    return &illegalMoveError{"the game is over"}
+  }
+  if s.isHuman(s.gps.state.T) != human {
+    return &illegalMoveError{"it's not your turn"}
+  }
+  return nil
+}
+
+// Play a Move for the human whose turn it is. Returns the state after the Move.
+func (s *gameSession) playHumanMove(gameMove Move) (GameState, error) {
+  return s.playHumanMoveWith(func() error {
+    if s.remote {
+      return &illegalMoveError{"moves in networked games go through the lobby"}
+    }
+    return nil
+  }, gameMove)
+}
+
+// Play a Move for a human in a networked game, who can only move on their own turn.
+func (s *gameSession) playMoveAs(t Turn, gameMove Move) (GameState, error) {
+  return s.playHumanMoveWith(func() error {
+    if s.gps.state.T != t && checkGameResult(s.gps.state) == Ongoing {
+      return &illegalMoveError{"it's not your turn"}
+    }
+    return nil
+  }, gameMove)
+}
+
+// Plays the Move if check, called with s.mu held, allows it, then publishes it.
+func (s *gameSession) playHumanMoveWith(check func() error, gameMove Move) (GameState, error) {
+  s.mu.Lock()
+  state, played, err := s.playHumanMoveLocked(check, gameMove)
+  s.mu.Unlock()
+  if err != nil {
+    return state, err
+  }
+  s.finishMove(played)
+  return state, nil
+}
+
+func (s *gameSession) playHumanMoveLocked(check func() error, gameMove Move) (GameState, playedMove, error) {
+  if err := check(); err != nil {
+    return *s.gps.state, playedMove{}, err
+  }
+  if err := s.checkCanMove(true); err != nil {
+    return *s.gps.state, playedMove{}, err
+  }
+  if !s.gps.state.isMoveValid(gameMove) {
+    return *s.gps.state, playedMove{}, &illegalMoveError{"hands with zero fingers are out of play"}
+  }
+  before := *s.gps.state
+  if _, err := s.gps.playGameTurn(gameMove); err != nil {
+    return *s.gps.state, playedMove{}, err
+  }
+  return *s.gps.state, s.recordMoveLocked(before, gameMove), nil
+}
+
+// Let the engine whose turn it is pick and play a Move. Returns the state after the Move and the Move in game hands.
+func (s *gameSession) playComputerMove(graph *solveGraph) (GameState, Move, error) {
+  s.mu.Lock()
+  state, played, err := s.playComputerMoveLocked(graph)
+  s.mu.Unlock()
+  if err != nil {
+    return state, Move{}, err
+  }
+  s.finishMove(played)
+  return state, played.ev.Move, nil
+}
+
+func (s *gameSession) playComputerMoveLocked(graph *solveGraph) (GameState, playedMove, error) {
+  if err := s.checkCanMove(false); err != nil {
+    return *s.gps.state, playedMove{}, err
+  }
+  curNode, err := graph.lookupOrSolve(s.gps.normalizedState)
+  if err != nil {
+    return *s.gps.state, playedMove{}, err
+  }
+  normalizedMove, err := chooseMoveTimed(s.engines[s.gps.state.T], curNode)
+  if err != nil {
+    return *s.gps.state, playedMove{}, err
+  }
+  before := *s.gps.state
+  gameMove, err := s.gps.playNormalizedTurn(normalizedMove)
+  if err != nil {
+    return *s.gps.state, playedMove{}, err
+  }
+  return *s.gps.state, s.recordMoveLocked(before, gameMove), nil
+}
+
+type sessionStore struct {
+  mu sync.Mutex
+  sessions map[string]*gameSession
+  // Where finished games get saved, nil to not save them.
+  games GameStore
+  // External engines the server was started with, keyed by the name games ask for them by. They're shared by every
+  // game, like the built-in engines.
+  bots map[string]Engine
+  logger *slog.Logger
+}
+
+func createSessionStore(games GameStore, logger *slog.Logger) *sessionStore {
+  return &sessionStore{sync.Mutex{}, make(map[string]*gameSession), games, make(map[string]Engine), logger}
+}
+
+// Lets games pick the engine as their difficulty. Only call this before serving.
+func (ss *sessionStore) addBot(name string, engine Engine) error {
+  if _, ok := DIFFICULTY_MISTAKE_RATES[name]; ok || ss.bots[name] != nil || name == "" {
+    return fmt.Errorf("Can't add an engine called %q, the name is taken", name)
+  }
+  ss.bots[name] = engine
+  return nil
+}
+
+// A bot, or a new built-in engine.
+func (ss *sessionStore) engineForDifficulty(difficulty string) (Engine, error) {
+  if bot, ok := ss.bots[difficulty]; ok {
+    return bot, nil
+  }
+  return engineForDifficulty(difficulty)
+}
+
+// Every difficulty games can ask for, bots last.
+func (ss *sessionStore) difficulties() []string {
+  names := append([]string{}, DIFFICULTY_NAMES...)
+  bots := make([]string, 0, len(ss.bots))
+  for name := range ss.bots {
+    bots = append(bots, name)
+  }
+  sort.Strings(bots)
+  return append(names, bots...)
+}
+
+func (ss *sessionStore) add(s *gameSession) {
+  ss.mu.Lock()
+  defer ss.mu.Unlock()
+  s.mu.Lock()
+  s.store = ss.games
+  s.logger = ss.logger
+  s.mu.Unlock()
+  ss.sessions[s.id] = s
+}
+
+func (ss *sessionStore) get(id string) (*gameSession, bool) {
+  ss.mu.Lock()
+  defer ss.mu.Unlock()
+  s, ok := ss.sessions[id]
+  return s, ok
+}
+
+// Saves the games that are still going, so they aren't lost when the server stops. Returns how many were saved.
+func (ss *sessionStore) saveUnfinished() (int, error) {
+  if ss.games == nil {
+    return 0, nil
+  }
+  saved := 0
+  for _, s := range ss.all() {
+    record := s.recordCopy()
+    // Games nobody has moved in yet have nothing worth keeping.
+    if record.Result != Ongoing || len(record.Moves) == 0 {
+      continue
+    }
+    if err := ss.games.save(record); err != nil {
+      return saved, fmt.Errorf("Can't save game %s: %w", record.Id, err)
+    }
+    saved++
+  }
+  return saved, nil
+}
+
+// Every session, copied so each one can be locked without holding up the store.
+func (ss *sessionStore) all() []*gameSession {
+  ss.mu.Lock()
+  defer ss.mu.Unlock()
+  sessions := make([]*gameSession, 0, len(ss.sessions))
+  for _, s := range ss.sessions {
+    sessions = append(sessions, s)
+  }
+  return sessions
+}
+
+// Games that aren't over yet.
+func (ss *sessionStore) activeGames() int {
+  active := 0
+  for _, s := range ss.all() {
+    s.mu.Lock()
+    if s.record.Result == Ongoing {
+      active++
+    }
+    s.mu.Unlock()
+  }
+  return active
+}
+
+func newSessionId() (string, error) {
+  idBytes := make([]byte, 8)
+  if _, err := rand.Read(idBytes); err != nil {
+    return "", err
+  }
+  return hex.EncodeToString(idBytes), nil
+}
+
+// ==== Handlers ====
+
+type createGameRequest struct {
+  // Starting position, e.g. "11/11 w". Empty means the usual start.
+  Position string `json:"position"`
+  AllowUnreachable bool `json:"allowUnreachable"`
+  // first, second or random. Ignored if Auto is set.
+  Side string `json:"side"`
+  Difficulty string `json:"difficulty"`
+  // Let the computer play itself, optionally with a different difficulty for each side.
+  Auto bool `json:"auto"`
+  P1Difficulty string `json:"p1Difficulty"`
+  P2Difficulty string `json:"p2Difficulty"`
+  // The human's name, for the ladder. Ignored if Auto is set.
+  Name string `json:"name"`
+}
+
+type moveRequest struct {
+  PlayerHand string `json:"playerHand"`
+  ReceiverHand string `json:"receiverHand"`
+}
+
+func enginesForRequest(sessions *sessionStore, req *createGameRequest) (map[Turn]Engine, error) {
+  engines := make(map[Turn]Engine, 2)
+  if req.Auto {
+    for t, difficulty := range map[Turn]string{Player1: req.P1Difficulty, Player2: req.P2Difficulty} {
+      if difficulty == "" {
+        difficulty = req.Difficulty
+      }
+      engine, err := sessions.engineForDifficulty(difficulty)
+      if err != nil {
+        return nil, err
+      }
+      engines[t] = engine
+    }
+    return engines, nil
+  }
+  side := req.Side
+  if side == "" {
+    side = "first"
+  }
+  human, err := parseSide(side)
+  if err != nil {
+    return nil, err
+  }
+  engine, err := sessions.engineForDifficulty(req.Difficulty)
+  if err != nil {
+    return nil, err
+  }
+  engines[human] = nil
+  engines[invertTurn(human)] = engine
+  return engines, nil
+}
+
+func writeJson(w http.ResponseWriter, r *http.Request, status int, v interface{}) {
+  jsonResp, err := json.Marshal(v)
+  if err != nil {
+    requestLogger(r).Error("Error serializing json", "err", err)
+    http.Error(w, "can't serialize response", http.StatusInternalServerError)
+    return
+  }
+  w.Header().Set("Content-Type", "application/json")
+  w.WriteHeader(status)
+  w.Write(jsonResp)
+}
+
+func readJsonBody(r *http.Request, v interface{}) error {
+  body, err := ioutil.ReadAll(r.Body)
+  if err != nil {
+    return err
+  }
+  if len(body) == 0 {
+    return nil
+  }
+  return json.Unmarshal(body, v)
+}
+
+func getCreateGameHandler(sessions *sessionStore, graph *solveGraph, auth *authenticator) http.Handler {
+  fn := func (w http.ResponseWriter, r *http.Request) {
+    var req createGameRequest
+    if err := readJsonBody(r, &req); err != nil {
+      http.Error(w, "can't read body: " + err.Error(), http.StatusBadRequest)
+      return
+    }
+    start, err := startPosition(req.Position, req.AllowUnreachable)
+    if err != nil {
+      http.Error(w, err.Error(), http.StatusBadRequest)
+      return
+    }
+    engines, err := enginesForRequest(sessions, &req)
+    if err != nil {
+      http.Error(w, err.Error(), http.StatusBadRequest)
+      return
+    }
+    name, err := playerNameForRequest(auth, r, req.Name)
+    if err != nil {
+      http.Error(w, err.Error(), http.StatusBadRequest)
+      return
+    }
+    // Make sure the computer can play from the start position.
+    if _, err := graph.lookupOrSolve(start); err != nil {
+      requestLogger(r).Error("Error solving position", "position", start.toPositionString(), "err", err)
+      http.Error(w, "can't solve position", http.StatusInternalServerError)
+      return
+    }
+    id, err := newSessionId()
+    if err != nil {
+      http.Error(w, "can't create game", http.StatusInternalServerError)
+      return
+    }
+    session := createGameSession(id, start, engines)
+    session.owner = accountFromRequest(r)
+    for t, engine := range engines {
+      if engine == nil {
+        session.setPlayerName(t, name)
+      }
+    }
+    sessions.add(session)
+    writeJson(w, r, http.StatusCreated, session.view())
+  }
+  return http.HandlerFunc(fn)
+}
+
+// GET /engines
+func getEnginesHandler(sessions *sessionStore) http.Handler {
+  fn := func (w http.ResponseWriter, r *http.Request) {
+    writeJson(w, r, http.StatusOK, sessions.difficulties())
+  }
+  return http.HandlerFunc(fn)
+}
+
+func getSessionOr404(sessions *sessionStore, w http.ResponseWriter, r *http.Request) (*gameSession, bool) {
+  id := mux.Vars(r)["id"]
+  session, ok := sessions.get(id)
+  if !ok {
+    http.Error(w, fmt.Sprintf("no game with id %s", id), http.StatusNotFound)
+  }
+  return session, ok
+}
+
+// Like getSessionOr404, but games that belong to an account can only be played by that account.
+func getOwnSessionOr404(sessions *sessionStore, w http.ResponseWriter, r *http.Request) (*gameSession, bool) {
+  session, ok := getSessionOr404(sessions, w, r)
+  if ok && session.owner != "" && session.owner != accountFromRequest(r) {
+    http.Error(w, "this game belongs to someone else", http.StatusForbidden)
+    return nil, false
+  }
+  return session, ok
+}
+
+func writeMoveError(w http.ResponseWriter, r *http.Request, err error) {
+  if isIllegalMoveError(err) {
+    http.Error(w, err.Error(), http.StatusBadRequest)
+  } else {
+    requestLogger(r).Error("Error playing move", "err", err)
+    http.Error(w, "can't play move", http.StatusInternalServerError)
+  }
+}
+
+func getGameHandler(sessions *sessionStore) http.Handler {
+  fn := func (w http.ResponseWriter, r *http.Request) {
+    if session, ok := getSessionOr404(sessions, w, r); ok {
+      writeJson(w, r, http.StatusOK, session.view())
+    }
+  }
+  return http.HandlerFunc(fn)
+}
+
+func getGameMoveHandler(sessions *sessionStore) http.Handler {
+  fn := func (w http.ResponseWriter, r *http.Request) {
+    session, ok := getOwnSessionOr404(sessions, w, r)
+    if !ok {
+      return
+    }
+    var req moveRequest
+    if err := readJsonBody(r, &req); err != nil {
+      http.Error(w, "can't read body: " + err.Error(), http.StatusBadRequest)
+      return
+    }
+    gameMove, err := parseUiHands(req.PlayerHand, req.ReceiverHand)
+    if err != nil {
+      http.Error(w, err.Error(), http.StatusBadRequest)
+      return
+    }
+    nextState, err := session.playHumanMove(gameMove)
+    if err != nil {
+      writeMoveError(w, r, err)
+      return
+    }
+    writeJson(w, r, http.StatusOK, &NextStateAndMove{nextState, gameMove})
+  }
+  return http.HandlerFunc(fn)
+}
+
+func getComputerMoveHandler(sessions *sessionStore, graph *solveGraph) http.Handler {
+  fn := func (w http.ResponseWriter, r *http.Request) {
+    session, ok := getOwnSessionOr404(sessions, w, r)
+    if !ok {
+      return
+    }
+    nextState, gameMove, err := session.playComputerMove(graph)
+    if err != nil {
+      writeMoveError(w, r, err)
+      return
+    }
+    writeJson(w, r, http.StatusOK, &NextStateAndMove{nextState, gameMove})
+  }
+  return http.HandlerFunc(fn)
+}
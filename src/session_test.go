@@ -0,0 +1,168 @@
+package main
+
+import (
+  "encoding/json"
+  "fmt"
+  "log/slog"
+  "net/http"
+  "net/http/httptest"
+  "strings"
+  "testing"
+)
+
+func createTestServer(t *testing.T) (*httptest.Server, *solveGraph) {
+  _, visitedStates, _, _, _, err := solve(initGame(), DEFAULT_MAX_DEPTH)
+  if err != nil {
+    t.Fatal(err.Error())
+  }
+  graph := createSolveGraph(visitedStates, DEFAULT_MAX_DEPTH, slog.Default())
+  return httptest.NewServer(createRouter(initGame(), graph, createSessionStore(nil, slog.Default()), createLobbyStore(slog.Default()), nil, getFrontendHandler(embeddedFrontend(), false), slog.Default())), graph
+}
+
+func postJson(t *testing.T, url string, body string, expectedStatus int, v interface{}) {
+  resp, err := http.Post(url, "application/json", strings.NewReader(body))
+  if err != nil {
+    t.Fatal(err.Error())
+  }
+  defer resp.Body.Close()
+  if resp.StatusCode != expectedStatus {
+    t.Fatalf("POST %s %s: expected status %d, got %d", url, body, expectedStatus, resp.StatusCode)
+  }
+  if v != nil {
+    if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+      t.Fatal(err.Error())
+    }
+  }
+}
+
+func TestCreateGameFromPosition(t *testing.T) {
+  fmt.Println("starting TestCreateGameFromPosition")
+  server, _ := createTestServer(t)
+  defer server.Close()
+
+  var game gameSessionView
+  postJson(t, server.URL + "/games", `{"position": "21/03 w", "side": "first", "difficulty": "hard"}`, http.StatusCreated, &game)
+  expected := GameState{Player{2, 1}, Player{0, 3}, Player1}
+  if !game.State.equals(&expected) || game.Human != "p1" || game.Difficulties["p2"] != "hard" {
+    t.Fatalf("Unexpected game: %+v", game)
+  }
+
+  postJson(t, server.URL + "/games", `{"position": "11/11 b"}`, http.StatusBadRequest, nil)
+  postJson(t, server.URL + "/games", `{"position": "51/11 w", "allowUnreachable": true}`, http.StatusBadRequest, nil)
+  postJson(t, server.URL + "/games", `{"side": "middle"}`, http.StatusBadRequest, nil)
+}
+
+func TestCreateGameSolvesUnreachablePosition(t *testing.T) {
+  fmt.Println("starting TestCreateGameSolvesUnreachablePosition")
+  server, graph := createTestServer(t)
+  defer server.Close()
+
+  start := GameState{Player{1, 1}, Player{1, 1}, Player2}
+  if _, ok := graph.lookup(&start); ok {
+    t.Fatalf("Expected %+v not to be in the solve graph", start)
+  }
+  var game gameSessionView
+  postJson(t, server.URL + "/games", `{"position": "11/11 b", "allowUnreachable": true, "side": "first"}`, http.StatusCreated, &game)
+  if _, ok := graph.lookup(&start); !ok {
+    t.Fatalf("Expected %+v to be solved on demand", start)
+  }
+  // The computer is Player 2 and moves first.
+  var next NextStateAndMove
+  postJson(t, server.URL + "/games/" + game.Id + "/computer-move", "", http.StatusOK, &next)
+  if next.NextState.T != Player1 {
+    t.Fatalf("Unexpected state after computer move: %+v", next)
+  }
+}
+
+func TestPlayGameSession(t *testing.T) {
+  fmt.Println("starting TestPlayGameSession")
+  server, _ := createTestServer(t)
+  defer server.Close()
+
+  var game gameSessionView
+  postJson(t, server.URL + "/games", `{"side": "second"}`, http.StatusCreated, &game)
+  gameUrl := server.URL + "/games/" + game.Id
+  // Not our turn yet.
+  postJson(t, gameUrl + "/move", `{"playerHand": "lh", "receiverHand": "lh"}`, http.StatusBadRequest, nil)
+
+  var next NextStateAndMove
+  for i := 0; i < 100; i++ {
+    postJson(t, gameUrl + "/computer-move", "", http.StatusOK, &next)
+    if checkGameResult(&next.NextState) != Ongoing {
+      break
+    }
+    // It's our turn now, so the computer can't move.
+    postJson(t, gameUrl + "/computer-move", "", http.StatusBadRequest, nil)
+    // Play the first legal Move.
+    gs := next.NextState
+    playerHand := "lh"
+    if gs.Player2.Lh == 0 {
+      playerHand = "rh"
+    }
+    receiverHand := "lh"
+    if gs.Player1.Lh == 0 {
+      receiverHand = "rh"
+    }
+    body := fmt.Sprintf(`{"playerHand": "%s", "receiverHand": "%s"}`, playerHand, receiverHand)
+    postJson(t, gameUrl + "/move", body, http.StatusOK, &next)
+    if checkGameResult(&next.NextState) != Ongoing {
+      break
+    }
+  }
+  if checkGameResult(&next.NextState) == Ongoing {
+    t.Fatalf("Game did not finish: %+v", next.NextState)
+  }
+
+  resp, err := http.Get(gameUrl)
+  if err != nil {
+    t.Fatal(err.Error())
+  }
+  defer resp.Body.Close()
+  var finished gameSessionView
+  if err := json.NewDecoder(resp.Body).Decode(&finished); err != nil {
+    t.Fatal(err.Error())
+  }
+  if finished.Result == Ongoing || !finished.State.equals(&next.NextState) {
+    t.Fatalf("Unexpected finished game: %+v", finished)
+  }
+  postJson(t, gameUrl + "/computer-move", "", http.StatusBadRequest, nil)
+}
+
+func TestGameAgainstBot(t *testing.T) {
+  fmt.Println("starting TestGameAgainstBot")
+  _, visitedStates, _, _, _, err := solve(initGame(), DEFAULT_MAX_DEPTH)
+  if err != nil {
+    t.Fatal(err.Error())
+  }
+  sessions := createSessionStore(nil, slog.Default())
+  if err := sessions.addBot("perfect", createGreedyEngine(1)); err == nil {
+    t.Fatal("Expected bots to not take the name of a difficulty")
+  }
+  if err := sessions.addBot("bot", createGreedyEngine(1)); err != nil {
+    t.Fatal(err.Error())
+  }
+  server := httptest.NewServer(createRouter(initGame(), createSolveGraph(visitedStates, DEFAULT_MAX_DEPTH, slog.Default()), sessions, createLobbyStore(slog.Default()), nil, getFrontendHandler(embeddedFrontend(), false), slog.Default()))
+  defer server.Close()
+
+  resp, err := http.Get(server.URL + "/engines")
+  if err != nil {
+    t.Fatal(err.Error())
+  }
+  defer resp.Body.Close()
+  var difficulties []string
+  if err := json.NewDecoder(resp.Body).Decode(&difficulties); err != nil {
+    t.Fatal(err.Error())
+  }
+  if len(difficulties) != len(DIFFICULTY_NAMES) + 1 || difficulties[len(difficulties) - 1] != "bot" {
+    t.Fatalf("Unexpected difficulties: %+v", difficulties)
+  }
+
+  var game gameSessionView
+  postJson(t, server.URL + "/games", `{"difficulty": "bot", "side": "second"}`, http.StatusCreated, &game)
+  // Games show the engine's own name.
+  if game.Difficulties["p1"] != "greedy" {
+    t.Fatalf("Expected the bot to play Player 1: %+v", game)
+  }
+  postJson(t, server.URL + "/games/" + game.Id + "/computer-move", "", http.StatusOK, nil)
+  postJson(t, server.URL + "/games", `{"difficulty": "nobody"}`, http.StatusBadRequest, nil)
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+  "log/slog"
+  "sync"
+  "sync/atomic"
+)
+
+// An immutable snapshot of solved states: normalized states mapped to their solved nodes. Nothing in a snapshot is
+// modified after it's published, so any number of goroutines can read it without locking. Solving always happens
+// on fresh nodes that aren't part of any snapshot yet.
+type solveSnapshot struct {
+  states map[GameState]*PlayNode
+  // Who wins from every state, worked out the first time someone asks, see evaluations.
+  evalsOnce sync.Once
+  evals map[GameState]positionEval
+}
+
+func createSolveSnapshot(states map[GameState]*PlayNode) *solveSnapshot {
+  return &solveSnapshot{states: states}
+}
+
+func (snap *solveSnapshot) lookup(gs *GameState) (*PlayNode, bool) {
+  node, ok := snap.states[*gs.copyAndNormalize()]
+  return node, ok
+}
+
+func (snap *solveSnapshot) size() int {
+  return len(snap.states)
+}
+
+// Evaluations of every state in the snapshot. Snapshots never change, so they're only worked out once.
+func (snap *solveSnapshot) evaluations() map[GameState]positionEval {
+  snap.evalsOnce.Do(func() {
+    snap.evals = evaluatePositions(snap.states)
+  })
+  return snap.evals
+}
+
+// Returns a new snapshot with the given states added. Existing explored nodes are kept; either copy is fine since
+// lookups always go through the map. Unexplored leaves get replaced so the next lookup finds children.
+func (snap *solveSnapshot) merge(solvedStates map[GameState]*PlayNode) *solveSnapshot {
+  merged := make(map[GameState]*PlayNode, len(snap.states) + len(solvedStates))
+  for state, node := range snap.states {
+    merged[state] = node
+  }
+  for state, node := range solvedStates {
+    if existing, ok := merged[state]; !ok || (existing.isUnexploredLeaf() && !node.isUnexploredLeaf()) {
+      merged[state] = node
+    }
+  }
+  return createSolveSnapshot(merged)
+}
+
+// The solve graph shared by the server. Positions that weren't part of the current snapshot, or that the solve
+// stopped at because of the depth cap, get solved on demand and merged into a new snapshot which is swapped in
+// atomically.
+type solveGraph struct {
+  current atomic.Value // *solveSnapshot
+  maxDepth int
+  logger *slog.Logger
+  // Serializes writers, so merges don't lose each other's states. Readers never take it.
+  mu sync.Mutex
+  // States that are being solved right now, closed when the solve is merged. Guarded by mu.
+  pending map[GameState]chan struct{}
+}
+
+func createSolveGraph(states map[GameState]*PlayNode, maxDepth int, logger *slog.Logger) *solveGraph {
+  sg := &solveGraph{maxDepth: maxDepth, logger: logger, pending: make(map[GameState]chan struct{})}
+  sg.current.Store(createSolveSnapshot(states))
+  return sg
+}
+
+func (sg *solveGraph) snapshot() *solveSnapshot {
+  return sg.current.Load().(*solveSnapshot)
+}
+
+func (sg *solveGraph) lookup(gs *GameState) (*PlayNode, bool) {
+  return sg.snapshot().lookup(gs)
+}
+
+func (sg *solveGraph) size() int {
+  return sg.snapshot().size()
+}
+
+// Solves the whole graph again from the given state and swaps it in. Readers keep using the previous snapshot until
+// the new one is ready.
+func (sg *solveGraph) rebuild(gs *GameState) error {
+  _, solvedStates, _, _, _, err := solveWithProgress(gs, sg.maxDepth, nil, sg.logger)
+  if err != nil {
+    return err
+  }
+  sg.mu.Lock()
+  defer sg.mu.Unlock()
+  sg.current.Store(createSolveSnapshot(solvedStates))
+  return nil
+}
+
+// Returns the solved node for the given state, solving from that state first if the graph doesn't contain it or
+// only has an unexplored leaf for it.
+func (sg *solveGraph) lookupOrSolve(gs *GameState) (*PlayNode, error) {
+  normalized := gs.copyAndNormalize()
+  for {
+    if node, ok := sg.lookup(normalized); ok && !node.isUnexploredLeaf() {
+      return node, nil
+    }
+    sg.mu.Lock()
+    // Check again, someone may have merged it while we waited for the lock.
+    if node, ok := sg.lookup(normalized); ok && !node.isUnexploredLeaf() {
+      sg.mu.Unlock()
+      return node, nil
+    }
+    done, solving := sg.pending[*normalized]
+    if !solving {
+      sg.pending[*normalized] = make(chan struct{})
+      sg.mu.Unlock()
+      return sg.solveAndMerge(normalized)
+    }
+    sg.mu.Unlock()
+    // Someone else is solving this state, wait for them and look it up again.
+    <-done
+  }
+}
+
+func (sg *solveGraph) solveAndMerge(normalized *GameState) (*PlayNode, error) {
+  defer func() {
+    sg.mu.Lock()
+    close(sg.pending[*normalized])
+    delete(sg.pending, *normalized)
+    sg.mu.Unlock()
+  }()
+
+  componentLogger(sg.logger, SOLVER_COMPONENT).Info("State not solved yet, solving it", "position", normalized.toPositionString())
+  // Solve outside of the lock, the new nodes aren't shared with anyone until we publish them.
+  _, solvedStates, _, _, _, err := solveWithProgress(normalized, sg.maxDepth, nil, sg.logger)
+  if err != nil {
+    return nil, err
+  }
+
+  sg.mu.Lock()
+  defer sg.mu.Unlock()
+  merged := sg.snapshot().merge(solvedStates)
+  sg.current.Store(merged)
+  node, _ := merged.lookup(normalized)
+  return node, nil
+}
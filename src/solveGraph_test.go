@@ -0,0 +1,147 @@
+package main
+
+import (
+  "fmt"
+  "log/slog"
+  "reflect"
+  "sync"
+  "testing"
+)
+
+func TestLookupOrSolveMissingState(t *testing.T) {
+  fmt.Println("starting TestLookupOrSolveMissingState")
+  _, visitedStates, _, _, _, err := solve(initGame(), DEFAULT_MAX_DEPTH)
+  if err != nil {
+    t.Fatal(err.Error())
+  }
+  graph := createSolveGraph(visitedStates, DEFAULT_MAX_DEPTH, slog.Default())
+  sizeBefore := graph.size()
+
+  // Unreachable from the start of the game, so it's not in the graph.
+  missing := &GameState{Player{1, 0}, Player{1, 1}, Player2}
+  if _, ok := graph.lookup(missing); ok {
+    t.Fatalf("Expected %+v to be missing from the solve graph", missing)
+  }
+  node, err := graph.lookupOrSolve(missing)
+  if err != nil {
+    t.Fatal(err.Error())
+  }
+  if !node.gs.equals(missing.copyAndNormalize()) || !node.isScored || len(node.nextNodes) == 0 {
+    t.Fatalf("Unexpected solved node: %s", node.toTreeString(1))
+  }
+  if graph.size() <= sizeBefore {
+    t.Fatalf("Solved nodes were not merged into the graph: %d nodes, previously %d", graph.size(), sizeBefore)
+  }
+  // Existing nodes are kept.
+  if existing, _ := graph.lookup(initGame()); existing != visitedStates[*initGame()] {
+    t.Fatal("Merging replaced an explored node")
+  }
+}
+
+func TestLookupOrSolveUnexploredLeaf(t *testing.T) {
+  fmt.Println("starting TestLookupOrSolveUnexploredLeaf")
+  // A shallow solve leaves unexplored leaves at the depth cap.
+  _, visitedStates, leaves, _, _, err := solve(initGame(), 4)
+  if err != nil {
+    t.Fatal(err.Error())
+  }
+  var unexplored *PlayNode
+  for leaf, _ := range leaves {
+    if leaf.isUnexploredLeaf() {
+      unexplored = leaf
+      break
+    }
+  }
+  if unexplored == nil {
+    t.Fatal("Expected an unexplored leaf")
+  }
+  graph := createSolveGraph(visitedStates, 4, slog.Default())
+  node, err := graph.lookupOrSolve(unexplored.gs)
+  if err != nil {
+    t.Fatal(err.Error())
+  }
+  if node == unexplored || node.isUnexploredLeaf() {
+    t.Fatalf("Expected the unexplored leaf to be solved: %s", node.toTreeString(1))
+  }
+  if existing, _ := graph.lookup(unexplored.gs); existing != node {
+    t.Fatal("Solved node was not merged into the graph")
+  }
+}
+
+func TestLookupOrSolveConcurrent(t *testing.T) {
+  fmt.Println("starting TestLookupOrSolveConcurrent")
+  graph := createSolveGraph(make(map[GameState]*PlayNode), DEFAULT_MAX_DEPTH, slog.Default())
+  states := []*GameState{initGame(), &GameState{Player{1, 0}, Player{1, 1}, Player2}}
+  var wg sync.WaitGroup
+  nodes := make([]*PlayNode, 8)
+  for i := 0; i < len(nodes); i++ {
+    wg.Add(1)
+    go func(i int) {
+      defer wg.Done()
+      node, err := graph.lookupOrSolve(states[i % len(states)])
+      if err != nil {
+        t.Error(err.Error())
+      }
+      nodes[i] = node
+    }(i)
+  }
+  wg.Wait()
+  // Everyone asking for the same state gets the same node.
+  for i := len(states); i < len(nodes); i++ {
+    if nodes[i] != nodes[i % len(states)] {
+      t.Fatalf("Got different nodes for %+v", states[i % len(states)])
+    }
+  }
+}
+
+func TestSnapshotsAreImmutable(t *testing.T) {
+  fmt.Println("starting TestSnapshotsAreImmutable")
+  _, visitedStates, _, _, _, err := solve(initGame(), DEFAULT_MAX_DEPTH)
+  if err != nil {
+    t.Fatal(err.Error())
+  }
+  graph := createSolveGraph(visitedStates, DEFAULT_MAX_DEPTH, slog.Default())
+  before := graph.snapshot()
+  sizeBefore := before.size()
+
+  missing := &GameState{Player{1, 0}, Player{1, 1}, Player2}
+  if _, err := graph.lookupOrSolve(missing); err != nil {
+    t.Fatal(err.Error())
+  }
+  if _, ok := before.lookup(missing); ok || before.size() != sizeBefore {
+    t.Fatal("Solving on demand modified a published snapshot")
+  }
+  if _, ok := graph.snapshot().lookup(missing); !ok {
+    t.Fatal("Solved state is missing from the new snapshot")
+  }
+
+  if err := graph.rebuild(initGame()); err != nil {
+    t.Fatal(err.Error())
+  }
+  if _, ok := graph.lookup(missing); ok {
+    t.Fatal("Rebuilding should replace the snapshot")
+  }
+  if node, _ := before.lookup(initGame()); node != visitedStates[*initGame()] {
+    t.Fatal("Rebuilding modified a published snapshot")
+  }
+}
+
+func TestSnapshotEvaluations(t *testing.T) {
+  fmt.Println("starting TestSnapshotEvaluations")
+  graph := createSolveGraph(make(map[GameState]*PlayNode), DEFAULT_MAX_DEPTH, slog.Default())
+  if err := graph.rebuild(initGame()); err != nil {
+    t.Fatal(err.Error())
+  }
+  snap := graph.snapshot()
+  evals := snap.evaluations()
+  if len(evals) != snap.size() || reflect.ValueOf(snap.evaluations()).Pointer() != reflect.ValueOf(evals).Pointer() {
+    t.Fatal("Expected a snapshot's evaluations to be worked out once")
+  }
+  missing := &GameState{Player{1, 0}, Player{1, 1}, Player2}
+  if _, err := graph.lookupOrSolve(missing); err != nil {
+    t.Fatal(err.Error())
+  }
+  if _, ok := graph.snapshot().evaluations()[*missing.copyAndNormalize()]; !ok {
+    t.Fatal("Expected the new snapshot to evaluate the state solved on demand")
+  }
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+  "fmt"
+  "log/slog"
+  "sort"
+  "strings"
+  "time"
+)
+
+// What the solver did, for tuning it and for watching long solves.
+
+const SOLVE_PHASE_EXPLORING = "exploring"
+const SOLVE_PHASE_LOOP_GRAPHS = "building loop graphs"
+const SOLVE_PHASE_SCORING = "scoring"
+const SOLVE_PHASE_SOLIDIFYING = "solidifying"
+const SOLVE_PHASE_DONE = "done"
+// The progress callback hears about exploring every this many states, on top of once per phase.
+const SOLVE_PROGRESS_INTERVAL = 10000
+
+type SolveStats struct {
+  // New states the explorer visited.
+  StatesExplored int
+  // Moves into states that had already been visited, elsewhere in the tree or further up the same line.
+  Transpositions int
+  // Finished games, and positions at the max depth.
+  Leaves int
+  Loops int
+  // How many loop graphs there were of each size.
+  LoopGraphSizes map[int]int
+  // Distinct nodes with a Move out of a loop graph.
+  ExitNodes int
+  // Rounds of the scorer, each scoring loop graphs and then propagating scores up from the frontier.
+  PropagationRounds int
+  // Passes of solidifyScores until no score changed.
+  SolidifyIterations int
+  // Nodes that got heuristic scores: positions at the max depth, and nodes the scorer couldn't resolve, stuck in
+  // loops.
+  HeuristicScores int
+  // Loop nodes with a child scored better than the best way out of the loop, which the scorer's sanity check says
+  // shouldn't happen.
+  MostWinningScoreViolations int
+  ExploreTime time.Duration
+  LoopGraphTime time.Duration
+  ScoreTime time.Duration
+  SolidifyTime time.Duration
+  TotalTime time.Duration
+}
+
+// Gets the stats so far when each phase starts, and while exploring. Stats are copies, apart from LoopGraphSizes.
+type SolveProgress func(phase string, stats SolveStats)
+
+func (stats SolveStats) loopGraphs() int {
+  total := 0
+  for _, count := range stats.LoopGraphSizes {
+    total += count
+  }
+  return total
+}
+
+func (stats SolveStats) toString() string {
+  sizes := make([]int, 0, len(stats.LoopGraphSizes))
+  for size := range stats.LoopGraphSizes {
+    sizes = append(sizes, size)
+  }
+  sort.Ints(sizes)
+  histogram := make([]string, len(sizes))
+  for i, size := range sizes {
+    histogram[i] = fmt.Sprintf("%d of size %d", stats.LoopGraphSizes[size], size)
+  }
+  lines := []string{
+    fmt.Sprintf("States explored: %d", stats.StatesExplored),
+    fmt.Sprintf("Transpositions: %d", stats.Transpositions),
+    fmt.Sprintf("Leaves: %d", stats.Leaves),
+    fmt.Sprintf("Loops: %d", stats.Loops),
+    fmt.Sprintf("Loop graphs: %d", stats.loopGraphs()),
+  }
+  if len(histogram) > 0 {
+    lines[len(lines) - 1] += " (" + strings.Join(histogram, ", ") + ")"
+  }
+  lines = append(lines,
+    fmt.Sprintf("Exit nodes: %d", stats.ExitNodes),
+    fmt.Sprintf("Propagation rounds: %d", stats.PropagationRounds),
+    fmt.Sprintf("Solidify iterations: %d", stats.SolidifyIterations),
+    fmt.Sprintf("Heuristic scores: %d", stats.HeuristicScores),
+    fmt.Sprintf("Most winning score violations: %d", stats.MostWinningScoreViolations),
+    fmt.Sprintf("Time: %s (exploring %s, building loop graphs %s, scoring %s, solidifying %s)",
+      stats.TotalTime, stats.ExploreTime, stats.LoopGraphTime, stats.ScoreTime, stats.SolidifyTime),
+  )
+  return strings.Join(lines, "\n") + "\n"
+}
+
+// Exit nodes shared by several loop graphs count once.
+func countExitNodes(loopsToExitNodes map[*loopGraph]map[*PlayNode]int) int {
+  exitNodes := make(map[*PlayNode]bool)
+  for _, nodes := range loopsToExitNodes {
+    for node := range nodes {
+      exitNodes[node] = true
+    }
+  }
+  return len(exitNodes)
+}
+
+// One solve's stats as it goes, who to tell about them and where it logs.
+type solveRun struct {
+  stats SolveStats
+  progress SolveProgress
+  logger *slog.Logger
+  phase string
+  phaseStart time.Time
+  // The stat the current phase's time goes to, nil between phases.
+  phaseTime *time.Duration
+}
+
+// A nil progress callback is fine.
+func createSolveRun(progress SolveProgress, logger *slog.Logger) *solveRun {
+  return &solveRun{stats: SolveStats{LoopGraphSizes: make(map[int]int)}, progress: progress, logger: logger}
+}
+
+func (run *solveRun) report() {
+  if run.progress != nil {
+    run.progress(run.phase, run.stats)
+  }
+}
+
+// Ends the current phase and starts the next, whose time goes to phaseTime.
+func (run *solveRun) startPhase(phase string, phaseTime *time.Duration) {
+  run.endPhase()
+  run.phase, run.phaseStart, run.phaseTime = phase, time.Now(), phaseTime
+  run.report()
+}
+
+func (run *solveRun) endPhase() {
+  if run.phaseTime != nil {
+    *run.phaseTime += time.Since(run.phaseStart)
+    run.phaseTime = nil
+  }
+}
+
+// Called by the explorer for every new state.
+func (run *solveRun) exploredState() {
+  run.stats.StatesExplored++
+  if run.stats.StatesExplored % SOLVE_PROGRESS_INTERVAL == 0 {
+    run.report()
+  }
+}
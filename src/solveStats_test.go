@@ -0,0 +1,64 @@
+package main
+
+import (
+  "fmt"
+  "log/slog"
+  "strings"
+  "testing"
+)
+
+func TestSolveStats(t *testing.T) {
+  fmt.Println("starting TestSolveStats")
+  phases := []string{}
+  progress := func(phase string, stats SolveStats) {
+    if len(phases) == 0 || phases[len(phases) - 1] != phase {
+      phases = append(phases, phase)
+    }
+  }
+  _, states, _, loopGraphs, stats, err := solveWithProgress(initGame(), DEFAULT_MAX_DEPTH, progress, slog.Default())
+  if err != nil {
+    t.Fatal(err.Error())
+  }
+
+  if stats.StatesExplored != len(states) {
+    t.Fatalf("Expected %d states explored, got %d", len(states), stats.StatesExplored)
+  }
+  if stats.Transpositions == 0 || stats.Leaves == 0 || stats.Loops == 0 || stats.ExitNodes == 0 {
+    t.Fatalf("Expected transpositions, leaves, loops and exit nodes: %+v", stats)
+  }
+  if stats.loopGraphs() != len(loopGraphs) {
+    t.Fatalf("Expected %d loop graphs in the histogram, got %d", len(loopGraphs), stats.loopGraphs())
+  }
+  if stats.PropagationRounds == 0 || stats.SolidifyIterations == 0 {
+    t.Fatalf("Expected the scorer to have run: %+v", stats)
+  }
+  if stats.TotalTime < stats.ExploreTime + stats.LoopGraphTime + stats.ScoreTime + stats.SolidifyTime {
+    t.Fatalf("Expected the phases to fit in the total time: %+v", stats)
+  }
+
+  expected := []string{SOLVE_PHASE_EXPLORING, SOLVE_PHASE_LOOP_GRAPHS, SOLVE_PHASE_SCORING, SOLVE_PHASE_SOLIDIFYING, SOLVE_PHASE_DONE}
+  if strings.Join(phases, ",") != strings.Join(expected, ",") {
+    t.Fatalf("Expected phases %v, got %v", expected, phases)
+  }
+  if text := stats.toString(); !strings.Contains(text, fmt.Sprintf("States explored: %d\n", len(states))) ||
+    !strings.Contains(text, fmt.Sprintf("Most winning score violations: %d\n", stats.MostWinningScoreViolations)) {
+    t.Fatalf("Unexpected stats:\n%s", text)
+  }
+}
+
+func TestSolveStatsCountsLeafHeuristics(t *testing.T) {
+  fmt.Println("starting TestSolveStatsCountsLeafHeuristics")
+  _, _, leaves, _, stats, err := solve(initGame(), 6)
+  if err != nil {
+    t.Fatal(err.Error())
+  }
+  unexplored := 0
+  for leaf := range leaves {
+    if leaf.isUnexploredLeaf() {
+      unexplored++
+    }
+  }
+  if unexplored == 0 || stats.HeuristicScores < unexplored {
+    t.Fatalf("Expected heuristic scores for the %d leaves at the max depth: %+v", unexplored, stats)
+  }
+}
@@ -2,12 +2,14 @@ package main
 
 import (
   "fmt"
+  "log/slog"
   "math"
   "sort"
+  "time"
 )
 
 const DEFAULT_MAX_DEPTH int = 150
-const useSimpleScore bool = false
+var useSimpleScore bool = false
 
 func getShallowestLeaf(leaves map[*PlayNode][]*PlayNode) (*PlayNode, []*PlayNode) {
   minLen := math.MaxInt32
@@ -24,47 +26,75 @@ func getShallowestLeaf(leaves map[*PlayNode][]*PlayNode) (*PlayNode, []*PlayNode
 }
 
 // Generate a play strategy given a starting game state. 
-func solveRetryable(curNode *PlayNode, curPath []*PlayNode, visitedStates map[GameState]*PlayNode, maxDepth int) (*PlayNode, map[GameState]*PlayNode, map[*PlayNode][]*PlayNode, map[*loopGraph]int, error) {
+// Adds what it did to run's stats.
+func solveRetryable(curNode *PlayNode, curPath []*PlayNode, visitedStates map[GameState]*PlayNode, maxDepth int, run *solveRun) (*PlayNode, map[GameState]*PlayNode, map[*PlayNode][]*PlayNode, map[*loopGraph]int, error) {
+  start := time.Now()
+  defer func() {
+    run.endPhase()
+    run.stats.TotalTime += time.Since(start)
+  }()
   // Step one: explore all possible states, and identify loops
-  root, leaves, loops, err := exploreStatesRetryable(curNode, curPath, visitedStates, maxDepth)
+  run.startPhase(SOLVE_PHASE_EXPLORING, &run.stats.ExploreTime)
+  root, leaves, loops, err := exploreStatesRetryable(curNode, curPath, visitedStates, maxDepth, run)
   if err != nil {
     return nil, nil, nil, nil, err
   }
-  if INFO {
-    fmt.Println(fmt.Sprintf("Generated Move tree with %d nodes (%d leaves, %d loops)", len(visitedStates), len(leaves), len(loops)))
-
-    minLeaf, minPath := getShallowestLeaf(leaves)
-    fmt.Printf("Shallowest leaf: %+v (%+v)\n", minLeaf, minPath)
+  run.stats.Leaves += len(leaves)
+  for leaf := range leaves {
+    // Positions at the max depth can only be scored by guessing.
+    if leaf.isUnexploredLeaf() {
+      run.stats.HeuristicScores++
+    }
   }
+  run.stats.Loops += len(loops)
+  logger := run.logger
+  logger.Info("Generated Move tree", "nodes", len(visitedStates), "leaves", len(leaves), "loops", len(loops))
+  logger.Debug("Shallowest leaf", "leaf", lazyLogValue(func() string {
+    minLeaf, minPath := getShallowestLeaf(leaves)
+    return fmt.Sprintf("%s, depth %d", minLeaf.toString(), len(minPath) - 1)
+  }))
 
   // Step two: build loop graphs and find exit nodes
+  run.startPhase(SOLVE_PHASE_LOOP_GRAPHS, &run.stats.LoopGraphTime)
   loopGraphs := createLoopGraphs(loops)
+  for lg := range loopGraphs {
+    run.stats.LoopGraphSizes[lg.size]++
+    LOOP_GRAPH_SIZE.observe(float64(lg.size))
+  }
   if useSimpleScore {
-    simpleScore(root, loopGraphs, maxDepth)
+    run.startPhase(SOLVE_PHASE_SCORING, &run.stats.ScoreTime)
+    simpleScore(root, loopGraphs, maxDepth, run)
   } else {
     loopGraphsToExitNodes := getAllExitNodes(loopGraphs)
+    run.stats.ExitNodes += countExitNodes(loopGraphsToExitNodes)
 
-    if INFO {
+    if debugEnabled(logger) {
       for lg, exitNodes := range loopGraphsToExitNodes {
-        fmt.Printf("== loop graph: %p = %+v, num exit nodes: %d\n", lg, lg, len(exitNodes))
+        logger.Debug("Loop graph", "size", lg.size, "exit_nodes", len(exitNodes))
       }
     }
 
     // Step three: propagate scores
-    if err := scorePlayGraph(leaves, loopGraphsToExitNodes); err != nil {
+    run.startPhase(SOLVE_PHASE_SCORING, &run.stats.ScoreTime)
+    if err := scorePlayGraph(leaves, loopGraphsToExitNodes, run); err != nil {
       return nil, nil, nil, nil, err
     }
     // Step four: solidify scores until convergence
+    run.startPhase(SOLVE_PHASE_SOLIDIFYING, &run.stats.SolidifyTime)
     for ;; {
-      updatedScores := solidifyScores(root, maxDepth)
+      run.stats.SolidifyIterations++
+      updatedScores := solidifyScores(root, maxDepth, logger)
       if !updatedScores {
         break
       }
     }
-    if INFO {
-      fmt.Println(fmt.Sprintf("Root score: %f\n", root.score))
-    }
+    logger.Info("Scored Move tree", "root", lazyLogValue(root.gs.toPositionString), "score", root.score)
   }
+  SOLVES.inc()
+  SOLVE_DURATION.observeDuration(start)
+  SOLVE_VISITED_STATES.set(float64(len(visitedStates)))
+  SOLVE_LEAVES.set(float64(len(leaves)))
+  SOLVE_LOOPS.set(float64(len(loops)))
   return root, visitedStates, leaves, loopGraphs, err
 }
 
@@ -74,15 +104,13 @@ type SolveCandidate struct {
 }
 
 // TODO: this is probably buggy
-func solveIterative(root *PlayNode, pathToRoot []*PlayNode, visitedStates map[GameState]*PlayNode, maxDepthPerIt int, iterations int) (*PlayNode, map[GameState]*PlayNode, map[*PlayNode][]*PlayNode, error) {
+func solveIterative(root *PlayNode, pathToRoot []*PlayNode, visitedStates map[GameState]*PlayNode, maxDepthPerIt int, iterations int, logger *slog.Logger) (*PlayNode, map[GameState]*PlayNode, map[*PlayNode][]*PlayNode, error) {
   solveCandidates := []*SolveCandidate{ &SolveCandidate{root, pathToRoot,} }
 
   for i := 0; i < iterations; i++ {
     // exit early if no more solve candidates
     if len(solveCandidates) == 0 { 
-      if INFO {
-        fmt.Println("Breaking early, no more solve candidates.")
-      }
+      logger.Debug("Breaking early, no more solve candidates")
       break
     }
     // Sort next nodes by decreasing score for current Player (i.e. best nodes for next Player first)
@@ -98,12 +126,12 @@ func solveIterative(root *PlayNode, pathToRoot []*PlayNode, visitedStates map[Ga
         continue
       }
       if _, contains := visitedStates[*curRoot.gs]; contains {
-        fmt.Println("Leaf already visited, ignoring")
+        logger.Debug("Leaf already visited, ignoring", "node", lazyLogValue(curRoot.toString))
         continue
       }
 
       // Ignore loops? is that ok??
-      _, _, leaves, _, err := solveRetryable(curRoot, curPath, visitedStates, maxDepthPerIt)
+      _, _, leaves, _, err := solveRetryable(curRoot, curPath, visitedStates, maxDepthPerIt, createSolveRun(nil, logger))
       if err != nil {
         return nil, nil, nil, err
       }
@@ -115,14 +143,14 @@ func solveIterative(root *PlayNode, pathToRoot []*PlayNode, visitedStates map[Ga
     }
     // Propagate scores down from the root. TODO: might be costly/unnecessary to do this every time?
     if i % 2 == 1  && i != iterations - 1 {
-      solidifyScores(root, math.MaxInt32)
+      solidifyScores(root, math.MaxInt32, logger)
     }
 
     // TODO: add alpha beta pruning here? remove the not-best nodes for each Player?? 
     solveCandidates = nextSolveCandidates
   }
 
-  solidifyScores(root, math.MaxInt32)
+  solidifyScores(root, math.MaxInt32, logger)
   // Leaves are the remaining solve candidates. TODO: this doesn't include terminal leaves, should it?
   leaves := make(map[*PlayNode][]*PlayNode, len(solveCandidates))
   for _, solveCandidate := range solveCandidates {
@@ -132,16 +160,26 @@ func solveIterative(root *PlayNode, pathToRoot []*PlayNode, visitedStates map[Ga
   return root, visitedStates, leaves, nil
 }
 
-// Generate a play strategy given a starting game state. 
-func solve(gs *GameState, maxDepth int) (*PlayNode, map[GameState]*PlayNode, map[*PlayNode][]*PlayNode, map[*loopGraph]int, error) {
+// Generate a play strategy given a starting game state, logging to the default logger.
+func solve(gs *GameState, maxDepth int) (*PlayNode, map[GameState]*PlayNode, map[*PlayNode][]*PlayNode, map[*loopGraph]int, SolveStats, error) {
+  return solveWithProgress(gs, maxDepth, nil, slog.Default())
+}
+
+// Like solve, telling progress how it's going and logging to logger.
+func solveWithProgress(gs *GameState, maxDepth int, progress SolveProgress, logger *slog.Logger) (*PlayNode, map[GameState]*PlayNode, map[*PlayNode][]*PlayNode, map[*loopGraph]int, SolveStats, error) {
   visitedStates := make(map[GameState]*PlayNode, 10)
   startNode := createPlayNodeCopyGs(gs)
-  return solveRetryable(startNode, []*PlayNode{startNode}, visitedStates, maxDepth)
+  run := createSolveRun(progress, componentLogger(logger, SOLVER_COMPONENT))
+  root, states, leaves, loopGraphs, err := solveRetryable(startNode, []*PlayNode{startNode}, visitedStates, maxDepth, run)
+  if err == nil {
+    run.startPhase(SOLVE_PHASE_DONE, nil)
+  }
+  return root, states, leaves, loopGraphs, run.stats, err
 }
 
-func solveWithIteration(gs *GameState, maxDepthPerIt int, iterations int) (*PlayNode, map[GameState]*PlayNode, map[*PlayNode][]*PlayNode, error) {
+func solveWithIteration(gs *GameState, maxDepthPerIt int, iterations int, logger *slog.Logger) (*PlayNode, map[GameState]*PlayNode, map[*PlayNode][]*PlayNode, error) {
   visitedStates := make(map[GameState]*PlayNode, 10)
   startNode := createPlayNodeCopyGs(gs)
   startPath := []*PlayNode{startNode}
-  return solveIterative(startNode, startPath, visitedStates, maxDepthPerIt, iterations)
+  return solveIterative(startNode, startPath, visitedStates, maxDepthPerIt, iterations, componentLogger(logger, SOLVER_COMPONENT))
 }
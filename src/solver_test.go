@@ -9,7 +9,7 @@ func testSolveTreeValid(t *testing.T) {
   startState := GameState{
     Player{1, 1}, Player{1, 1}, Player1,
   }
-  stateNode, existingStates, leaves, _, solveErr := solve(&startState, 150)
+  stateNode, existingStates, leaves, _, _, solveErr := solve(&startState, 150)
   gps := createGamePlayState(&startState)
   if solveErr != nil {
     t.Fatal(solveErr.Error())
@@ -18,6 +18,7 @@ func testSolveTreeValid(t *testing.T) {
   //   Player{4, 4}, Player{2, 2}, Player1,
   // }].toString())
   validateSolveNode(gps, stateNode, make(map[GameState]bool, len(existingStates)), existingStates, leaves, t)
+  requireVerified(t, stateNode, existingStates)
 }
 
 func TestSolveTreeValid3(t *testing.T) {
@@ -130,7 +131,7 @@ func testBestMoves(stateNode *PlayNode, t *testing.T) {
       fmt.Println("Hit leaf node, exiting")
       break;
     }
-    bestMove, scoreForCurrentPlayer, err := curNode.getBestMoveAndScoreForCurrentPlayer(false, false)
+    bestMove, scoreForCurrentPlayer, err := curNode.getBestMoveAndScoreForCurrentPlayer(nil, false)
     if err != nil {
       t.Fatal(err.Error())
     }
@@ -165,7 +166,7 @@ func testSolveBestMoves(maxDepth int, t *testing.T) {
   startState := GameState{
     Player{1, 1}, Player{1, 1}, Player1,
   }
-  stateNode, _, _, _, err := solve(&startState, maxDepth)
+  stateNode, _, _, _, _, err := solve(&startState, maxDepth)
   if err != nil {
     t.Fatal(err.Error())
   }
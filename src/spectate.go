@@ -0,0 +1,72 @@
+package main
+
+import (
+  "encoding/json"
+  "fmt"
+  "net/http"
+  "time"
+)
+
+// Spectators follow a game through a Server-Sent Events stream. The stream starts with a "state" event holding the
+// whole game (including the Moves played so far), followed by a "move" event (a gameEvent) for every Move.
+
+const SSE_KEEPALIVE_INTERVAL = 15 * time.Second
+
+func writeSseEvent(w http.ResponseWriter, flusher http.Flusher, name string, v interface{}) error {
+  data, err := json.Marshal(v)
+  if err != nil {
+    return err
+  }
+  if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", name, data); err != nil {
+    return err
+  }
+  flusher.Flush()
+  return nil
+}
+
+func getGameEventsHandler(sessions *sessionStore) http.Handler {
+  fn := func (w http.ResponseWriter, r *http.Request) {
+    session, ok := getSessionOr404(sessions, w, r)
+    if !ok {
+      return
+    }
+    flusher, ok := w.(http.Flusher)
+    if !ok {
+      http.Error(w, "streaming not supported", http.StatusInternalServerError)
+      return
+    }
+    // Subscribe before taking the view so no Move falls in between. A Move can show up in both, the MoveNumber
+    // tells spectators which ones they've already seen.
+    events, unsubscribe := session.subscribe()
+    defer unsubscribe()
+
+    w.Header().Set("Content-Type", "text/event-stream")
+    w.Header().Set("Cache-Control", "no-cache")
+    w.WriteHeader(http.StatusOK)
+    if err := writeSseEvent(w, flusher, "state", session.view()); err != nil {
+      requestLogger(r).Warn("Error sending game state", "game", session.id, "err", err)
+      return
+    }
+
+    keepalive := time.NewTicker(SSE_KEEPALIVE_INTERVAL)
+    defer keepalive.Stop()
+    for {
+      select {
+      case ev := <-events:
+        if err := writeSseEvent(w, flusher, "move", &ev); err != nil {
+          requestLogger(r).Debug("Spectator left", "game", session.id, "err", err)
+          return
+        }
+      case <-keepalive.C:
+        // Comments keep proxies from timing out quiet games.
+        if _, err := fmt.Fprint(w, ": keepalive\n\n"); err != nil {
+          return
+        }
+        flusher.Flush()
+      case <-r.Context().Done():
+        return
+      }
+    }
+  }
+  return http.HandlerFunc(fn)
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+  "bufio"
+  "encoding/json"
+  "fmt"
+  "log/slog"
+  "net/http"
+  "strings"
+  "testing"
+)
+
+// Reads the next SSE event from the stream, skipping keepalive comments.
+func readSseEvent(t *testing.T, reader *bufio.Reader) (string, []byte) {
+  name, data := "", []byte{}
+  for {
+    line, err := reader.ReadString('\n')
+    if err != nil {
+      t.Fatal(err.Error())
+    }
+    line = strings.TrimRight(line, "\n")
+    if line == "" && name != "" {
+      return name, data
+    } else if strings.HasPrefix(line, "event: ") {
+      name = strings.TrimPrefix(line, "event: ")
+    } else if strings.HasPrefix(line, "data: ") {
+      data = []byte(strings.TrimPrefix(line, "data: "))
+    }
+  }
+}
+
+func TestGameEvents(t *testing.T) {
+  fmt.Println("starting TestGameEvents")
+  server, _ := createTestServer(t)
+  defer server.Close()
+
+  var game gameSessionView
+  postJson(t, server.URL + "/games", `{"auto": true}`, http.StatusCreated, &game)
+  var first NextStateAndMove
+  postJson(t, server.URL + "/games/" + game.Id + "/computer-move", "", http.StatusOK, &first)
+
+  resp, err := http.Get(server.URL + "/games/" + game.Id + "/events")
+  if err != nil {
+    t.Fatal(err.Error())
+  }
+  defer resp.Body.Close()
+  if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "text/event-stream" {
+    t.Fatalf("Unexpected response: %d %s", resp.StatusCode, resp.Header.Get("Content-Type"))
+  }
+  reader := bufio.NewReader(resp.Body)
+
+  name, data := readSseEvent(t, reader)
+  var state gameSessionView
+  if err := json.Unmarshal(data, &state); name != "state" || err != nil {
+    t.Fatalf("Expected a state event: %s %s %v", name, data, err)
+  }
+  if len(state.Moves) != 1 || state.Moves[0] != first.M || !state.State.equals(&first.NextState) {
+    t.Fatalf("Unexpected game state: %+v", state)
+  }
+
+  var second NextStateAndMove
+  postJson(t, server.URL + "/games/" + game.Id + "/computer-move", "", http.StatusOK, &second)
+  name, data = readSseEvent(t, reader)
+  var ev gameEvent
+  if err := json.Unmarshal(data, &ev); name != "move" || err != nil {
+    t.Fatalf("Expected a move event: %s %s %v", name, data, err)
+  }
+  if ev.MoveNumber != 2 || ev.Player != "p2" || ev.Move != second.M || !ev.State.equals(&second.NextState) {
+    t.Fatalf("Unexpected move event: %+v, expected %+v", ev, second)
+  }
+
+  resp404, err := http.Get(server.URL + "/games/nope/events")
+  if err != nil {
+    t.Fatal(err.Error())
+  }
+  resp404.Body.Close()
+  if resp404.StatusCode != http.StatusNotFound {
+    t.Fatalf("Expected a 404 for a missing game, got %d", resp404.StatusCode)
+  }
+}
+
+func TestPublishInOrder(t *testing.T) {
+  fmt.Println("starting TestPublishInOrder")
+  session := createGameSession("order", initGame(), map[Turn]Engine{Player1: nil, Player2: nil})
+  // Commenting solves on demand, outside the session's lock.
+  session.commentator = createSolveGraph(make(map[GameState]*PlayNode), DEFAULT_MAX_DEPTH, slog.Default())
+  events, unsubscribe := session.subscribe()
+  defer unsubscribe()
+
+  played := []playedMove{}
+  for _, m := range []Move{{Left, Left}, {Left, Left}, {Left, Left}} {
+    session.mu.Lock()
+    _, next, err := session.playHumanMoveLocked(func() error { return nil }, m)
+    session.mu.Unlock()
+    if err != nil {
+      t.Fatal(err.Error())
+    }
+    played = append(played, next)
+  }
+  // Commenting on the first Move took longest, then the third's.
+  session.publish(played[1])
+  select {
+  case ev := <-events:
+    t.Fatalf("Expected the second Move to wait for the first: %+v", ev)
+  default:
+  }
+  session.publish(played[0])
+  session.publish(played[2])
+  for i := 1; i <= len(played); i++ {
+    ev := <-events
+    if ev.MoveNumber != i || ev.Comment == nil {
+      t.Fatalf("Expected Move %d with a comment: %+v", i, ev)
+    }
+  }
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+  "encoding/json"
+  "errors"
+  "fmt"
+  "log/slog"
+  "os"
+  "path/filepath"
+  "sort"
+)
+
+// Tablebases are the solved graph saved as JSON, so the game only has to be solved once. Every normalized state is
+// saved with its score and its Moves, and loading links the nodes back up the way the explorer left them. A
+// tablebase is only good for the settings it was solved with, so those are saved too and checked on load.
+
+type tablebaseEntry struct {
+  Position string
+  Score float32
+  Scored bool
+  // Normalized Moves in notation, to the position they lead to.
+  Moves map[string]string `json:",omitempty"`
+}
+
+type tablebaseFile struct {
+  Fingers int
+  MaxDepth int
+  Solver string
+  States []tablebaseEntry
+}
+
+func saveTablebase(path string, states map[GameState]*PlayNode, cfg config) error {
+  entries := make([]tablebaseEntry, 0, len(states))
+  for state, node := range states {
+    moves := make(map[string]string, len(node.nextNodes))
+    for m, next := range node.nextNodes {
+      moves[m.toNotation()] = next.gs.toPositionString()
+    }
+    entries = append(entries, tablebaseEntry{state.toPositionString(), node.score, node.isScored, moves})
+  }
+  sort.Slice(entries, func(i, j int) bool {
+    return entries[i].Position < entries[j].Position
+  })
+  tablebaseJson, err := json.Marshal(tablebaseFile{cfg.Fingers, cfg.MaxDepth, cfg.Solver, entries})
+  if err != nil {
+    return err
+  }
+  // Write it next to the real file and move it into place, so a crash can't leave half a tablebase behind.
+  tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path) + ".tmp")
+  if err != nil {
+    return err
+  }
+  defer os.Remove(tmp.Name())
+  if _, err := tmp.Write(tablebaseJson); err != nil {
+    tmp.Close()
+    return err
+  }
+  if err := tmp.Close(); err != nil {
+    return err
+  }
+  if err := os.Chmod(tmp.Name(), 0644); err != nil {
+    return err
+  }
+  return os.Rename(tmp.Name(), path)
+}
+
+func loadTablebase(path string, cfg config) (map[GameState]*PlayNode, error) {
+  tablebaseJson, err := os.ReadFile(path)
+  if err != nil {
+    return nil, err
+  }
+  var f tablebaseFile
+  if err := json.Unmarshal(tablebaseJson, &f); err != nil {
+    return nil, fmt.Errorf("Bad tablebase %s: %w", path, err)
+  }
+  if f.Fingers != cfg.Fingers || f.MaxDepth != cfg.MaxDepth || f.Solver != cfg.Solver {
+    return nil, fmt.Errorf("Tablebase %s was solved with %d fingers, max depth %d and the %s solver; delete it to solve it again with %d fingers, max depth %d and the %s solver",
+      path, f.Fingers, f.MaxDepth, f.Solver, cfg.Fingers, cfg.MaxDepth, cfg.Solver)
+  }
+
+  states := make(map[GameState]*PlayNode, len(f.States))
+  for _, entry := range f.States {
+    gs, err := parsePosition(entry.Position)
+    if err != nil {
+      return nil, fmt.Errorf("Bad tablebase %s: %w", path, err)
+    }
+    if !gs.isNormalized() {
+      return nil, fmt.Errorf("Bad tablebase %s: %s isn't normalized", path, entry.Position)
+    }
+    node := createPlayNodeReuseGs(gs)
+    node.score, node.isScored = entry.Score, entry.Scored
+    states[*gs] = node
+  }
+  for _, entry := range f.States {
+    gs, _ := parsePosition(entry.Position)
+    node := states[*gs]
+    for notation, position := range entry.Moves {
+      m, err := parseMoveNotation(notation)
+      if err != nil {
+        return nil, fmt.Errorf("Bad tablebase %s: %w", path, err)
+      }
+      nextGs, err := parsePosition(position)
+      if err != nil {
+        return nil, fmt.Errorf("Bad tablebase %s: %w", path, err)
+      }
+      next, ok := states[*nextGs]
+      if !ok {
+        return nil, fmt.Errorf("Bad tablebase %s: %s leads to %s, which is missing", path, entry.Position, position)
+      }
+      addParentChildEdges(node, next, m)
+    }
+  }
+  return states, nil
+}
+
+// The tablebase at cfg.Tablebase, solving the game from the start and saving it there if there isn't one yet.
+func loadOrBuildTablebase(cfg config, logger *slog.Logger) (map[GameState]*PlayNode, error) {
+  states, err := loadTablebase(cfg.Tablebase, cfg)
+  if err == nil || !errors.Is(err, os.ErrNotExist) {
+    return states, err
+  }
+  _, states, _, _, _, err = solveWithProgress(initGame(), cfg.MaxDepth, nil, logger)
+  if err != nil {
+    return nil, err
+  }
+  if err := saveTablebase(cfg.Tablebase, states, cfg); err != nil {
+    return nil, fmt.Errorf("Can't save tablebase %s: %w", cfg.Tablebase, err)
+  }
+  return states, nil
+}
+
+// The graph solved from the start of the game, from the tablebase if there is one.
+func solvedGraphFromConfig(cfg config, logger *slog.Logger) (*solveGraph, error) {
+  graph := createSolveGraph(make(map[GameState]*PlayNode), cfg.MaxDepth, logger)
+  if cfg.Tablebase == "" {
+    return graph, graph.rebuild(initGame())
+  }
+  states, err := loadOrBuildTablebase(cfg, logger)
+  if err != nil {
+    return nil, err
+  }
+  return createSolveGraph(states, cfg.MaxDepth, logger), nil
+}
+
+// A graph to solve positions on demand, starting from the tablebase if there is one. For commands that only look at
+// a few positions, where solving the whole game would be a waste.
+func lazyGraphFromConfig(cfg config, logger *slog.Logger) (*solveGraph, error) {
+  if cfg.Tablebase == "" {
+    return createSolveGraph(make(map[GameState]*PlayNode), cfg.MaxDepth, logger), nil
+  }
+  return solvedGraphFromConfig(cfg, logger)
+}
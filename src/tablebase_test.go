@@ -0,0 +1,51 @@
+package main
+
+import (
+  "fmt"
+  "log/slog"
+  "os"
+  "path/filepath"
+  "testing"
+)
+
+func TestTablebaseRoundTrip(t *testing.T) {
+  fmt.Println("starting TestTablebaseRoundTrip")
+  cfg := defaultConfig()
+  cfg.Tablebase = filepath.Join(t.TempDir(), "tablebase.json")
+  built, err := loadOrBuildTablebase(cfg, slog.Default())
+  if err != nil {
+    t.Fatal(err.Error())
+  }
+  if _, err := os.Stat(cfg.Tablebase); err != nil {
+    t.Fatalf("Expected the tablebase to be saved: %s", err.Error())
+  }
+  loaded, err := loadOrBuildTablebase(cfg, slog.Default())
+  if err != nil {
+    t.Fatal(err.Error())
+  }
+  if len(loaded) != len(built) {
+    t.Fatalf("Expected %d states, got %d", len(built), len(loaded))
+  }
+  for state, node := range built {
+    other, ok := loaded[state]
+    if !ok || other.score != node.score || other.isScored != node.isScored || len(other.nextNodes) != len(node.nextNodes) {
+      t.Fatalf("Expected %s to be loaded as it was solved: %+v vs %+v", state.toPositionString(), other, node)
+    }
+    for m, next := range node.nextNodes {
+      if loadedNext, ok := other.nextNodes[m]; !ok || *loadedNext.gs != *next.gs || loadedNext != loaded[*next.gs] {
+        t.Fatalf("Expected %s to lead to %s with %s", state.toPositionString(), next.gs.toPositionString(), m.toNotation())
+      }
+    }
+  }
+  root := loaded[*initGame().copyAndNormalize()]
+  if _, _, err := root.validateEdges(true); err != nil {
+    t.Fatal(err.Error())
+  }
+  requireVerified(t, root, loaded)
+
+  // Solving with other settings needs a new tablebase.
+  cfg.MaxDepth = 100
+  if _, err := loadOrBuildTablebase(cfg, slog.Default()); err == nil {
+    t.Fatal("Expected a tablebase solved with another max depth to be refused")
+  }
+}
@@ -0,0 +1,301 @@
+package main
+
+import (
+  "fmt"
+  "math"
+  "math/rand"
+  "sort"
+  "strings"
+)
+
+// Engine tournaments: every game is played from a handful of openings with each engine taking both sides, so
+// neither engine gets the easier half of a lopsided position. Games that go on for too long are draws, since
+// engines that never blunder into a loss can shuffle around the same positions forever.
+
+const TOURNAMENT_DEFAULT_MAX_PLIES = 200
+const TOURNAMENT_DEFAULT_OPENINGS = 4
+var TOURNAMENT_FORMATS []string = []string{"roundrobin", "swiss"}
+
+type tournamentScore struct {
+  Wins int
+  Draws int
+  Losses int
+}
+
+func (s tournamentScore) games() int {
+  return s.Wins + s.Draws + s.Losses
+}
+
+func (s tournamentScore) points() float64 {
+  return float64(s.Wins) + float64(s.Draws) / 2
+}
+
+func (s tournamentScore) add(other tournamentScore) tournamentScore {
+  return tournamentScore{s.Wins + other.Wins, s.Draws + other.Draws, s.Losses + other.Losses}
+}
+
+func (s tournamentScore) toString() string {
+  return fmt.Sprintf("%d-%d-%d", s.Wins, s.Draws, s.Losses)
+}
+
+type tournament struct {
+  graph *solveGraph
+  engines []Engine
+  openings []*GameState
+  maxPlies int
+  // results[i][j] is how engine i did against engine j.
+  results [][]tournamentScore
+  // Swiss rounds where the engine had nobody to play.
+  byes []int
+}
+
+func createTournament(graph *solveGraph, engines []Engine, openings []*GameState, maxPlies int) *tournament {
+  results := make([][]tournamentScore, len(engines))
+  for i := range results {
+    results[i] = make([]tournamentScore, len(engines))
+  }
+  return &tournament{graph, engines, openings, maxPlies, results, make([]int, len(engines))}
+}
+
+// Picks count openings: the usual start of the game, then random positions that come up in normal games.
+func pickOpenings(count int, seed int64) []*GameState {
+  var positions []*GameState
+  for state, _ := range reachableStates(initGame()) {
+    state := state
+    if checkGameResult(&state) == Ongoing && !state.equals(initGame()) {
+      positions = append(positions, &state)
+    }
+  }
+  // Map iteration order is random, sort before shuffling so the seed picks the same positions every time.
+  sort.Slice(positions, func(i, j int) bool {
+    return positions[i].toPositionString() < positions[j].toPositionString()
+  })
+  rand.New(rand.NewSource(seed)).Shuffle(len(positions), func(i, j int) {
+    positions[i], positions[j] = positions[j], positions[i]
+  })
+  openings := []*GameState{initGame()}
+  for i := 0; len(openings) < count && i < len(positions); i++ {
+    openings = append(openings, positions[i])
+  }
+  return openings
+}
+
+// Plays one game between two engines without anyone at the keyboard, the way the cli plays the computer's turns.
+// Returns Ongoing if the game hit maxPlies.
+func playEngineGame(graph *solveGraph, start *GameState, players cliPlayers, maxPlies int) (GameResult, error) {
+  // Nowhere to save it: tournament games go in the tournament's table, not the game store.
+  game, err := createCliGame(players, graph, start, "", nil)
+  if err != nil {
+    return Ongoing, err
+  }
+  for plies := 0; plies < maxPlies && checkGameResult(game.gps.state) == Ongoing; plies++ {
+    if _, err := game.playComputerTurn(); err != nil {
+      return Ongoing, err
+    }
+  }
+  return checkGameResult(game.gps.state), nil
+}
+
+func (t *tournament) record(i int, j int, result GameResult, iTurn Turn) {
+  score := tournamentScore{}
+  switch rewardForTurn(result, iTurn) {
+  case 1:
+    score.Wins++
+  case 0:
+    score.Losses++
+  default:
+    score.Draws++
+  }
+  t.results[i][j] = t.results[i][j].add(score)
+  t.results[j][i] = t.results[j][i].add(tournamentScore{score.Losses, score.Draws, score.Wins})
+}
+
+// Plays engines i and j against each other from every opening, once on each side.
+func (t *tournament) playMatch(i int, j int) error {
+  for _, opening := range t.openings {
+    for _, iTurn := range []Turn{Player1, Player2} {
+      players := cliPlayers{iTurn: t.engines[i], invertTurn(iTurn): t.engines[j]}
+      result, err := playEngineGame(t.graph, opening, players, t.maxPlies)
+      if err != nil {
+        return err
+      }
+      t.record(i, j, result, iTurn)
+    }
+  }
+  return nil
+}
+
+func (t *tournament) roundRobin() error {
+  for i := range t.engines {
+    for j := i + 1; j < len(t.engines); j++ {
+      if err := t.playMatch(i, j); err != nil {
+        return err
+      }
+    }
+  }
+  return nil
+}
+
+// Default number of Swiss rounds: enough to separate the field, about log2 of the number of engines.
+func swissRounds(numEngines int) int {
+  return int(math.Max(1, math.Ceil(math.Log2(float64(numEngines)))))
+}
+
+// Each round pairs engines with similar scores that haven't met yet. With an odd number of engines the lowest ranked
+// engine without a bye sits the round out, and scores nothing for it.
+func (t *tournament) swiss(rounds int) error {
+  for round := 0; round < rounds; round++ {
+    order := make([]int, 0, len(t.engines))
+    for _, s := range t.standings() {
+      order = append(order, s.index)
+    }
+    if len(order) % 2 == 1 {
+      byeIdx := len(order) - 1
+      for k := len(order) - 1; k >= 0; k-- {
+        if t.byes[order[k]] == 0 {
+          byeIdx = k
+          break
+        }
+      }
+      t.byes[order[byeIdx]]++
+      order = append(order[:byeIdx], order[byeIdx + 1:]...)
+    }
+    paired := make(map[int]bool, len(order))
+    for k, i := range order {
+      if paired[i] {
+        continue
+      }
+      // The next engine down that i hasn't played, or just the next engine down if it's played all of them.
+      opponent := -1
+      for _, j := range order[k + 1:] {
+        if paired[j] {
+          continue
+        }
+        if opponent == -1 {
+          opponent = j
+        }
+        if t.results[i][j].games() == 0 {
+          opponent = j
+          break
+        }
+      }
+      paired[i], paired[opponent] = true, true
+      if err := t.playMatch(i, opponent); err != nil {
+        return err
+      }
+    }
+  }
+  return nil
+}
+
+// Maximum likelihood Elo ratings from the results, averaging zero. Every engine also gets a virtual draw against an
+// average engine, so an engine that won or lost every game still gets a finite rating.
+func estimateElo(results [][]tournamentScore) []float64 {
+  ratings := make([]float64, len(results))
+  for iteration := 0; iteration < 2000; iteration++ {
+    for i := range results {
+      actual, expected, games := 0.5, eloExpectedScore(ratings[i], 0), 1
+      for j := range results {
+        if g := results[i][j].games(); g > 0 {
+          actual += results[i][j].points()
+          expected += float64(g) * eloExpectedScore(ratings[i], ratings[j])
+          games += g
+        }
+      }
+      ratings[i] += 400 * (actual - expected) / float64(games)
+    }
+    mean := 0.0
+    for _, r := range ratings {
+      mean += r
+    }
+    mean /= float64(len(ratings))
+    for i := range ratings {
+      ratings[i] -= mean
+    }
+  }
+  return ratings
+}
+
+type tournamentStanding struct {
+  Name string
+  Score tournamentScore
+  Points float64
+  Elo float64
+  Byes int
+  // Position in the engines list
+  index int
+}
+
+// Everyone's totals, most points first.
+func (t *tournament) standings() []tournamentStanding {
+  elo := estimateElo(t.results)
+  standings := make([]tournamentStanding, len(t.engines))
+  for i, engine := range t.engines {
+    total := tournamentScore{}
+    for _, score := range t.results[i] {
+      total = total.add(score)
+    }
+    standings[i] = tournamentStanding{engine.name(), total, total.points(), elo[i], t.byes[i], i}
+  }
+  sort.SliceStable(standings, func(a, b int) bool {
+    if standings[a].Points != standings[b].Points {
+      return standings[a].Points > standings[b].Points
+    }
+    return standings[a].Elo > standings[b].Elo
+  })
+  return standings
+}
+
+// Results as JSON: Crosstable[i][j] is how Engines[i] did against Engines[j].
+type tournamentResult struct {
+  Engines []string
+  Openings []string
+  Crosstable [][]tournamentScore
+  Standings []tournamentStanding
+}
+
+func (t *tournament) result() tournamentResult {
+  names := make([]string, len(t.engines))
+  for i, engine := range t.engines {
+    names[i] = engine.name()
+  }
+  openings := make([]string, len(t.openings))
+  for i, opening := range t.openings {
+    openings[i] = opening.toPositionString()
+  }
+  return tournamentResult{names, openings, t.results, t.standings()}
+}
+
+// The crosstable in standings order, each cell being wins-draws-losses of the row engine against the column engine.
+func (t *tournament) crosstable() string {
+  standings := t.standings()
+  width := len("Engine")
+  for _, s := range standings {
+    if len(s.Name) > width {
+      width = len(s.Name)
+    }
+  }
+  const cellWidth = 10
+  var sb strings.Builder
+  fmt.Fprintf(&sb, "%3s  %-*s", "", width, "Engine")
+  for k := range standings {
+    fmt.Fprintf(&sb, " %*d", cellWidth, k + 1)
+  }
+  fmt.Fprintf(&sb, " %*s %7s %5s\n", cellWidth, "W-D-L", "Points", "Elo")
+  for k, row := range standings {
+    fmt.Fprintf(&sb, "%2d.  %-*s", k + 1, width, row.Name)
+    for _, col := range standings {
+      cell := "-"
+      if row.index != col.index && t.results[row.index][col.index].games() > 0 {
+        cell = t.results[row.index][col.index].toString()
+      }
+      fmt.Fprintf(&sb, " %*s", cellWidth, cell)
+    }
+    fmt.Fprintf(&sb, " %*s %7.1f %+5.0f", cellWidth, row.Score.toString(), row.Points, row.Elo)
+    if row.Byes > 0 {
+      fmt.Fprintf(&sb, "  (byes: %d)", row.Byes)
+    }
+    sb.WriteString("\n")
+  }
+  return sb.String()
+}
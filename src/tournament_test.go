@@ -0,0 +1,111 @@
+package main
+
+import (
+  "fmt"
+  "log/slog"
+  "strings"
+  "testing"
+)
+
+func createTestTournament(t *testing.T, specs []string, openings int) *tournament {
+  graph := createSolveGraph(make(map[GameState]*PlayNode), DEFAULT_MAX_DEPTH, slog.Default())
+  if err := graph.rebuild(initGame()); err != nil {
+    t.Fatal(err.Error())
+  }
+  engines := make([]Engine, len(specs))
+  for i, spec := range specs {
+    engine, err := engineForSpec(spec, int64(i))
+    if err != nil {
+      t.Fatal(err.Error())
+    }
+    engines[i] = engine
+  }
+  return createTournament(graph, engines, pickOpenings(openings, 1), 100)
+}
+
+func TestRoundRobin(t *testing.T) {
+  fmt.Println("starting TestRoundRobin")
+  tour := createTestTournament(t, []string{"perfect", "random", "greedy"}, 3)
+  if len(tour.openings) != 3 || !tour.openings[0].equals(initGame()) {
+    t.Fatalf("Unexpected openings: %+v", tour.openings)
+  }
+  if err := tour.roundRobin(); err != nil {
+    t.Fatal(err.Error())
+  }
+  for i := range tour.engines {
+    for j := range tour.engines {
+      mine, theirs := tour.results[i][j], tour.results[j][i]
+      if mine.Wins != theirs.Losses || mine.Draws != theirs.Draws {
+        t.Fatalf("Results don't match up: %+v vs %+v", mine, theirs)
+      }
+      // Both sides of every opening.
+      if expected := 6; i != j && mine.games() != expected {
+        t.Fatalf("Expected %d games between %d and %d, got %+v", expected, i, j, mine)
+      }
+    }
+  }
+  standings := tour.standings()
+  if standings[0].Name != "perfect" || standings[0].Elo <= standings[len(standings) - 1].Elo {
+    t.Fatalf("Expected perfect to come first: %+v", standings)
+  }
+  if table := tour.crosstable(); !strings.Contains(table, " 1.  perfect") {
+    t.Fatalf("Unexpected crosstable:\n%s", table)
+  }
+}
+
+func TestSwiss(t *testing.T) {
+  fmt.Println("starting TestSwiss")
+  tour := createTestTournament(t, []string{"perfect", "random", "greedy"}, 1)
+  if err := tour.swiss(3); err != nil {
+    t.Fatal(err.Error())
+  }
+  // With three engines someone sits out every round, and nobody sits out twice.
+  for i := range tour.engines {
+    if tour.byes[i] != 1 {
+      t.Fatalf("Expected everyone to get one bye: %+v", tour.byes)
+    }
+    for j := range tour.engines {
+      if i != j && tour.results[i][j].games() != 2 {
+        t.Fatalf("Expected every pair to play once: %+v", tour.results)
+      }
+    }
+  }
+}
+
+func TestEstimateElo(t *testing.T) {
+  fmt.Println("starting TestEstimateElo")
+  // a beats b three games in four, about 190 Elo, and b and c are even. The virtual draws pull everyone in a bit.
+  results := [][]tournamentScore{
+    {{}, {3, 0, 1}, {}},
+    {{1, 0, 3}, {}, {1, 2, 1}},
+    {{}, {1, 2, 1}, {}},
+  }
+  elo := estimateElo(results)
+  if elo[0] - elo[1] < 100 || elo[0] - elo[1] > 191 || elo[0] + elo[1] + elo[2] > 1e-6 {
+    t.Fatalf("Unexpected ratings: %+v", elo)
+  }
+  if elo[1] - elo[2] > 20 || elo[2] - elo[1] > 20 {
+    t.Fatalf("Expected even engines to be rated about the same: %+v", elo)
+  }
+}
+
+// Always plays a Move the starting position doesn't have.
+type illegalEngine struct{}
+
+func (e illegalEngine) name() string {
+  return "illegal"
+}
+
+func (e illegalEngine) chooseMove(node *PlayNode) (Move, error) {
+  return Move{Right, Left}, nil
+}
+
+func TestPlayEngineGameIllegalMove(t *testing.T) {
+  fmt.Println("starting TestPlayEngineGameIllegalMove")
+  tour := createTestTournament(t, []string{"perfect"}, 1)
+  players := cliPlayers{Player1: illegalEngine{}, Player2: tour.engines[0]}
+  _, err := playEngineGame(tour.graph, initGame(), players, 100)
+  if err == nil || !strings.HasPrefix(err.Error(), "illegal played a Move") {
+    t.Fatalf("Expected the error to name the engine that moved, got %v", err)
+  }
+}
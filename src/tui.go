@@ -0,0 +1,503 @@
+package main
+
+import (
+  "fmt"
+  "io"
+  "math"
+  "os"
+  "strings"
+  "time"
+
+  "golang.org/x/term"
+)
+
+// A full-screen terminal ui: the hands are drawn with their fingers up, the last Move is highlighted, and Moves are
+// picked with the arrow keys instead of typed. It only uses ANSI escape codes, so it runs in any modern terminal.
+
+const TUI_HELP = "←/→ pick a hand  enter select  esc back  ? hint  e eval bar  u undo  n new game  q quit"
+// Moves shown in the sidebar, the latest ones if there are more.
+const TUI_MOVE_LIST_LINES = 12
+const TUI_EVAL_BAR_WIDTH = 24
+
+const (
+  ANSI_RESET = "\x1b[0m"
+  ANSI_BOLD = "\x1b[1m"
+  ANSI_DIM = "\x1b[2m"
+  ANSI_REVERSE = "\x1b[7m"
+  ANSI_GREEN = "\x1b[32m"
+  ANSI_YELLOW = "\x1b[33m"
+  ANSI_MAGENTA = "\x1b[35m"
+  ANSI_CYAN = "\x1b[36m"
+  ANSI_CLEAR = "\x1b[H\x1b[2J"
+  ANSI_ENTER_FULL_SCREEN = "\x1b[?1049h\x1b[?25l"
+  ANSI_EXIT_FULL_SCREEN = "\x1b[?25h\x1b[?1049l"
+)
+
+// Keys the tui responds to. Everything else comes through as the character typed.
+const (
+  TUI_KEY_LEFT = "left"
+  TUI_KEY_RIGHT = "right"
+  TUI_KEY_UP = "up"
+  TUI_KEY_DOWN = "down"
+  TUI_KEY_ENTER = "enter"
+  TUI_KEY_ESCAPE = "escape"
+  TUI_KEY_QUIT = "ctrl-c"
+)
+
+func playerColor(t Turn) string {
+  if t == Player1 {
+    return ANSI_CYAN
+  }
+  return ANSI_MAGENTA
+}
+
+// Splits what was read from the terminal into keys. Arrow keys arrive as escape sequences, possibly several in one
+// read, and a lone escape is the escape key.
+func parseTuiKeys(data []byte) []string {
+  arrows := map[byte]string{'A': TUI_KEY_UP, 'B': TUI_KEY_DOWN, 'C': TUI_KEY_RIGHT, 'D': TUI_KEY_LEFT}
+  var keys []string
+  for i := 0; i < len(data); i++ {
+    switch b := data[i]; {
+    case b == 0x1b && i + 2 < len(data) && (data[i + 1] == '[' || data[i + 1] == 'O'):
+      if arrow, ok := arrows[data[i + 2]]; ok {
+        keys = append(keys, arrow)
+      }
+      i += 2
+    case b == 0x1b:
+      keys = append(keys, TUI_KEY_ESCAPE)
+    case b == '\r' || b == '\n' || b == ' ':
+      keys = append(keys, TUI_KEY_ENTER)
+    case b == 0x03 || b == 0x04:
+      keys = append(keys, TUI_KEY_QUIT)
+    case b == 0x7f || b == 0x08:
+      keys = append(keys, TUI_KEY_ESCAPE)
+    default:
+      keys = append(keys, string(rune(b)))
+    }
+  }
+  return keys
+}
+
+// Reads keys until the input ends or done is closed, then closes the channel.
+func readTuiKeys(in io.Reader, keys chan<- string, done <-chan struct{}) {
+  defer close(keys)
+  buf := make([]byte, 64)
+  for {
+    n, err := in.Read(buf)
+    for _, key := range parseTuiKeys(buf[:n]) {
+      select {
+      case keys <- key:
+      case <-done:
+        return
+      }
+    }
+    if err != nil {
+      return
+    }
+  }
+}
+
+type tui struct {
+  *cliGame
+  out io.Writer
+  computerDelay time.Duration
+  showEval bool
+
+  // The hand the cursor is on, and the human's hand once they've picked it, in which case the cursor is on the
+  // opponent's hands.
+  cursor Hand
+  picked bool
+  pickedHand Hand
+  // Shown under the board until the next key.
+  message string
+}
+
+func createTui(out io.Writer, players cliPlayers, graph *solveGraph, start *GameState, humanName string, games GameStore) (*tui, error) {
+  game, err := createCliGame(players, graph, start, humanName, games)
+  if err != nil {
+    return nil, err
+  }
+  return &tui{cliGame: game, out: out}, nil
+}
+
+// Takes over the terminal until the human quits: raw mode, so keys come through as they're pressed, and the
+// alternate screen, so the terminal is left as it was.
+func runTerminalTui(t *tui) error {
+  fd := int(os.Stdin.Fd())
+  if !term.IsTerminal(fd) {
+    return fmt.Errorf("The tui needs a terminal")
+  }
+  state, err := term.MakeRaw(fd)
+  if err != nil {
+    return err
+  }
+  defer term.Restore(fd, state)
+  in, err := cancellableStdin()
+  if err != nil {
+    return err
+  }
+  fmt.Fprint(t.out, ANSI_ENTER_FULL_SCREEN)
+  defer fmt.Fprint(t.out, ANSI_EXIT_FULL_SCREEN)
+  return t.run(in)
+}
+
+// Draws the screen after every key and every computer Move, until the input ends or the human quits. Keys still
+// work while the computer is thinking, so a game between engines can be stopped. If in is an io.Closer, it's closed
+// on the way out; either way, nothing is reading from it once run returns, so the next key typed goes to whoever
+// reads next, e.g. the repl.
+func (t *tui) run(in io.Reader) error {
+  keys := make(chan string)
+  done := make(chan struct{})
+  stopped := make(chan struct{})
+  go func() {
+    readTuiKeys(in, keys, done)
+    close(stopped)
+  }()
+  defer func() {
+    close(done)
+    if closer, ok := in.(io.Closer); ok {
+      closer.Close()
+    }
+    <-stopped
+  }()
+  for {
+    if result := checkGameResult(t.gps.state); result != Ongoing && !t.announced {
+      t.announced = true
+      if err := t.finishGame(result); err != nil {
+        return err
+      }
+    }
+    t.draw()
+    if checkGameResult(t.gps.state) == Ongoing && !t.players.isHuman(t.gps.state.T) {
+      // Without a delay, the computer moves right away so scripted keys always land on the human's turn.
+      if t.computerDelay > 0 {
+        select {
+        case key, ok := <-keys:
+          if !ok || !t.handleKey(key) {
+            return nil
+          }
+          continue
+        case <-time.After(t.computerDelay):
+        }
+      }
+      if _, err := t.playComputerTurn(); err != nil {
+        return err
+      }
+      continue
+    }
+    key, ok := <-keys
+    if !ok || !t.handleKey(key) {
+      return nil
+    }
+  }
+}
+
+func (t *tui) finishGame(result GameResult) error {
+  t.message = t.winnerMessage(result)
+  saved, err := t.saveFinished()
+  if err != nil {
+    return err
+  }
+  if saved {
+    t.message += " Saved game " + t.record.Id + "."
+  }
+  return nil
+}
+
+// Whether the human can pick hands right now.
+func (t *tui) humanToMove() bool {
+  return checkGameResult(t.gps.state) == Ongoing && t.players.isHuman(t.gps.state.T)
+}
+
+func (t *tui) resetCursor() {
+  t.cursor, t.picked = Left, false
+}
+
+// Handles one key, returning false to quit.
+func (t *tui) handleKey(key string) bool {
+  t.message = ""
+  switch key {
+  case "q", TUI_KEY_QUIT:
+    return false
+  case TUI_KEY_LEFT, "h", "a":
+    t.cursor = Left
+  case TUI_KEY_RIGHT, "l", "d":
+    t.cursor = Right
+  case TUI_KEY_ENTER:
+    t.selectHand()
+  case TUI_KEY_ESCAPE:
+    if t.picked {
+      t.cursor, t.picked = t.pickedHand, false
+    }
+  case "e":
+    t.showEval = !t.showEval
+  case "?":
+    if checkGameResult(t.gps.state) != Ongoing {
+      t.message = "The game is over."
+    } else if m, eval, err := t.hint(); err != nil {
+      t.message = "Can't work out a hint: " + err.Error()
+    } else {
+      t.message = fmt.Sprintf("Try %s, it's a %s.", m.toNotation(), eval.toString())
+    }
+  case "u":
+    if undone, err := t.undo(); err != nil {
+      t.message = "Can't undo: " + err.Error()
+    } else if undone == 0 {
+      t.message = "There's nothing of yours to undo."
+    } else {
+      t.message = fmt.Sprintf("Took back %d Moves.", undone)
+    }
+    t.resetCursor()
+  case "n":
+    if err := t.newGame(t.start); err != nil {
+      t.message = "Can't start a new game: " + err.Error()
+    }
+    t.resetCursor()
+  }
+  return true
+}
+
+// Picks the hand under the cursor: first the human's own hand, then the hand it taps, which plays the Move.
+func (t *tui) selectHand() {
+  if !t.humanToMove() {
+    return
+  }
+  if !t.picked {
+    if t.gps.state.getPlayer().getHand(t.cursor) == 0 {
+      t.message = "That hand is out of play."
+      return
+    }
+    t.picked, t.pickedHand = true, t.cursor
+    return
+  }
+  m := Move{t.pickedHand, t.cursor}
+  if !t.gps.state.isMoveValid(m) {
+    t.message = "That hand is out of play."
+    return
+  }
+  if err := t.playMove(m); err != nil {
+    t.message = err.Error()
+    return
+  }
+  t.resetCursor()
+}
+
+func (t *tui) draw() {
+  fmt.Fprint(t.out, ANSI_CLEAR + strings.Join(t.render(), "\r\n"))
+}
+
+// The screen, a line at a time: the board with the move list beside it, then the eval bar, the message and the keys.
+func (t *tui) render() []string {
+  board := t.renderBoard()
+  moveList := t.renderMoveList()
+  lines := []string{ANSI_BOLD + "Chopsticks" + ANSI_RESET, ""}
+  for i := 0; i < len(board) || i < len(moveList); i++ {
+    line := ""
+    if i < len(board) {
+      line = board[i]
+    }
+    line += strings.Repeat(" ", 40 - visibleWidth(line))
+    if i < len(moveList) {
+      line += moveList[i]
+    }
+    lines = append(lines, line)
+  }
+  lines = append(lines, "")
+  if t.showEval {
+    lines = append(lines, t.renderEvalBar(), "")
+  }
+  lines = append(lines, t.status(), ANSI_DIM + TUI_HELP + ANSI_RESET)
+  return lines
+}
+
+// What the human should do next, unless there's a message to show.
+func (t *tui) status() string {
+  if t.message != "" {
+    return t.message
+  }
+  switch {
+  case checkGameResult(t.gps.state) != Ongoing:
+    return t.winnerMessage(checkGameResult(t.gps.state)) + " Press n to play again."
+  case !t.players.isHuman(t.gps.state.T):
+    return t.players.describe(t.gps.state.T) + " is thinking..."
+  case t.picked:
+    return fmt.Sprintf("Tap which hand with your %s hand?", handName(t.pickedHand))
+  default:
+    return "Pick one of your hands to play with."
+  }
+}
+
+func handName(h Hand) string {
+  if h == Left {
+    return "left"
+  }
+  return "right"
+}
+
+// Player 2 at the top and Player 1 at the bottom, unless the human is Player 2, who then sits at the bottom.
+func (t *tui) renderBoard() []string {
+  var top, bottom Turn = Player2, Player1
+  if t.players.isHuman(Player2) && !t.players.isHuman(Player1) {
+    top, bottom = Player1, Player2
+  }
+  var lines []string
+  for i, turn := range []Turn{top, bottom} {
+    if i > 0 {
+      lines = append(lines, "")
+    }
+    lines = append(lines, t.renderPlayer(turn)...)
+  }
+  return lines
+}
+
+func (t *tui) renderPlayer(turn Turn) []string {
+  name := t.players.describe(turn)
+  marker := "   "
+  if checkGameResult(t.gps.state) == Ongoing && t.gps.state.T == turn {
+    marker = "=> "
+  }
+  player := t.gps.state.Player1
+  if turn == Player2 {
+    player = t.gps.state.Player2
+  }
+  left, right := t.renderHand(turn, Left, player.Lh), t.renderHand(turn, Right, player.Rh)
+  lines := []string{marker + playerColor(turn) + ANSI_BOLD + name + ANSI_RESET}
+  for i := range left {
+    lines = append(lines, "   " + left[i] + "    " + right[i])
+  }
+  return lines
+}
+
+// A hand as its fingers over a palm, with the hand's name and count underneath. Knocked out hands are dimmed.
+func (t *tui) renderHand(turn Turn, hand Hand, fingers int8) []string {
+  slots := int(NUM_FINGERS) - 1
+  tips, bases := make([]string, slots), make([]string, slots)
+  for i := 0; i < slots; i++ {
+    tips[i], bases[i] = " ", "╷"
+    if i < int(fingers) {
+      tips[i], bases[i] = "┃", "┃"
+    }
+  }
+  label := fmt.Sprintf("%s %d", strings.ToUpper(handName(hand)[:1]), fingers)
+  if fingers == 0 {
+    label = strings.ToUpper(handName(hand)[:1]) + " out"
+  }
+  width := 2 * slots + 1
+  lines := []string{
+    " " + strings.Join(tips, " ") + " ",
+    " " + strings.Join(bases, " ") + " ",
+    "╰" + strings.Repeat("─", width - 2) + "╯",
+    fmt.Sprintf("%-*s", width, " " + label),
+  }
+  style := t.handStyle(turn, hand, fingers)
+  for i := range lines {
+    lines[i] = style + lines[i] + ANSI_RESET
+  }
+  return lines
+}
+
+// The cursor shows in reverse, the hand the human picked in green, and the two hands of the last Move in yellow.
+func (t *tui) handStyle(turn Turn, hand Hand, fingers int8) string {
+  if t.humanToMove() {
+    onCursorSide := (turn == t.gps.state.T) != t.picked
+    if onCursorSide && hand == t.cursor {
+      return ANSI_REVERSE + playerColor(turn)
+    }
+    if t.picked && turn == t.gps.state.T && hand == t.pickedHand {
+      return ANSI_BOLD + ANSI_GREEN
+    }
+  }
+  if len(t.record.Moves) > 0 {
+    last := t.record.Moves[len(t.record.Moves) - 1].Move
+    mover := invertTurn(t.gps.state.T)
+    if (turn == mover && hand == last.PlayerHand) || (turn != mover && hand == last.ReceiverHand) {
+      return ANSI_BOLD + ANSI_YELLOW
+    }
+  }
+  if fingers == 0 {
+    return ANSI_DIM
+  }
+  return playerColor(turn)
+}
+
+// The Moves in game notation, a Move number per line.
+func (t *tui) renderMoveList() []string {
+  lines := []string{ANSI_BOLD + "Moves" + ANSI_RESET}
+  var rows []string
+  turn := t.start.T
+  for i, recorded := range t.record.Moves {
+    notation := recorded.Move.toNotation()
+    if turn == Player1 {
+      rows = append(rows, fmt.Sprintf("%3d. %-5s", len(rows) + 1, notation))
+    } else if i == 0 {
+      rows = append(rows, fmt.Sprintf("%3d. %-5s %s", 1, "...", notation))
+    } else {
+      rows[len(rows) - 1] += " " + notation
+    }
+    turn = invertTurn(turn)
+  }
+  if len(rows) > TUI_MOVE_LIST_LINES {
+    rows = rows[len(rows) - TUI_MOVE_LIST_LINES:]
+  }
+  if len(rows) == 0 {
+    rows = []string{ANSI_DIM + "  none yet" + ANSI_RESET}
+  }
+  return append(lines, rows...)
+}
+
+// A bar filled with Player 1's color as far as Player 1 is winning, from the solved value of the position.
+func (t *tui) renderEvalBar() string {
+  share, label := 0.5, ""
+  if result := checkGameResult(t.gps.state); result != Ongoing {
+    if result == Player1Wins {
+      share = 1
+    } else {
+      share = 0
+    }
+    label = result.toString()
+  } else if node, err := t.currentNode(); err != nil {
+    label = "can't evaluate: " + err.Error()
+  } else if eval, err := t.currentEval(); err != nil {
+    label = "can't evaluate: " + err.Error()
+  } else {
+    share = evalBarShare(eval, t.gps.state.T, node.score)
+    label = fmt.Sprintf("%s to move: %s", turnToString(t.gps.state.T), eval.toString())
+  }
+  filled := int(share * TUI_EVAL_BAR_WIDTH)
+  return "Eval " + ANSI_CYAN + strings.Repeat("█", filled) + ANSI_MAGENTA +
+    strings.Repeat("█", TUI_EVAL_BAR_WIDTH - filled) + ANSI_RESET + " " + label
+}
+
+// How much of the eval bar is Player 1's, for an ongoing game. A forced win fills at least three quarters of the bar
+// for the winner, more the sooner it comes, up to all of it for a win on the next Move. Drawn positions stay within a
+// quarter of the middle, leaning towards whoever the solver's score favors.
+func evalBarShare(eval positionEval, turn Turn, score float32) float64 {
+  if eval.Outcome == Draw {
+    return 0.5 + 0.25 * math.Max(-1, math.Min(1, float64(score)))
+  }
+  plies := eval.Plies
+  if plies < 1 {
+    plies = 1
+  }
+  winnerShare := 0.75 + 0.25 / float64(plies)
+  if (eval.Outcome == Win) == (turn == Player1) {
+    return winnerShare
+  }
+  return 1 - winnerShare
+}
+
+// How many columns a line takes up, not counting escape codes.
+func visibleWidth(line string) int {
+  width, inEscape := 0, false
+  for _, r := range line {
+    switch {
+    case inEscape:
+      inEscape = !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z')
+    case r == 0x1b:
+      inEscape = true
+    default:
+      width++
+    }
+  }
+  return width
+}
@@ -0,0 +1,13 @@
+//go:build !unix
+
+package main
+
+import (
+  "io"
+  "os"
+)
+
+// Elsewhere the tui reads stdin directly, and a read still waiting when it exits takes the next key.
+func cancellableStdin() (io.ReadCloser, error) {
+  return io.NopCloser(os.Stdin), nil
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+  "bytes"
+  "fmt"
+  "io"
+  "log/slog"
+  "os"
+  "path/filepath"
+  "reflect"
+  "regexp"
+  "strings"
+  "testing"
+  "time"
+)
+
+func createTestTui(t *testing.T, position string, games GameStore) (*tui, *bytes.Buffer) {
+  start, err := startPosition(position, true)
+  if err != nil {
+    t.Fatal(err.Error())
+  }
+  _, visitedStates, _, _, _, err := solve(start, DEFAULT_MAX_DEPTH)
+  if err != nil {
+    t.Fatal(err.Error())
+  }
+  engine, err := createSolverEngine("perfect", 1)
+  if err != nil {
+    t.Fatal(err.Error())
+  }
+  var out bytes.Buffer
+  players := cliPlayers{start.T: nil, invertTurn(start.T): engine}
+  tui, err := createTui(&out, players, createSolveGraph(visitedStates, DEFAULT_MAX_DEPTH, slog.Default()), start, "alice", games)
+  if err != nil {
+    t.Fatal(err.Error())
+  }
+  return tui, &out
+}
+
+// The screen as plain text.
+func renderedText(tui *tui) string {
+  return regexp.MustCompile("\x1b\\[[0-9;?]*[a-zA-Z]").ReplaceAllString(strings.Join(tui.render(), "\n"), "")
+}
+
+func TestParseTuiKeys(t *testing.T) {
+  fmt.Println("starting TestParseTuiKeys")
+  keys := parseTuiKeys([]byte("\x1b[D\x1bOC\r \x1bq\x1b[A?\x03"))
+  expected := []string{TUI_KEY_LEFT, TUI_KEY_RIGHT, TUI_KEY_ENTER, TUI_KEY_ENTER, TUI_KEY_ESCAPE, "q", TUI_KEY_UP, "?", TUI_KEY_QUIT}
+  if !reflect.DeepEqual(keys, expected) {
+    t.Fatalf("Expected %v, got %v", expected, keys)
+  }
+}
+
+func TestTuiPicksHands(t *testing.T) {
+  fmt.Println("starting TestTuiPicksHands")
+  tui, out := createTestTui(t, "", nil)
+  // Pick the right hand, think better of it, then play the left hand onto the right hand.
+  if err := tui.run(strings.NewReader("\x1b[C\r\x1b\x1b[D\r\x1b[C\r")); err != nil {
+    t.Fatal(err.Error())
+  }
+  if len(tui.record.Moves) != 2 || tui.record.Moves[0].Move != (Move{Left, Right}) {
+    t.Fatalf("Expected L>R and the computer's reply: %+v", tui.record.Moves)
+  }
+  if !strings.Contains(out.String(), ANSI_CLEAR) {
+    t.Fatal("Expected the screen to be redrawn")
+  }
+  text := renderedText(tui)
+  for _, expected := range []string{"Player 1 (you)", "Player 2 (perfect)", "1. L>R   " + tui.record.Moves[1].Move.toNotation(), "Pick one of your hands"} {
+    if !strings.Contains(text, expected) {
+      t.Fatalf("Expected %q on the screen:\n%s", expected, text)
+    }
+  }
+
+  tui.handleKey("u")
+  if len(tui.record.Moves) != 0 || !strings.Contains(renderedText(tui), "Took back 2 Moves.") {
+    t.Fatalf("Expected undo to take back both Moves:\n%s", renderedText(tui))
+  }
+}
+
+func TestTuiWins(t *testing.T) {
+  fmt.Println("starting TestTuiWins")
+  games := createFileGameStore(filepath.Join(t.TempDir(), "games.jsonl"))
+  tui, _ := createTestTui(t, "10/40 w", games)
+  tui.handleKey(TUI_KEY_RIGHT)
+  tui.handleKey(TUI_KEY_ENTER)
+  if tui.picked || !strings.Contains(renderedText(tui), "That hand is out of play.") {
+    t.Fatalf("Expected the knocked out hand to be refused:\n%s", renderedText(tui))
+  }
+  tui.handleKey("e")
+  tui.handleKey("?")
+  text := renderedText(tui)
+  for _, expected := range []string{"Try L>L, it's a win in 1.", "Player 1 to move: win in 1", "R out"} {
+    if !strings.Contains(text, expected) {
+      t.Fatalf("Expected %q on the screen:\n%s", expected, text)
+    }
+  }
+
+  if err := tui.run(strings.NewReader("\x1b[D\r\r\r")); err != nil {
+    t.Fatal(err.Error())
+  }
+  text = renderedText(tui)
+  if !strings.Contains(text, "You win! Press n to play again.") || !strings.Contains(text, "Player 1 wins") {
+    t.Fatalf("Expected the win to be announced:\n%s", text)
+  }
+  if saved, ok, err := games.get(tui.record.Id); err != nil || !ok || saved.Result != Player1Wins {
+    t.Fatalf("Expected the game to be saved: %+v, %v", saved, err)
+  }
+}
+
+func TestVisibleWidth(t *testing.T) {
+  fmt.Println("starting TestVisibleWidth")
+  if width := visibleWidth(ANSI_BOLD + "╰──╯" + ANSI_RESET + " L"); width != 6 {
+    t.Fatalf("Expected 6 columns, got %d", width)
+  }
+}
+
+func TestEvalBarShare(t *testing.T) {
+  fmt.Println("starting TestEvalBarShare")
+  winNow := evalBarShare(positionEval{Win, 1}, Player1, 1)
+  winLater := evalBarShare(positionEval{Win, 5}, Player1, 1)
+  if winNow != 1 || winLater <= 0.75 || winLater >= winNow {
+    t.Fatalf("Expected sooner wins to fill more of the bar: %f, %f", winNow, winLater)
+  }
+  if lose := evalBarShare(positionEval{Loss, 5}, Player1, -1); lose != 1 - winLater {
+    t.Fatalf("Expected a loss to mirror the win, got %f", lose)
+  }
+  if share := evalBarShare(positionEval{Win, 5}, Player2, -1); share != 1 - winLater {
+    t.Fatalf("Expected Player 2's win to be Player 1's loss, got %f", share)
+  }
+  even := evalBarShare(positionEval{Draw, 0}, Player1, 0)
+  ahead := evalBarShare(positionEval{Draw, 0}, Player2, 0.5)
+  if even != 0.5 || ahead <= 0.5 || ahead >= 0.75 {
+    t.Fatalf("Expected draws to stay near the middle, leaning with the score: %f, %f", even, ahead)
+  }
+}
+
+// Closing it interrupts the read in progress but leaves the pipe open, like the tui's copy of stdin.
+type interruptibleReader struct {
+  *os.File
+}
+
+func (r interruptibleReader) Close() error {
+  return r.SetReadDeadline(time.Now())
+}
+
+func TestTuiStopsReading(t *testing.T) {
+  fmt.Println("starting TestTuiStopsReading")
+  tui, _ := createTestTui(t, "", nil)
+  r, w, err := os.Pipe()
+  if err != nil {
+    t.Fatal(err.Error())
+  }
+  defer r.Close()
+  defer w.Close()
+  if _, err := io.WriteString(w, "q"); err != nil {
+    t.Fatal(err.Error())
+  }
+  if err := tui.run(interruptibleReader{r}); err != nil {
+    t.Fatal(err.Error())
+  }
+  // Whatever's typed after the tui exits goes to the next reader.
+  if err := r.SetReadDeadline(time.Now().Add(time.Second)); err != nil {
+    t.Fatal(err.Error())
+  }
+  if _, err := io.WriteString(w, "x"); err != nil {
+    t.Fatal(err.Error())
+  }
+  buf := make([]byte, 8)
+  if n, err := r.Read(buf); err != nil || string(buf[:n]) != "x" {
+    t.Fatalf("Expected to read the next key, got %q, %v", buf[:n], err)
+  }
+}
@@ -0,0 +1,38 @@
+//go:build unix
+
+package main
+
+import (
+  "io"
+  "os"
+  "syscall"
+)
+
+// A copy of stdin that stops being read from once it's closed. Reads from os.Stdin block until a key is pressed
+// and can't be interrupted, so a reader left behind by the tui would swallow the next key typed at the repl. The
+// copy is non-blocking, which lets Go wake up a read that's waiting on it when it's closed. Closing it puts stdin
+// back the way it was.
+func cancellableStdin() (io.ReadCloser, error) {
+  fd := int(os.Stdin.Fd())
+  copied, err := syscall.Dup(fd)
+  if err != nil {
+    return nil, err
+  }
+  // The flag is shared with stdin, see close.
+  if err := syscall.SetNonblock(copied, true); err != nil {
+    syscall.Close(copied)
+    return nil, err
+  }
+  return &stdinCopy{os.NewFile(uintptr(copied), "stdin"), fd}, nil
+}
+
+type stdinCopy struct {
+  *os.File
+  stdinFd int
+}
+
+func (s *stdinCopy) Close() error {
+  err := s.File.Close()
+  syscall.SetNonblock(s.stdinFd, false)
+  return err
+}
@@ -0,0 +1,230 @@
+package main
+
+import (
+  "fmt"
+  "log/slog"
+  "math"
+  "sort"
+  "strings"
+)
+
+// Checks a solved graph against the rules of the game and against itself, so changes to the explorer and scorer
+// can be trusted: scores have to follow from the children's under negamax, finished games have to be scored as
+// wins, edges have to go both ways, every Move from the root has to be in the graph, and no score can be a
+// heuristic guess where retrograde analysis finds a forced result. Every violation is reported, not just the first.
+
+const VERIFY_NEGAMAX = "negamax"
+const VERIFY_TERMINAL = "terminal"
+const VERIFY_EDGES = "edges"
+const VERIFY_MISSING = "missing"
+const VERIFY_HEURISTIC = "heuristic"
+// Scores are float32s worked out in the same way, so they should be equal, but leave room for rounding.
+const VERIFY_SCORE_TOLERANCE = 1e-6
+
+type verifyViolation struct {
+  // Which check failed, one of the VERIFY_ constants.
+  Check string
+  Position string
+  Message string
+}
+
+type verifyReport struct {
+  States int
+  Violations []verifyViolation
+}
+
+func (report verifyReport) ok() bool {
+  return len(report.Violations) == 0
+}
+
+func (report verifyReport) toString() string {
+  if report.ok() {
+    return fmt.Sprintf("Verified %d states, no violations", report.States)
+  }
+  lines := []string{fmt.Sprintf("Verified %d states, %d violations:", report.States, len(report.Violations))}
+  for _, v := range report.Violations {
+    lines = append(lines, fmt.Sprintf("%s: %s: %s", v.Check, v.Position, v.Message))
+  }
+  return strings.Join(lines, "\n")
+}
+
+func (report *verifyReport) add(check string, gs *GameState, format string, args ...interface{}) {
+  report.Violations = append(report.Violations, verifyViolation{check, gs.toPositionString(), fmt.Sprintf(format, args...)})
+}
+
+func scoresEqual(a float32, b float32) bool {
+  return math.Abs(float64(a - b)) <= VERIFY_SCORE_TOLERANCE
+}
+
+// Checks the graph solved from root. states has every node in it, keyed by their normalized states.
+func verifyGraph(root *PlayNode, states map[GameState]*PlayNode) verifyReport {
+  report := verifyReport{States: len(states), Violations: []verifyViolation{}}
+  evals := evaluatePositions(states)
+  // Sorted, so the same graph always gets the same report.
+  keys := make([]GameState, 0, len(states))
+  for state := range states {
+    keys = append(keys, state)
+  }
+  sort.Slice(keys, func(i, j int) bool {
+    return keys[i].toPositionString() < keys[j].toPositionString()
+  })
+  for _, state := range keys {
+    node := states[state]
+    verifyEdges(&report, node, state, states)
+    verifyMoves(&report, node)
+    verifyTerminal(&report, node)
+    verifyNegamax(&report, node)
+    verifyExactScore(&report, node, evals[state])
+  }
+  verifyReachable(&report, root, states)
+  return report
+}
+
+func verifyEdges(report *verifyReport, node *PlayNode, state GameState, states map[GameState]*PlayNode) {
+  if !node.gs.equals(&state) {
+    report.add(VERIFY_EDGES, &state, "keyed by the wrong state, its node is %s", node.gs.toPositionString())
+  }
+  if _, _, err := node.validateEdges(false); err != nil {
+    report.add(VERIFY_EDGES, node.gs, "%s", err.Error())
+  }
+  for _, m := range sortedMoves(node.nextNodes) {
+    if next := node.nextNodes[m]; states[*next.gs] != next {
+      report.add(VERIFY_EDGES, node.gs, "%s leads to %s, which isn't the graph's node for it", m.toNotation(), next.gs.toPositionString())
+    }
+  }
+}
+
+// Every legal Move from an explored node has to be there, leading where the rules say.
+func verifyMoves(report *verifyReport, node *PlayNode) {
+  if len(node.nextNodes) == 0 {
+    return
+  }
+  legal := 0
+  for _, playerHand := range node.gs.getPlayer().getDistinctPlayableHands() {
+    for _, receiverHand := range node.gs.getReceiver().getDistinctPlayableHands() {
+      m := Move{playerHand, receiverHand}
+      legal++
+      nextState, err := node.gs.copyAndPlayTurn(playerHand, receiverHand)
+      if err != nil {
+        report.add(VERIFY_MISSING, node.gs, "can't play %s: %s", m.toNotation(), err.Error())
+        continue
+      }
+      nextState.normalize()
+      next, ok := node.nextNodes[m]
+      if !ok {
+        report.add(VERIFY_MISSING, node.gs, "%s to %s isn't in the graph", m.toNotation(), nextState.toPositionString())
+      } else if !next.gs.equals(nextState) {
+        report.add(VERIFY_EDGES, node.gs, "%s leads to %s, should be %s", m.toNotation(), next.gs.toPositionString(), nextState.toPositionString())
+      }
+    }
+  }
+  if len(node.nextNodes) != legal {
+    report.add(VERIFY_EDGES, node.gs, "has %d Moves, but only %d are legal", len(node.nextNodes), legal)
+  }
+}
+
+func verifyTerminal(report *verifyReport, node *PlayNode) {
+  var expected float32
+  switch checkGameResult(node.gs) {
+  case Player1Wins:
+    expected = 1
+  case Player2Wins:
+    expected = -1
+  default:
+    return
+  }
+  if len(node.nextNodes) != 0 {
+    report.add(VERIFY_TERMINAL, node.gs, "the game is over, but it has %d Moves", len(node.nextNodes))
+  }
+  if !node.isScored || !scoresEqual(node.score, expected) {
+    report.add(VERIFY_TERMINAL, node.gs, "the game is over, scored %f (scored: %t), should be %.0f", node.score, node.isScored, expected)
+  }
+}
+
+// Unexplored leaves get heuristic scores, everything else the best of its children's.
+func verifyNegamax(report *verifyReport, node *PlayNode) {
+  if node.isTerminal() {
+    return
+  }
+  if !node.isScored {
+    report.add(VERIFY_NEGAMAX, node.gs, "isn't scored")
+    return
+  }
+  expected, err := node.computeScore(false)
+  if err != nil {
+    report.add(VERIFY_NEGAMAX, node.gs, "can't score it from its children: %s", err.Error())
+    return
+  }
+  if !scoresEqual(node.score, expected) {
+    report.add(VERIFY_NEGAMAX, node.gs, "scored %f, its children make it %f", node.score, expected)
+  }
+}
+
+// Where a win or loss can be forced, the score has to say so, and where it can't, the score can't claim one.
+func verifyExactScore(report *verifyReport, node *PlayNode, eval positionEval) {
+  score := node.scoreForCurrentPlayer()
+  switch eval.Outcome {
+  case Win, Loss:
+    if !scoresEqual(score, float32(eval.Outcome)) {
+      report.add(VERIFY_HEURISTIC, node.gs, "scored %f for the Player to move, who has a %s", score, eval.toString())
+    }
+  default:
+    if scoresEqual(score, 1) || scoresEqual(score, -1) {
+      report.add(VERIFY_HEURISTIC, node.gs, "scored %f for the Player to move, but neither Player can force a win", score)
+    }
+  }
+}
+
+// Plays every Move from the root by the rules, and reports states the graph doesn't have. The search stops at
+// unexplored leaves, where the solver stopped too.
+func verifyReachable(report *verifyReport, root *PlayNode, states map[GameState]*PlayNode) {
+  if root == nil {
+    return
+  }
+  start := root.gs.copyAndNormalize()
+  reached := map[GameState]bool{*start: true}
+  frontier := createDumbQueue() // Values are *GameState
+  frontier.enqueue(start)
+  for !frontier.isEmpty() {
+    gsIf, _ := frontier.dequeue()
+    gs := gsIf.(*GameState)
+    node, ok := states[*gs]
+    if !ok {
+      report.add(VERIFY_MISSING, gs, "can be reached from %s, but isn't in the graph", start.toPositionString())
+      continue
+    }
+    if checkGameResult(gs) != Ongoing || node.isUnexploredLeaf() {
+      continue
+    }
+    for _, playerHand := range gs.getPlayer().getDistinctPlayableHands() {
+      for _, receiverHand := range gs.getReceiver().getDistinctPlayableHands() {
+        nextState, err := gs.copyAndPlayTurn(playerHand, receiverHand)
+        if err != nil {
+          continue
+        }
+        nextState.normalize()
+        if !reached[*nextState] {
+          reached[*nextState] = true
+          frontier.enqueue(nextState)
+        }
+      }
+    }
+  }
+}
+
+// The graph to verify for the position: the tablebase if there is one, or a fresh solve from the position.
+func graphToVerify(cfg config, gs *GameState, logger *slog.Logger) (*PlayNode, map[GameState]*PlayNode, error) {
+  if cfg.Tablebase == "" {
+    root, states, _, _, _, err := solveWithProgress(gs.copyAndNormalize(), cfg.MaxDepth, nil, logger)
+    return root, states, err
+  }
+  graph, err := solvedGraphFromConfig(cfg, logger)
+  if err != nil {
+    return nil, nil, err
+  }
+  root, ok := graph.lookup(gs)
+  if !ok {
+    return nil, nil, fmt.Errorf("Position %s isn't in tablebase %s", gs.toPositionString(), cfg.Tablebase)
+  }
+  return root, graph.snapshot().states, nil
+}
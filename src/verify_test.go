@@ -0,0 +1,102 @@
+package main
+
+import (
+  "fmt"
+  "testing"
+)
+
+// Fails the test with every violation in the graph.
+func requireVerified(t *testing.T, root *PlayNode, states map[GameState]*PlayNode) {
+  t.Helper()
+  if report := verifyGraph(root, states); !report.ok() {
+    t.Fatal(report.toString())
+  }
+}
+
+func TestVerifySolvedGame(t *testing.T) {
+  fmt.Println("starting TestVerifySolvedGame")
+  root, states, _, _, _, err := solve(initGame(), DEFAULT_MAX_DEPTH)
+  if err != nil {
+    t.Fatal(err.Error())
+  }
+  requireVerified(t, root, states)
+}
+
+// Checks that verifying the graph finds the check's violation at the position.
+func requireViolation(t *testing.T, root *PlayNode, states map[GameState]*PlayNode, check string, position string) {
+  t.Helper()
+  report := verifyGraph(root, states)
+  for _, v := range report.Violations {
+    if v.Check == check && v.Position == position {
+      return
+    }
+  }
+  t.Fatalf("Expected a %s violation at %s:\n%s", check, position, report.toString())
+}
+
+func TestVerifyFindsViolations(t *testing.T) {
+  fmt.Println("starting TestVerifyFindsViolations")
+  solveFresh := func() (*PlayNode, map[GameState]*PlayNode) {
+    root, states, _, _, _, err := solve(initGame(), DEFAULT_MAX_DEPTH)
+    if err != nil {
+      t.Fatal(err.Error())
+    }
+    return root, states
+  }
+  lookup := func(states map[GameState]*PlayNode, position string) *PlayNode {
+    gs, err := parsePosition(position)
+    if err != nil {
+      t.Fatal(err.Error())
+    }
+    node, ok := states[*gs]
+    if !ok {
+      t.Fatalf("Expected %s to be solved", position)
+    }
+    return node
+  }
+
+  // A finished game scored as a draw.
+  root, states := solveFresh()
+  lookup(states, "00/11 w").score = 0
+  requireViolation(t, root, states, VERIFY_TERMINAL, "00/11 w")
+
+  // A score that doesn't follow from the children.
+  root, states = solveFresh()
+  lookup(states, "11/11 w").score = 0.5
+  requireViolation(t, root, states, VERIFY_NEGAMAX, "11/11 w")
+
+  // A child that doesn't point back at its parent.
+  root, states = solveFresh()
+  for _, next := range root.nextNodes {
+    delete(next.prevNodes, *root.gs)
+    break
+  }
+  requireViolation(t, root, states, VERIFY_EDGES, "11/11 w")
+
+  // A Move and the state it leads to left out. 11/12 w has two Moves, so it still looks explored without one.
+  root, states = solveFresh()
+  node := lookup(states, "11/12 w")
+  m := sortedMoves(node.nextNodes)[0]
+  next := node.nextNodes[m]
+  delete(node.nextNodes, m)
+  delete(next.prevNodes, *node.gs)
+  delete(states, *next.gs)
+  requireViolation(t, root, states, VERIFY_MISSING, "11/12 w")
+  requireViolation(t, root, states, VERIFY_MISSING, next.gs.toPositionString())
+
+  // A heuristic score on a position that's lost, whose parents agree with it.
+  root, states = solveFresh()
+  evals := evaluatePositions(states)
+  found := false
+  for state, node := range states {
+    if eval := evals[state]; eval.Outcome == Loss && eval.Plies > 0 {
+      node.score = node.getHeuristicScore()
+      requireViolation(t, root, states, VERIFY_HEURISTIC, state.toPositionString())
+      found = true
+      break
+    }
+  }
+  if !found {
+    t.Fatal("Expected a lost position that isn't over yet")
+  }
+}